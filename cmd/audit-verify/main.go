@@ -0,0 +1,86 @@
+// audit-verify kiểm tra chuỗi hash của audit log ngoài server, dùng cho auditor:
+//
+//	go run ./cmd/audit-verify [-checkpoints checkpoints.log]
+//
+// Người có quyền ghi DB có thể xóa cả sự kiện lẫn checkpoint, vì vậy checkpoint cần được lưu thêm
+// ở nơi khác: mỗi checkpoint mới được server ghi ra log dạng "audit checkpoint {json}".
+// -checkpoints nhận file gồm các dòng log đó (hoặc mỗi dòng một checkpoint JSON)
+// để kiểm tra cùng checkpoint trong DB.
+//
+// Thoát với mã 1 nếu chuỗi bị thay đổi.
+package main
+
+import (
+	"UserManagementVer/collections"
+	configs "UserManagementVer/configs"
+	"UserManagementVer/db"
+	"UserManagementVer/models"
+	"UserManagementVer/services"
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	checkpointsFile := flag.String("checkpoints", "", "file checkpoint đã xuất ra ngoài DB")
+	flag.Parse()
+
+	var opts services.AuditVerifyOptions
+	if *checkpointsFile != "" {
+		checkpoints, err := readCheckpoints(*checkpointsFile)
+		if err != nil {
+			log.Fatal("Không thể đọc file checkpoint: ", err)
+		}
+		opts.Checkpoints = checkpoints
+	}
+
+	configs.LoadFileConfig()
+	database := db.ConnectMongo(configs.AppConfig.Database.URI, configs.AppConfig.Database.Name)
+	auditService := services.NewAuditService(
+		collections.NewAuditEventCollection(database.Collection("audit_events")),
+		collections.NewAuditCheckpointCollection(database.Collection("audit_checkpoints")),
+		services.LoadAuditSigner(configs.AppConfig.Audit.SigningKey),
+		time.Duration(configs.AppConfig.Audit.CheckpointIntervalMinutes)*time.Minute,
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+	result, err := auditService.Verify(ctx, opts)
+	if err != nil {
+		log.Fatal("Không thể kiểm tra audit log: ", err)
+	}
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+	if !result.Valid {
+		os.Exit(1)
+	}
+}
+
+// readCheckpoints đọc mỗi dòng một checkpoint JSON, phần đứng trước JSON (prefix của log) được bỏ qua
+func readCheckpoints(path string) ([]models.AuditCheckpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	checkpoints := []models.AuditCheckpoint{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		start := strings.Index(scanner.Text(), "{")
+		if start < 0 {
+			continue
+		}
+		var checkpoint models.AuditCheckpoint
+		if err := json.Unmarshal([]byte(scanner.Text()[start:]), &checkpoint); err != nil {
+			return nil, fmt.Errorf("dòng %d: %w", line, err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, scanner.Err()
+}
@@ -2,6 +2,7 @@ package collections
 
 import (
 	"UserManagementVer/models"
+	"UserManagementVer/repositories"
 	"UserManagementVer/utils"
 	"context"
 	"errors"
@@ -17,53 +18,99 @@ import (
 
 type AccountCollection struct {
 	collection *mongo.Collection
+	outbox     *OutboxCollection
+	// cache có thể nil khi không bật Redis
+	cache *AccountCache
 }
 
-func NewAccountCollection(collection *mongo.Collection) *AccountCollection {
+var _ repositories.AccountRepository = (*AccountCollection)(nil)
+
+func NewAccountCollection(collection *mongo.Collection, outbox *OutboxCollection, cache *AccountCache) *AccountCollection {
 	return &AccountCollection{
 		collection: collection,
+		outbox:     outbox,
+		cache:      cache,
 	}
 }
 
-func (a *AccountCollection) Create(ctx context.Context, account models.Account) error {
+func (a *AccountCollection) Create(ctx context.Context, account models.Account) (primitive.ObjectID, error) {
 	var (
 		err error
 	)
 	account.Password, err = utils.HashPassword(account.Password)
 	account.CreatedAt = time.Now()
 	if err != nil {
-		return err
+		return primitive.NilObjectID, err
 	}
-	_, err = a.collection.InsertOne(ctx, account)
-	return err
+	if account.Id.IsZero() {
+		account.Id = primitive.NewObjectID()
+	}
+	event, err := models.NewOutboxEvent(models.WebhookEventAccountCreated, models.AggregateAccount, account.Id, models.AccountEventPayload(account))
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	err = a.outbox.WithTransaction(ctx, func(ctx context.Context) error {
+		if _, err := a.collection.InsertOne(ctx, account); err != nil {
+			return err
+		}
+		return a.outbox.Add(ctx, event)
+	})
+	a.invalidate(ctx, account)
+	return account.Id, err
 }
 
 func (a *AccountCollection) GetAccountById(ctx context.Context, objectId primitive.ObjectID) (models.Account, error) {
 
-	var (
-		account models.Account
-		err     error
-	)
 	fmt.Println(objectId.Hex())
-	err = a.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&account)
-	if err != nil {
-		return account, err
+	opts := accountFindOneOptions(repositories.ById(objectId))
+	if a.cache != nil {
+		return a.cache.GetById(ctx, objectId, func(ctx context.Context) (models.Account, error) {
+			return a.findOne(ctx, bson.M{"_id": objectId}, opts)
+		})
 	}
-	return account, nil
+	return a.findOne(ctx, bson.M{"_id": objectId}, opts)
 }
 
-func (a *AccountCollection) Find(ctx context.Context, filter bson.M) (models.Account, error) {
+// Find chỉ dùng cache khi tìm đúng theo email, các query khác luôn đọc từ Mongo
+func (a *AccountCollection) Find(ctx context.Context, query repositories.AccountQuery) (models.Account, error) {
+	filter, opts := accountFilter(query), accountFindOneOptions(query)
+	if isEmailLookup(query) && a.cache != nil {
+		return a.cache.GetByEmail(ctx, query.Email, func(ctx context.Context) (models.Account, error) {
+			return a.findOne(ctx, filter, opts)
+		})
+	}
+	return a.findOne(ctx, filter, opts)
+}
+
+func (a *AccountCollection) findOne(ctx context.Context, filter bson.M, opts *options.FindOneOptions) (models.Account, error) {
 	var account models.Account
-	err := a.collection.FindOne(ctx, filter).Decode(&account)
+	err := a.collection.FindOne(ctx, filter, opts).Decode(&account)
 	if err != nil {
 		return models.Account{}, err
 	}
 	return account, nil
 }
 
-func (a *AccountCollection) FindAll(ctx context.Context, filter bson.M) ([]models.Account, error) {
+// invalidate xóa cache sau mỗi lần ghi (kể cả khi lỗi vì có thể đã ghi một phần),
+// tài khoản được đọc lại từ Mongo ở lần truy cập sau
+func (a *AccountCollection) invalidate(ctx context.Context, accounts ...models.Account) {
+	if a.cache != nil {
+		a.cache.Invalidate(ctx, accounts...)
+	}
+}
+
+// Cache trả về nil khi không bật cache
+func (a *AccountCollection) Cache() *AccountCache {
+	return a.cache
+}
+
+func (a *AccountCollection) FindAll(ctx context.Context, query repositories.AccountQuery) ([]models.Account, error) {
+	return a.find(ctx, accountFilter(query), accountFindOptions(query))
+}
+
+func (a *AccountCollection) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Account, error) {
 	var accounts []models.Account
-	cursor, err := a.collection.Find(ctx, filter)
+	cursor, err := a.collection.Find(ctx, filter, opts...)
 	if err != nil {
 		return accounts, err
 	}
@@ -74,53 +121,181 @@ func (a *AccountCollection) FindAll(ctx context.Context, filter bson.M) ([]model
 	return accounts, nil
 }
 
-func (a *AccountCollection) Update(ctx context.Context, filter bson.M, update bson.M) error {
-	res, err := a.collection.UpdateOne(ctx, filter, update)
+func (a *AccountCollection) Count(ctx context.Context, query repositories.AccountQuery) (int64, error) {
+	return a.collection.CountDocuments(ctx, accountFilter(query), options.Count().SetCollation(accountCollation(query)))
+}
+
+func (a *AccountCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	res, err := a.collection.DeleteMany(ctx, filter)
 	if err != nil {
-		return err
-	}
-	if res.MatchedCount == 0 {
-		return errors.New("Không có tài liệu được update")
+		return 0, err
 	}
-	return nil
+	return res.DeletedCount, nil
 }
 
-func (a *AccountCollection) DeleteIndex(indexName string) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// Update cập nhật một tài khoản và ghi sự kiện thay đổi vào outbox trong cùng transaction
+func (a *AccountCollection) Update(ctx context.Context, query repositories.AccountQuery, update repositories.AccountUpdate) error {
+	var before, after models.Account
+	err := a.outbox.WithTransaction(ctx, func(ctx context.Context) error {
+		opts := options.FindOneAndUpdate().SetCollation(accountCollation(query)).SetProjection(withoutPassword)
+		err := a.collection.FindOneAndUpdate(ctx, accountFilter(query), accountUpdateDocument(update), opts).Decode(&before)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return repositories.ErrNoMatch
+		}
+		if err != nil {
+			return err
+		}
+		if err := a.collection.FindOne(ctx, bson.M{"_id": before.Id}, options.FindOne().SetProjection(withoutPassword)).Decode(&after); err != nil {
+			return err
+		}
+		event, err := models.NewAccountChangeEvent(before, after)
+		if err != nil {
+			return err
+		}
+		return a.outbox.Add(ctx, event)
+	})
+	a.invalidate(ctx, before, after)
+	return err
+}
 
-	cursor, _ := a.collection.Indexes().List(ctx)
+// Purge xóa vĩnh viễn tài khoản đã được nhận xử lý (StatusPurging) và ghi account.deleted (permanent) trong cùng transaction
+func (a *AccountCollection) Purge(ctx context.Context, account models.Account) (int64, error) {
+	filter := accountFilter(repositories.AccountQuery{
+		Ids:    []primitive.ObjectID{account.Id},
+		Status: models.StatusPurging,
+	})
+	data := models.AccountEventPayload(account)
+	data.Permanent = true
+	event, err := models.NewOutboxEvent(models.WebhookEventAccountDeleted, models.AggregateAccount, account.Id, data)
+	if err != nil {
+		return 0, err
+	}
+	var deleted int64
+	err = a.outbox.WithTransaction(ctx, func(ctx context.Context) error {
+		res, err := a.collection.DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+		deleted = res.DeletedCount
+		if deleted == 0 {
+			return nil
+		}
+		return a.outbox.Add(ctx, event)
+	})
+	a.invalidate(ctx, account)
+	return deleted, err
+}
 
-	for cursor.Next(ctx) {
-		var indexInfo map[string]interface{}
-		if err := cursor.Decode(&indexInfo); err != nil {
-			panic(err)
+// BulkUpdate thực hiện các cập nhật không theo thứ tự, trả về lỗi của từng thao tác theo vị trí trong items.
+// Sự kiện thay đổi được ghi vào outbox trong cùng transaction, vì vậy khi có transaction một thao tác lỗi
+// sẽ hủy cả lô và lỗi được trả về cho mọi vị trí.
+func (a *AccountCollection) BulkUpdate(ctx context.Context, items []repositories.AccountBulkUpdate) (map[int]error, error) {
+	writeErrors := map[int]error{}
+	if len(items) == 0 {
+		return writeErrors, nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(items))
+	writeModels := make([]mongo.WriteModel, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.Id)
+		filter := bson.M{"_id": item.Id}
+		if item.Status != "" {
+			filter = bson.M{"$and": []bson.M{filter, models.StatusFilter(item.Status)}}
+		}
+		writeModels = append(writeModels, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(accountUpdateDocument(item.Update)))
+	}
+	before := map[primitive.ObjectID]models.Account{}
+	err := a.outbox.WithTransaction(ctx, func(ctx context.Context) error {
+		writeErrors = map[int]error{}
+		accounts, err := a.find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(withoutPassword))
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			before[account.Id] = account
+		}
+		// Trong transaction before chính là dữ liệu mà BulkWrite sẽ thấy, filter theo status
+		// vẫn bảo vệ thao tác ghi khi MongoDB không hỗ trợ transaction
+		for i, item := range items {
+			if account, ok := before[item.Id]; ok && item.Status != "" && !account.HasStoredStatus(item.Status) {
+				writeErrors[i] = repositories.ErrNoMatch
+			}
+		}
+		_, err = a.collection.BulkWrite(ctx, writeModels, options.BulkWrite().SetOrdered(false))
+		var bulkErr mongo.BulkWriteException
+		if errors.As(err, &bulkErr) {
+			if mongo.SessionFromContext(ctx) != nil || bulkErr.WriteConcernError != nil {
+				return err
+			}
+			for _, writeErr := range bulkErr.WriteErrors {
+				writeErrors[writeErr.Index] = errors.New(writeErr.Message)
+			}
+		} else if err != nil {
+			return err
 		}
 
-		// So sánh tên index
-		if name, ok := indexInfo["name"].(string); ok && name == indexName {
-			fmt.Println("Delete successful")
-			_, err := a.collection.Indexes().DropOne(ctx, indexName) // tên mặc định
+		succeededIds := []primitive.ObjectID{}
+		for i, id := range ids {
+			if _, failed := writeErrors[i]; !failed {
+				succeededIds = append(succeededIds, id)
+			}
+		}
+		after, err := a.find(ctx, bson.M{"_id": bson.M{"$in": succeededIds}}, options.Find().SetProjection(withoutPassword))
+		if err != nil {
+			return err
+		}
+		for _, account := range after {
+			event, err := models.NewAccountChangeEvent(before[account.Id], account)
 			if err != nil {
-				return
+				return err
+			}
+			if err := a.outbox.Add(ctx, event); err != nil {
+				return err
 			}
-			return
 		}
+		return nil
+	})
+	changed := []models.Account{}
+	for _, account := range before {
+		changed = append(changed, account)
 	}
+	a.invalidate(ctx, changed...)
+	return writeErrors, err
 }
 
-func (a *AccountCollection) UpdateIndex(ttl int, indexName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	indexModel := mongo.IndexModel{
-		Keys:    bson.M{indexName: 1},
-		Options: options.Index().SetExpireAfterSeconds(int32(ttl*24*60) * 60), // TTL mới
-	}
+// Watch mở change stream trên collection accounts, token rỗng thì bắt đầu từ thời điểm hiện tại
+func (a *AccountCollection) Watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	return watchCollection(ctx, a.collection, resumeToken)
+}
 
-	_, err := a.collection.Indexes().CreateOne(ctx, indexModel)
+// EnsureIndexes tạo index unique cho email (email_ci không phân biệt hoa thường, tạo thất bại nếu
+// dữ liệu cũ có hai email chỉ khác hoa thường và cần gộp tay trước), text index dùng cho AccountQuery.Text và index image_url cho job dọn avatar
+func (a *AccountCollection) EnsureIndexes(ctx context.Context) error {
+	_, err := a.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_ci").SetUnique(true).SetCollation(emailCollation),
+		},
+		{
+			Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "email", Value: "text"}},
+			Options: options.Index().SetDefaultLanguage("none"),
+		},
+		{
+			// Job dọn avatar tra cứu file còn được tham chiếu theo image_url
+			Keys:    bson.D{{Key: "image_url", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
 	return err
+}
 
+// Indexes trả về IndexManager để đọc/thay đổi index của collection accounts
+func (a *AccountCollection) Indexes() *IndexManager {
+	return NewIndexManager(a.collection)
 }
 
 func (a *AccountCollection) SearchByText(keyword string) ([]models.Account, error) {
@@ -135,6 +310,7 @@ func (a *AccountCollection) SearchByText(keyword string) ([]models.Account, erro
 				{"$search", keyword},
 			}},
 		}}},
+		{{"$project", withoutPassword}},
 	}
 
 	cusor, err := a.collection.Aggregate(ctx, pipeline)
@@ -0,0 +1,212 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	accountCacheIdPrefix    = "account:id:"
+	accountCacheEmailPrefix = "account:email:"
+	// accountCacheMissing đánh dấu tài khoản không tồn tại (negative cache)
+	accountCacheMissing = "-"
+	// accountCacheTombstone được Invalidate ghi thay cho việc xóa khóa, trong thời gian tombstone còn hạn
+	// các lần đọc DB bắt đầu trước khi tài khoản đổi không ghi đè được dữ liệu cũ vào cache
+	accountCacheTombstone = "~"
+	// accountCacheTombstoneTTL phải dài hơn thời gian một lần đọc DB (timeout request 10 giây)
+	accountCacheTombstoneTTL = 15 * time.Second
+)
+
+// AccountCache cache tài khoản trên Redis theo id và email. Khóa email chỉ trỏ tới id
+// nên khi tài khoản đổi chỉ cần xóa khóa id, khóa email cũ sẽ không còn khớp và bị bỏ qua.
+// Mật khẩu không được cache, luồng cần mật khẩu đọc thẳng DB (AccountQuery.WithPassword).
+//
+// Kết quả đọc DB chỉ được ghi khi khóa chưa có (SET NX), Invalidate ghi tombstone thay vì xóa khóa
+// nên lần đọc DB bắt đầu trước một thay đổi không thể ghi lại dữ liệu cũ sau khi cache đã bị xóa.
+type AccountCache struct {
+	client      *redis.Client
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// group gom các lần đọc Mongo cùng một khóa khi cache vừa hết hạn
+	group singleflight.Group
+
+	hits          atomic.Int64
+	negativeHits  atomic.Int64
+	misses        atomic.Int64
+	errors        atomic.Int64
+	invalidations atomic.Int64
+}
+
+type AccountCacheStats struct {
+	Hits          int64   `json:"hits"`
+	NegativeHits  int64   `json:"negative_hits"`
+	Misses        int64   `json:"misses"`
+	Errors        int64   `json:"errors"`
+	Invalidations int64   `json:"invalidations"`
+	HitRate       float64 `json:"hit_rate"`
+}
+
+func NewAccountCache(client *redis.Client, ttl time.Duration, negativeTTL time.Duration) *AccountCache {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if negativeTTL <= 0 {
+		negativeTTL = 30 * time.Second
+	}
+	return &AccountCache{client: client, ttl: ttl, negativeTTL: negativeTTL}
+}
+
+func (c *AccountCache) Stats() AccountCacheStats {
+	stats := AccountCacheStats{
+		Hits:          c.hits.Load(),
+		NegativeHits:  c.negativeHits.Load(),
+		Misses:        c.misses.Load(),
+		Errors:        c.errors.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+	if total := stats.Hits + stats.NegativeHits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.NegativeHits) / float64(total)
+	}
+	return stats
+}
+
+// GetById đọc tài khoản từ cache, nếu không có thì gọi load và lưu kết quả (kể cả không tìm thấy)
+func (c *AccountCache) GetById(ctx context.Context, id primitive.ObjectID, load func(ctx context.Context) (models.Account, error)) (models.Account, error) {
+	account, found, err := c.readAccount(ctx, id)
+	if err == nil && found {
+		return account, nil
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return account, err
+	}
+	c.misses.Add(1)
+	return c.load(ctx, accountCacheIdPrefix+id.Hex(), load)
+}
+
+// GetByEmail dùng khóa email để lấy id rồi đọc tài khoản theo id
+func (c *AccountCache) GetByEmail(ctx context.Context, email string, load func(ctx context.Context) (models.Account, error)) (models.Account, error) {
+	value, err := c.client.Get(ctx, emailCacheKey(email)).Result()
+	switch {
+	case err == nil && value == accountCacheMissing:
+		c.negativeHits.Add(1)
+		return models.Account{}, mongo.ErrNoDocuments
+	case err == nil && value == accountCacheTombstone:
+	case err == nil:
+		if id, err := primitive.ObjectIDFromHex(value); err == nil {
+			account, found, err := c.readAccount(ctx, id)
+			if err == nil && found && strings.EqualFold(account.Email, email) {
+				return account, nil
+			}
+		}
+	case !errors.Is(err, redis.Nil):
+		c.fail("Không thể đọc cache tài khoản", err)
+	}
+	c.misses.Add(1)
+	return c.load(ctx, emailCacheKey(email), load)
+}
+
+// emailCacheKey không phân biệt hoa thường giống tìm kiếm theo email trong MongoDB
+func emailCacheKey(email string) string {
+	return accountCacheEmailPrefix + strings.ToLower(email)
+}
+
+// readAccount trả về found = true khi có tài khoản trong cache, ErrNoDocuments khi cache ghi nhận không tồn tại
+func (c *AccountCache) readAccount(ctx context.Context, id primitive.ObjectID) (models.Account, bool, error) {
+	var account models.Account
+	value, err := c.client.Get(ctx, accountCacheIdPrefix+id.Hex()).Bytes()
+	if errors.Is(err, redis.Nil) || err == nil && string(value) == accountCacheTombstone {
+		return account, false, nil
+	}
+	if err != nil {
+		c.fail("Không thể đọc cache tài khoản", err)
+		return account, false, err
+	}
+	if string(value) == accountCacheMissing {
+		c.negativeHits.Add(1)
+		return account, false, mongo.ErrNoDocuments
+	}
+	if err := bson.Unmarshal(value, &account); err != nil {
+		c.fail("Dữ liệu cache tài khoản không hợp lệ", err)
+		return account, false, err
+	}
+	c.hits.Add(1)
+	return account, true, nil
+}
+
+func (c *AccountCache) load(ctx context.Context, key string, load func(ctx context.Context) (models.Account, error)) (models.Account, error) {
+	result, err, _ := c.group.Do(key, func() (interface{}, error) {
+		account, err := load(ctx)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.add(ctx, key, accountCacheMissing, c.negativeTTL)
+			return account, err
+		}
+		if err != nil {
+			return account, err
+		}
+		c.store(ctx, account)
+		return account, nil
+	})
+	account, _ := result.(models.Account)
+	return account, err
+}
+
+func (c *AccountCache) store(ctx context.Context, account models.Account) {
+	account.Password = ""
+	value, err := bson.Marshal(account)
+	if err != nil {
+		c.fail("Không thể ghi cache tài khoản", err)
+		return
+	}
+	c.add(ctx, accountCacheIdPrefix+account.Id.Hex(), value, c.ttl)
+	if account.Email != "" {
+		c.add(ctx, emailCacheKey(account.Email), account.Id.Hex(), c.ttl)
+	}
+}
+
+// add chỉ ghi khi khóa chưa có, khóa đang là tombstone nghĩa là tài khoản vừa đổi sau khi bắt đầu đọc DB
+func (c *AccountCache) add(ctx context.Context, key string, value interface{}, ttl time.Duration) {
+	if err := c.client.SetNX(ctx, key, value, ttl).Err(); err != nil {
+		c.fail("Không thể ghi cache tài khoản", err)
+	}
+}
+
+// Invalidate xóa cache của các tài khoản vừa thay đổi, gồm cả khóa email (xóa luôn negative cache khi tạo mới),
+// bằng cách ghi tombstone ngắn hạn lên các khóa
+func (c *AccountCache) Invalidate(ctx context.Context, accounts ...models.Account) {
+	keys := []string{}
+	for _, account := range accounts {
+		if !account.Id.IsZero() {
+			keys = append(keys, accountCacheIdPrefix+account.Id.Hex())
+		}
+		if account.Email != "" {
+			keys = append(keys, emailCacheKey(account.Email))
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	c.invalidations.Add(int64(len(accounts)))
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		pipe.Set(ctx, key, accountCacheTombstone, accountCacheTombstoneTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.fail("Không thể xóa cache tài khoản", err)
+	}
+}
+
+func (c *AccountCache) fail(message string, err error) {
+	c.errors.Add(1)
+	log.Println(message, err)
+}
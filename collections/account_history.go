@@ -0,0 +1,63 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AccountHistoryCollection struct {
+	collection *mongo.Collection
+}
+
+func NewAccountHistoryCollection(collection *mongo.Collection) *AccountHistoryCollection {
+	return &AccountHistoryCollection{collection}
+}
+
+func (h *AccountHistoryCollection) Create(ctx context.Context, history models.AccountHistory) error {
+	_, err := h.collection.InsertOne(ctx, history)
+	return err
+}
+
+func (h *AccountHistoryCollection) FindOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) (models.AccountHistory, error) {
+	var history models.AccountHistory
+	err := h.collection.FindOne(ctx, filter, opts...).Decode(&history)
+	return history, err
+}
+
+func (h *AccountHistoryCollection) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.AccountHistory, error) {
+	histories := []models.AccountHistory{}
+	cursor, err := h.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &histories); err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
+
+func (h *AccountHistoryCollection) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return h.collection.CountDocuments(ctx, filter)
+}
+
+func (h *AccountHistoryCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	res, err := h.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// EnsureIndexes tạo unique index (account_id, version) để hai thay đổi đồng thời không trùng version
+func (h *AccountHistoryCollection) EnsureIndexes(ctx context.Context) error {
+	_, err := h.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "account_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
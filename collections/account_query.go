@@ -0,0 +1,180 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// accountFilter chuyển AccountQuery thành filter của MongoDB
+func accountFilter(query repositories.AccountQuery) bson.M {
+	conditions := []bson.M{}
+	if query.Ids != nil {
+		conditions = append(conditions, bson.M{"_id": bson.M{"$in": query.Ids}})
+	}
+	if !query.ExcludeId.IsZero() {
+		conditions = append(conditions, bson.M{"_id": bson.M{"$ne": query.ExcludeId}})
+	}
+	if query.Email != "" {
+		conditions = append(conditions, bson.M{"email": query.Email})
+	}
+	if query.Emails != nil {
+		conditions = append(conditions, bson.M{"email": bson.M{"$in": query.Emails}})
+	}
+	if query.ImageUrls != nil {
+		conditions = append(conditions, bson.M{"image_url": bson.M{"$in": query.ImageUrls}})
+	}
+	if query.Keyword != "" {
+		keyword := regexp.QuoteMeta(query.Keyword)
+		conditions = append(conditions, bson.M{
+			"$or": []bson.M{
+				{"name": bson.M{"$regex": keyword, "$options": "i"}},
+				{"email": bson.M{"$regex": keyword, "$options": "i"}},
+			},
+		})
+	}
+	if words := strings.Fields(query.Text); len(words) > 0 {
+		// Mỗi từ được đặt trong ngoặc kép để $text yêu cầu đủ mọi từ thay vì chỉ một từ bất kỳ
+		conditions = append(conditions, bson.M{"$text": bson.M{"$search": `"` + strings.Join(words, `" "`) + `"`}})
+	}
+	if query.Status != "" {
+		conditions = append(conditions, models.StatusFilter(query.Status))
+	}
+	if query.NotDeleted {
+		conditions = append(conditions, bson.M{"deleted_at": nil})
+	}
+	if !query.DeletedAtOrBefore.IsZero() {
+		conditions = append(conditions, bson.M{"deleted_at": bson.M{"$lte": query.DeletedAtOrBefore}})
+	}
+	switch len(conditions) {
+	case 0:
+		return bson.M{}
+	case 1:
+		return conditions[0]
+	default:
+		return bson.M{"$and": conditions}
+	}
+}
+
+func accountFindOptions(query repositories.AccountQuery) *options.FindOptions {
+	opts := options.Find().SetCollation(accountCollation(query)).SetProjection(accountProjection(query))
+	switch query.Sort {
+	case repositories.SortDeletedAtAsc:
+		opts.SetSort(bson.D{{Key: "deleted_at", Value: 1}})
+	case repositories.SortDeletedAtDesc:
+		opts.SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	}
+	if query.Skip > 0 {
+		opts.SetSkip(int64(query.Skip))
+	}
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit))
+	}
+	return opts
+}
+
+// withoutPassword là projection mặc định khi đọc tài khoản, hash mật khẩu không rời khỏi DB
+// trừ khi query yêu cầu (AccountQuery.WithPassword)
+var withoutPassword = bson.M{"password": 0}
+
+func accountProjection(query repositories.AccountQuery) interface{} {
+	if query.WithPassword {
+		return nil
+	}
+	return withoutPassword
+}
+
+func accountFindOneOptions(query repositories.AccountQuery) *options.FindOneOptions {
+	return options.FindOne().SetCollation(accountCollation(query)).SetProjection(accountProjection(query))
+}
+
+// emailCollation so sánh không phân biệt hoa thường, phải giống collation của unique index email_ci
+var emailCollation = &options.Collation{Locale: "en", Strength: 2}
+
+// accountCollation trả về emailCollation khi query lọc theo email để tìm không phân biệt hoa thường
+// bằng index email_ci, các query khác giữ cách so sánh mặc định ($text không dùng được với collation)
+func accountCollation(query repositories.AccountQuery) *options.Collation {
+	if (query.Email != "" || query.Emails != nil) && query.Text == "" {
+		return emailCollation
+	}
+	return nil
+}
+
+// isEmailLookup cho biết query chỉ tìm theo đúng một email, trường hợp duy nhất được đọc qua cache
+func isEmailLookup(query repositories.AccountQuery) bool {
+	return query.Email != "" && !query.WithPassword && query.Ids == nil && query.ExcludeId.IsZero() && query.Emails == nil && query.ImageUrls == nil &&
+		query.Keyword == "" && query.Text == "" && query.Status == "" && !query.NotDeleted && query.DeletedAtOrBefore.IsZero()
+}
+
+// accountUpdateDocument chuyển AccountUpdate thành update của MongoDB, giá trị rỗng được $unset
+func accountUpdateDocument(update repositories.AccountUpdate) bson.M {
+	set := bson.M{}
+	unset := bson.M{}
+	setString(set, unset, "name", update.Name)
+	setString(set, unset, "email", update.Email)
+	setString(set, unset, "password", update.Password)
+	setString(set, unset, "phone", update.Phone)
+	setTime(set, unset, "dob", update.Dob)
+	setString(set, unset, "image_url", update.ImageUrl)
+	setId(set, unset, "managed_by", update.ManagedBy)
+	setTime(set, unset, "updated_at", update.UpdatedAt)
+	setId(set, unset, "updated_by", update.UpdatedBy)
+	setTime(set, unset, "deleted_at", update.DeletedAt)
+	setId(set, unset, "deleted_by", update.DeletedBy)
+	setString(set, unset, "status", update.Status)
+	setString(set, unset, "status_reason", update.StatusReason)
+	setTime(set, unset, "suspended_until", update.SuspendedUntil)
+	setTime(set, unset, "status_changed_at", update.StatusChangedAt)
+	setId(set, unset, "status_changed_by", update.StatusChangedBy)
+
+	document := bson.M{}
+	if len(set) > 0 {
+		document["$set"] = set
+	}
+	if len(unset) > 0 {
+		document["$unset"] = unset
+	}
+	if update.AppendStatusHistory != nil {
+		document["$push"] = bson.M{"status_history": *update.AppendStatusHistory}
+	}
+	return document
+}
+
+func setString(set bson.M, unset bson.M, field string, value *string) {
+	if value == nil {
+		return
+	}
+	if *value == "" {
+		unset[field] = ""
+		return
+	}
+	set[field] = *value
+}
+
+func setTime(set bson.M, unset bson.M, field string, value *time.Time) {
+	if value == nil {
+		return
+	}
+	if value.IsZero() {
+		unset[field] = ""
+		return
+	}
+	set[field] = *value
+}
+
+func setId(set bson.M, unset bson.M, field string, value *primitive.ObjectID) {
+	if value == nil {
+		return
+	}
+	if value.IsZero() {
+		unset[field] = ""
+		return
+	}
+	set[field] = *value
+}
@@ -0,0 +1,43 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditCheckpointCollection struct {
+	collection *mongo.Collection
+}
+
+func NewAuditCheckpointCollection(collection *mongo.Collection) *AuditCheckpointCollection {
+	return &AuditCheckpointCollection{collection}
+}
+
+func (a *AuditCheckpointCollection) Create(ctx context.Context, checkpoint models.AuditCheckpoint) error {
+	_, err := a.collection.InsertOne(ctx, checkpoint)
+	return err
+}
+
+func (a *AuditCheckpointCollection) Last(ctx context.Context) (models.AuditCheckpoint, error) {
+	var checkpoint models.AuditCheckpoint
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	err := a.collection.FindOne(ctx, bson.M{}, opts).Decode(&checkpoint)
+	return checkpoint, err
+}
+
+func (a *AuditCheckpointCollection) FindAll(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.AuditCheckpoint, error) {
+	checkpoints := []models.AuditCheckpoint{}
+	cursor, err := a.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &checkpoints); err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
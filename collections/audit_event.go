@@ -0,0 +1,80 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditEventCollection chỉ cho phép thêm và đọc, không có hàm sửa/xóa
+type AuditEventCollection struct {
+	collection *mongo.Collection
+}
+
+func NewAuditEventCollection(collection *mongo.Collection) *AuditEventCollection {
+	return &AuditEventCollection{collection}
+}
+
+func (a *AuditEventCollection) Create(ctx context.Context, event models.AuditEvent) error {
+	_, err := a.collection.InsertOne(ctx, event)
+	return err
+}
+
+func (a *AuditEventCollection) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.AuditEvent, error) {
+	events := []models.AuditEvent{}
+	cursor, err := a.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Last trả về sự kiện cuối cùng của chuỗi hash
+func (a *AuditEventCollection) Last(ctx context.Context) (models.AuditEvent, error) {
+	var event models.AuditEvent
+	opts := options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}})
+	err := a.collection.FindOne(ctx, bson.M{"sequence": bson.M{"$gt": 0}}, opts).Decode(&event)
+	return event, err
+}
+
+func (a *AuditEventCollection) FindBySequence(ctx context.Context, sequence int64) (models.AuditEvent, error) {
+	var event models.AuditEvent
+	err := a.collection.FindOne(ctx, bson.M{"sequence": sequence}).Decode(&event)
+	return event, err
+}
+
+// Cursor duyệt các sự kiện theo thứ tự, dùng khi kiểm tra chuỗi hash mà không phải nạp hết vào bộ nhớ
+func (a *AuditEventCollection) Cursor(ctx context.Context, filter bson.M, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return a.collection.Find(ctx, filter, opts...)
+}
+
+func (a *AuditEventCollection) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return a.collection.CountDocuments(ctx, filter)
+}
+
+// Indexes trả về IndexManager để đọc TTL index của chính sách lưu trữ
+func (a *AuditEventCollection) Indexes() *IndexManager {
+	return NewIndexManager(a.collection)
+}
+
+// EnsureIndexes tạo các index phục vụ bộ lọc của API tra cứu audit log
+func (a *AuditEventCollection) EnsureIndexes(ctx context.Context) error {
+	_, err := a.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor.id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "target.id", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Unique để hai server không thể nối hai sự kiện vào cùng một vị trí của chuỗi
+		{
+			Keys:    bson.D{{Key: "sequence", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"sequence": bson.M{"$gt": 0}}),
+		},
+	})
+	return err
+}
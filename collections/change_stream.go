@@ -0,0 +1,58 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// watchCollection mở change stream cho các thao tác ghi trên collection,
+// fullDocument được đọc lại sau update để handler có trạng thái mới nhất.
+func watchCollection(ctx context.Context, collection *mongo.Collection, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": []string{"insert", "update", "replace", "delete"}}}}},
+	}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	if len(resumeToken) > 0 {
+		// StartAfter tiếp tục được cả sau sự kiện invalidate, ResumeAfter thì không
+		opts.SetStartAfter(resumeToken)
+	}
+	return collection.Watch(ctx, pipeline, opts)
+}
+
+// ChangeStreamTokenCollection lưu resume token của từng watcher để chạy tiếp sau khi khởi động lại
+type ChangeStreamTokenCollection struct {
+	collection *mongo.Collection
+}
+
+func NewChangeStreamTokenCollection(collection *mongo.Collection) *ChangeStreamTokenCollection {
+	return &ChangeStreamTokenCollection{collection}
+}
+
+// Get trả về token rỗng nếu watcher chưa từng chạy
+func (c *ChangeStreamTokenCollection) Get(ctx context.Context, name string) (bson.Raw, error) {
+	var document struct {
+		Token bson.Raw `bson:"token"`
+	}
+	err := c.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	return document.Token, err
+}
+
+func (c *ChangeStreamTokenCollection) Save(ctx context.Context, name string, token bson.Raw) error {
+	_, err := c.collection.UpdateOne(ctx, bson.M{"_id": name}, bson.M{
+		"$set": bson.M{"token": token, "updated_at": time.Now()},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (c *ChangeStreamTokenCollection) Delete(ctx context.Context, name string) error {
+	_, err := c.collection.DeleteOne(ctx, bson.M{"_id": name})
+	return err
+}
@@ -0,0 +1,53 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type EmailChangeCollection struct {
+	collection *mongo.Collection
+}
+
+func NewEmailChangeCollection(collection *mongo.Collection) *EmailChangeCollection {
+	return &EmailChangeCollection{collection}
+}
+
+func (e *EmailChangeCollection) Create(ctx context.Context, emailChange models.EmailChange) error {
+	_, err := e.collection.InsertOne(ctx, emailChange)
+	return err
+}
+
+func (e *EmailChangeCollection) FindOne(ctx context.Context, filter bson.M) (models.EmailChange, error) {
+	var emailChange models.EmailChange
+	err := e.collection.FindOne(ctx, filter).Decode(&emailChange)
+	return emailChange, err
+}
+
+func (e *EmailChangeCollection) Update(ctx context.Context, filter bson.M, update bson.M) error {
+	res, err := e.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("Không có yêu cầu đổi email được update")
+	}
+	return nil
+}
+
+func (e *EmailChangeCollection) UpdateMany(ctx context.Context, filter bson.M, update bson.M) error {
+	_, err := e.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+func (e *EmailChangeCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	res, err := e.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,67 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ImportJobCollection struct {
+	collection *mongo.Collection
+}
+
+func NewImportJobCollection(collection *mongo.Collection) *ImportJobCollection {
+	return &ImportJobCollection{collection}
+}
+
+func (i *ImportJobCollection) Create(ctx context.Context, job models.ImportJob) (primitive.ObjectID, error) {
+	if job.Id.IsZero() {
+		job.Id = primitive.NewObjectID()
+	}
+	_, err := i.collection.InsertOne(ctx, job)
+	return job.Id, err
+}
+
+func (i *ImportJobCollection) GetById(ctx context.Context, objectId primitive.ObjectID) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := i.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&job)
+	return job, err
+}
+
+// FailStale chuyển các job running không cập nhật tiến độ từ trước before sang failed, trả về số job bị đổi.
+// Job tạo trước khi có updated_at được xét theo created_at.
+func (i *ImportJobCollection) FailStale(ctx context.Context, before time.Time, message string) (int64, error) {
+	res, err := i.collection.UpdateMany(ctx, bson.M{
+		"status": models.ImportJobRunning,
+		"$or": bson.A{
+			bson.M{"updated_at": bson.M{"$lt": before}},
+			bson.M{"updated_at": bson.M{"$exists": false}, "created_at": bson.M{"$lt": before}},
+		},
+	}, bson.M{
+		"$set": bson.M{
+			"status":      models.ImportJobFailed,
+			"message":     message,
+			"finished_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (i *ImportJobCollection) Update(ctx context.Context, objectId primitive.ObjectID, update bson.M) error {
+	res, err := i.collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("Không có job import được update")
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package collections
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IndexInfo struct {
+	Name                    string `bson:"name"`
+	Keys                    bson.D `bson:"key"`
+	ExpireAfterSeconds      *int64 `bson:"expireAfterSeconds,omitempty"`
+	PartialFilterExpression bson.M `bson:"partialFilterExpression,omitempty"`
+}
+
+// IndexManager đọc và thay đổi index của một collection thông qua Indexes API và lệnh collMod
+type IndexManager struct {
+	collection *mongo.Collection
+}
+
+func NewIndexManager(collection *mongo.Collection) *IndexManager {
+	return &IndexManager{collection}
+}
+
+func (m *IndexManager) CollectionName() string {
+	return m.collection.Name()
+}
+
+// FindIndex trả về nil nếu collection không có index tên name
+func (m *IndexManager) FindIndex(ctx context.Context, name string) (*IndexInfo, error) {
+	cursor, err := m.collection.Indexes().List(ctx)
+	if err != nil {
+		// Collection chưa tồn tại thì cũng chưa có index
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var raw bson.M
+		if err := cursor.Decode(&raw); err != nil {
+			return nil, err
+		}
+		if raw["name"] != name {
+			continue
+		}
+		info := &IndexInfo{Name: name}
+		if keys, ok := raw["key"].(bson.D); ok {
+			info.Keys = keys
+		}
+		if partial, ok := raw["partialFilterExpression"].(bson.M); ok {
+			info.PartialFilterExpression = partial
+		}
+		// expireAfterSeconds có thể được lưu dưới dạng int32, int64 hoặc double
+		switch ttl := raw["expireAfterSeconds"].(type) {
+		case int32:
+			seconds := int64(ttl)
+			info.ExpireAfterSeconds = &seconds
+		case int64:
+			info.ExpireAfterSeconds = &ttl
+		case float64:
+			seconds := int64(ttl)
+			info.ExpireAfterSeconds = &seconds
+		}
+		return info, nil
+	}
+	return nil, cursor.Err()
+}
+
+func (m *IndexManager) CreateTTLIndex(ctx context.Context, name string, field string, seconds int64, partialFilter bson.M) error {
+	opts := options.Index().SetName(name).SetExpireAfterSeconds(int32(seconds))
+	if partialFilter != nil {
+		opts.SetPartialFilterExpression(partialFilter)
+	}
+	_, err := m.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: opts,
+	})
+	return err
+}
+
+// SetTTL đổi expireAfterSeconds của index đang có bằng collMod, không cần xóa và tạo lại index
+func (m *IndexManager) SetTTL(ctx context.Context, name string, seconds int64) error {
+	command := bson.D{
+		{Key: "collMod", Value: m.collection.Name()},
+		{Key: "index", Value: bson.D{
+			{Key: "name", Value: name},
+			{Key: "expireAfterSeconds", Value: seconds},
+		}},
+	}
+	return m.collection.Database().RunCommand(ctx, command).Err()
+}
+
+func (m *IndexManager) DropIndex(ctx context.Context, name string) error {
+	_, err := m.collection.Indexes().DropOne(ctx, name)
+	return err
+}
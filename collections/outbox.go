@@ -0,0 +1,140 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// outboxRetentionSeconds là thời gian giữ sự kiện đã phát trước khi MongoDB tự xóa
+const outboxRetentionSeconds = 7 * 24 * 60 * 60
+
+type OutboxCollection struct {
+	collection *mongo.Collection
+	// standalone được bật khi MongoDB không hỗ trợ transaction (không chạy replica set)
+	standalone atomic.Bool
+}
+
+func NewOutboxCollection(collection *mongo.Collection) *OutboxCollection {
+	return &OutboxCollection{collection: collection}
+}
+
+// WithTransaction chạy fn trong một transaction, mọi thao tác dùng ctx được truyền vào fn
+// sẽ cùng commit hoặc cùng rollback. MongoDB standalone không có transaction nên fn được
+// chạy tuần tự, khi đó sự kiện có thể mất nếu tiến trình dừng giữa hai lần ghi.
+func (o *OutboxCollection) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if o.standalone.Load() {
+		return fn(ctx)
+	}
+	session, err := o.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionCtx)
+	})
+	if isTransactionUnsupported(err) {
+		if o.standalone.CompareAndSwap(false, true) {
+			log.Println("MongoDB không hỗ trợ transaction, outbox được ghi ngoài transaction:", err)
+		}
+		return fn(ctx)
+	}
+	return err
+}
+
+func isTransactionUnsupported(err error) bool {
+	var cmdErr mongo.CommandError
+	if !errors.As(err, &cmdErr) {
+		return false
+	}
+	return cmdErr.Code == 20 || strings.Contains(cmdErr.Message, "Transaction numbers are only allowed")
+}
+
+// Add ghi sự kiện vào outbox, gọi trong WithTransaction để ghi cùng thay đổi dữ liệu
+func (o *OutboxCollection) Add(ctx context.Context, event models.OutboxEvent) error {
+	if event.Id.IsZero() {
+		event.Id = primitive.NewObjectID()
+	}
+	_, err := o.collection.InsertOne(ctx, event)
+	return err
+}
+
+var _ repositories.OutboxRepository = (*OutboxCollection)(nil)
+
+func (o *OutboxCollection) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := o.collection.FindOneAndUpdate(ctx, bson.M{
+		"status":          models.OutboxPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}, bson.M{
+		"$set": bson.M{"next_attempt_at": now.Add(lease)},
+	}, opts).Decode(&event)
+	return event, err
+}
+
+func (o *OutboxCollection) SaveAttempt(ctx context.Context, event models.OutboxEvent) error {
+	set := bson.M{
+		"status":          event.Status,
+		"attempts":        event.Attempts,
+		"delivered_sinks": event.DeliveredSinks,
+	}
+	unset := bson.M{}
+	optional := func(field string, value interface{}, empty bool) {
+		if empty {
+			unset[field] = ""
+		} else {
+			set[field] = value
+		}
+	}
+	optional("last_error", event.LastError, event.LastError == "")
+	optional("next_attempt_at", event.NextAttemptAt, event.NextAttemptAt.IsZero())
+	optional("published_at", event.PublishedAt, event.PublishedAt.IsZero())
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	res, err := o.collection.UpdateOne(ctx, bson.M{"_id": event.Id}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("Không có sự kiện outbox được update")
+	}
+	return nil
+}
+
+// DeletePublished xóa sự kiện đã phát, TTL index trên published_at cũng tự xóa sau outboxRetentionSeconds
+func (o *OutboxCollection) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := o.collection.DeleteMany(ctx, bson.M{
+		"status":       models.OutboxPublished,
+		"published_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (o *OutboxCollection) EnsureIndexes(ctx context.Context) error {
+	_, err := o.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "published_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(outboxRetentionSeconds)},
+	})
+	return err
+}
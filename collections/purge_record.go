@@ -0,0 +1,21 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type PurgeRecordCollection struct {
+	collection *mongo.Collection
+}
+
+func NewPurgeRecordCollection(collection *mongo.Collection) *PurgeRecordCollection {
+	return &PurgeRecordCollection{collection}
+}
+
+func (p *PurgeRecordCollection) Create(ctx context.Context, record models.PurgeRecord) error {
+	_, err := p.collection.InsertOne(ctx, record)
+	return err
+}
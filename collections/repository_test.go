@@ -0,0 +1,62 @@
+package collections_test
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/repositories"
+	"UserManagementVer/repositories/repotest"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestAccountCollection(t *testing.T) {
+	repotest.RunAccountRepository(t, func(t *testing.T) repositories.AccountRepository {
+		db := newDatabase(t)
+		accounts := collections.NewAccountCollection(db.Collection("accounts"), collections.NewOutboxCollection(db.Collection("outbox_events")), nil)
+		ensureIndexes(t, accounts.EnsureIndexes)
+		return accounts
+	})
+}
+
+func TestSessionCollection(t *testing.T) {
+	repotest.RunSessionRepository(t, func(t *testing.T) repositories.SessionRepository {
+		db := newDatabase(t)
+		sessions := collections.NewSessionCollection(db.Collection("sessions"), collections.NewOutboxCollection(db.Collection("outbox_events")))
+		ensureIndexes(t, sessions.EnsureIndexes)
+		return sessions
+	})
+}
+
+// newDatabase tạo database riêng cho từng test, test bị bỏ qua nếu không có MONGO_TEST_URI.
+// Outbox ghi trong transaction nên MongoDB phải chạy replica set.
+func newDatabase(t *testing.T) *mongo.Database {
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI chưa được đặt")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("Không thể kết nối MongoDB: %v", err)
+	}
+	db := client.Database("test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func ensureIndexes(t *testing.T, ensure func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ensure(ctx); err != nil {
+		t.Fatalf("Không thể tạo index: %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package collections
 
 import (
 	"UserManagementVer/models"
+	"UserManagementVer/repositories"
 	"context"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -11,25 +12,52 @@ import (
 
 type SessionCollection struct {
 	collection *mongo.Collection
+	outbox     *OutboxCollection
 }
 
-func NewSessionCollection(collection *mongo.Collection) *SessionCollection {
-	return &SessionCollection{collection}
+var _ repositories.SessionRepository = (*SessionCollection)(nil)
+
+func NewSessionCollection(collection *mongo.Collection, outbox *OutboxCollection) *SessionCollection {
+	return &SessionCollection{collection, outbox}
 }
 
-func (sessionCollection *SessionCollection) FindOne(ctx context.Context, filter bson.M) (models.Session, error) {
+// sessionFilter chuyển SessionQuery thành filter của MongoDB
+func sessionFilter(query repositories.SessionQuery) bson.M {
+	filter := bson.M{}
+	if !query.Id.IsZero() {
+		filter["_id"] = query.Id
+	}
+	if !query.UserId.IsZero() {
+		filter["user_id"] = query.UserId
+	}
+	if query.DeviceId != "" {
+		filter["device_id"] = query.DeviceId
+	} else if query.ExcludeDeviceId != "" {
+		filter["device_id"] = bson.M{"$ne": query.ExcludeDeviceId}
+	}
+	if query.ApprovedToken != "" {
+		filter["approved_token"] = query.ApprovedToken
+	}
+	if query.TrustedOnly {
+		filter["trusted_device"] = true
+	}
+	return filter
+}
+
+func (sessionCollection *SessionCollection) FindOne(ctx context.Context, query repositories.SessionQuery) (models.Session, error) {
 	var session models.Session
-	err := sessionCollection.collection.FindOne(ctx, filter).Decode(&session)
+	err := sessionCollection.collection.FindOne(ctx, sessionFilter(query)).Decode(&session)
 	if err != nil {
 		return session, err
 	}
 	return session, nil
 }
 
-func (sessionCollection *SessionCollection) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Session, error) {
+func (sessionCollection *SessionCollection) Find(ctx context.Context, query repositories.SessionQuery) ([]models.Session, error) {
 	var sessions []models.Session
 
-	cursor, err := sessionCollection.collection.Find(ctx, filter, opts...)
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := sessionCollection.collection.Find(ctx, sessionFilter(query), opts)
 	if err != nil {
 		return nil, err
 	}
@@ -68,15 +96,42 @@ func (sessionCollection *SessionCollection) FindAndUpdate(ctx context.Context, s
 		SetReturnDocument(options.After)
 
 	var updated models.Session
-	res := sessionCollection.collection.FindOneAndUpdate(ctx, filter, update, opts)
-	if res.Err() != nil {
-		return updated, res.Err()
-	}
-	err := res.Decode(&updated)
+	err := sessionCollection.outbox.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := sessionCollection.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated); err != nil {
+			return err
+		}
+		event, ok, err := models.NewSessionEvent(updated)
+		if err != nil || !ok {
+			return err
+		}
+		return sessionCollection.outbox.Add(ctx, event)
+	})
 	return updated, err
 }
 
-func (sessionCollection *SessionCollection) DeleteSession(ctx context.Context, filter bson.M) error {
-	_, err := sessionCollection.collection.DeleteOne(ctx, filter)
+// Watch mở change stream trên collection sessions, token rỗng thì bắt đầu từ thời điểm hiện tại
+func (sessionCollection *SessionCollection) Watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error) {
+	return watchCollection(ctx, sessionCollection.collection, resumeToken)
+}
+
+// EnsureIndexes tạo index unique cho (user_id, device_id), khóa dùng để upsert trong FindAndUpdate
+func (sessionCollection *SessionCollection) EnsureIndexes(ctx context.Context) error {
+	_, err := sessionCollection.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "device_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+func (sessionCollection *SessionCollection) DeleteSession(ctx context.Context, query repositories.SessionQuery) error {
+	_, err := sessionCollection.collection.DeleteOne(ctx, sessionFilter(query))
 	return err
 }
+
+func (sessionCollection *SessionCollection) DeleteSessions(ctx context.Context, query repositories.SessionQuery) (int64, error) {
+	res, err := sessionCollection.collection.DeleteMany(ctx, sessionFilter(query))
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
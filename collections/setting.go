@@ -0,0 +1,47 @@
+package collections
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SettingCollection struct {
+	collection *mongo.Collection
+}
+
+func NewSettingCollection(collection *mongo.Collection) *SettingCollection {
+	return &SettingCollection{collection}
+}
+
+// Get đọc giá trị của key vào value, found = false nếu chưa có cấu hình
+func (s *SettingCollection) Get(ctx context.Context, key string, value interface{}) (bool, error) {
+	var doc struct {
+		Value bson.RawValue `bson:"value"`
+	}
+	err := s.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, doc.Value.Unmarshal(value)
+}
+
+func (s *SettingCollection) Set(ctx context.Context, key string, value interface{}, updatedBy primitive.ObjectID) error {
+	set := bson.M{
+		"value":      value,
+		"updated_at": time.Now(),
+	}
+	if !updatedBy.IsZero() {
+		set["updated_by"] = updatedBy
+	}
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": set}, options.Update().SetUpsert(true))
+	return err
+}
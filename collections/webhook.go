@@ -0,0 +1,189 @@
+package collections
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookSubscriptionCollection struct {
+	collection *mongo.Collection
+}
+
+func NewWebhookSubscriptionCollection(collection *mongo.Collection) *WebhookSubscriptionCollection {
+	return &WebhookSubscriptionCollection{collection}
+}
+
+func (w *WebhookSubscriptionCollection) Create(ctx context.Context, subscription models.WebhookSubscription) (primitive.ObjectID, error) {
+	if subscription.Id.IsZero() {
+		subscription.Id = primitive.NewObjectID()
+	}
+	_, err := w.collection.InsertOne(ctx, subscription)
+	return subscription.Id, err
+}
+
+func (w *WebhookSubscriptionCollection) GetById(ctx context.Context, objectId primitive.ObjectID) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := w.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&subscription)
+	return subscription, err
+}
+
+func (w *WebhookSubscriptionCollection) FindAll(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.WebhookSubscription, error) {
+	subscriptions := []models.WebhookSubscription{}
+	cursor, err := w.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &subscriptions); err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+func (w *WebhookSubscriptionCollection) Update(ctx context.Context, objectId primitive.ObjectID, update bson.M) error {
+	res, err := w.collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+func (w *WebhookSubscriptionCollection) Delete(ctx context.Context, objectId primitive.ObjectID) error {
+	res, err := w.collection.DeleteOne(ctx, bson.M{"_id": objectId})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
+
+var _ repositories.WebhookSubscriptionRepository = (*WebhookSubscriptionCollection)(nil)
+
+func (w *WebhookSubscriptionCollection) FindActive(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return w.FindAll(ctx, bson.M{"active": true})
+}
+
+type WebhookDeliveryCollection struct {
+	collection *mongo.Collection
+}
+
+func NewWebhookDeliveryCollection(collection *mongo.Collection) *WebhookDeliveryCollection {
+	return &WebhookDeliveryCollection{collection}
+}
+
+func (w *WebhookDeliveryCollection) Create(ctx context.Context, delivery models.WebhookDelivery) (primitive.ObjectID, error) {
+	if delivery.Id.IsZero() {
+		delivery.Id = primitive.NewObjectID()
+	}
+	_, err := w.collection.InsertOne(ctx, delivery)
+	return delivery.Id, err
+}
+
+func (w *WebhookDeliveryCollection) GetById(ctx context.Context, objectId primitive.ObjectID) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := w.collection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&delivery)
+	return delivery, err
+}
+
+func (w *WebhookDeliveryCollection) FindAll(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	cursor, err := w.collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (w *WebhookDeliveryCollection) Count(ctx context.Context, filter bson.M) (int64, error) {
+	return w.collection.CountDocuments(ctx, filter)
+}
+
+var _ repositories.WebhookDeliveryRepository = (*WebhookDeliveryCollection)(nil)
+
+func (w *WebhookDeliveryCollection) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := w.collection.FindOneAndUpdate(ctx, bson.M{
+		"status":          models.WebhookDeliveryPending,
+		"next_attempt_at": bson.M{"$lte": now},
+	}, bson.M{
+		"$set": bson.M{"next_attempt_at": now.Add(lease)},
+	}, opts).Decode(&delivery)
+	return delivery, err
+}
+
+func (w *WebhookDeliveryCollection) SaveAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	set := bson.M{"status": status}
+	if status == models.WebhookDeliverySucceeded {
+		set["delivered_at"] = attempt.At
+	}
+	update := bson.M{
+		"$set":  set,
+		"$inc":  bson.M{"attempt_count": 1},
+		"$push": bson.M{"attempts": attempt},
+	}
+	if nextAttemptAt.IsZero() {
+		update["$unset"] = bson.M{"next_attempt_at": ""}
+	} else {
+		set["next_attempt_at"] = nextAttemptAt
+	}
+	return w.update(ctx, id, update)
+}
+
+func (w *WebhookDeliveryCollection) Reset(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	return w.update(ctx, id, bson.M{
+		"$set": bson.M{
+			"status":          models.WebhookDeliveryPending,
+			"attempt_count":   0,
+			"next_attempt_at": now,
+		},
+	})
+}
+
+func (w *WebhookDeliveryCollection) update(ctx context.Context, objectId primitive.ObjectID, update bson.M) error {
+	res, err := w.collection.UpdateOne(ctx, bson.M{"_id": objectId}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("Không có delivery được update")
+	}
+	return nil
+}
+
+func (w *WebhookDeliveryCollection) DeleteMany(ctx context.Context, filter bson.M) (int64, error) {
+	res, err := w.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (w *WebhookDeliveryCollection) EnsureIndexes(ctx context.Context) error {
+	_, err := w.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+		{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		// Mỗi sự kiện chỉ có một delivery cho mỗi subscription dù relay phát lại nhiều lần
+		{Keys: bson.D{{Key: "subscription_id", Value: 1}, {Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	})
+	return err
+}
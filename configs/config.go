@@ -10,7 +10,8 @@ import (
 )
 
 type Server struct {
-	Port int `yaml:"port"`
+	Port    int    `yaml:"port"`
+	BaseUrl string `yaml:"base_url"`
 }
 type Database struct {
 	URI  string `yaml:"uri"`
@@ -22,6 +23,8 @@ type Jwt struct {
 	JwtAccessTokenExpirationTime   int    `yaml:"jwt_access_token_expiration_time"`
 	JwtRefreshTokenExpirationTime  int    `yaml:"jwt_refresh_token_expiration_time"`
 	JwtAprrovedTokenExpirationTime int    `yaml:"jwt_aprroved_token_expiration_time"`
+	JwtEmailChangeExpirationTime   int    `yaml:"jwt_email_change_expiration_time"`
+	JwtEmailUndoExpirationTime     int    `yaml:"jwt_email_undo_expiration_time"`
 }
 
 type Email struct {
@@ -31,11 +34,118 @@ type Email struct {
 	Pass string `yaml:"pass"`
 }
 
+type Import struct {
+	SyncLimit int `yaml:"sync_limit"`
+	MaxRows   int `yaml:"max_rows"`
+}
+
+type Bulk struct {
+	ConfirmThreshold           int `yaml:"confirm_threshold"`
+	MaxItems                   int `yaml:"max_items"`
+	ConfirmTokenExpirationTime int `yaml:"confirm_token_expiration_time"`
+}
+
+type Purge struct {
+	IntervalMinutes int `yaml:"interval_minutes"`
+	BatchSize       int `yaml:"batch_size"`
+}
+
+type Webhook struct {
+	TimeoutSeconds        int `yaml:"timeout_seconds"`
+	MaxAttempts           int `yaml:"max_attempts"`
+	WorkerIntervalSeconds int `yaml:"worker_interval_seconds"`
+}
+
+type Outbox struct {
+	PollIntervalMs   int      `yaml:"poll_interval_ms"`
+	MaxAttempts      int      `yaml:"max_attempts"`
+	Sinks            []string `yaml:"sinks"`
+	BusSubjectPrefix string   `yaml:"bus_subject_prefix"`
+}
+
+type ChangeStream struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+type Cache struct {
+	Enabled            bool `yaml:"enabled"`
+	TtlSeconds         int  `yaml:"ttl_seconds"`
+	NegativeTtlSeconds int  `yaml:"negative_ttl_seconds"`
+}
+
+type Audit struct {
+	SigningKey                string `yaml:"signing_key"`
+	CheckpointIntervalMinutes int    `yaml:"checkpoint_interval_minutes"`
+}
+
+type S3 struct {
+	Endpoint          string `yaml:"endpoint"`
+	AccessKey         string `yaml:"access_key"`
+	SecretKey         string `yaml:"secret_key"`
+	Bucket            string `yaml:"bucket"`
+	Region            string `yaml:"region"`
+	UseSsl            bool   `yaml:"use_ssl"`
+	PresignTtlSeconds int    `yaml:"presign_ttl_seconds"`
+}
+
+type Storage struct {
+	Driver   string `yaml:"driver"`
+	LocalDir string `yaml:"local_dir"`
+	S3       S3     `yaml:"s3"`
+}
+
+type Scanner struct {
+	// Driver rỗng là không quét, "clamd" gửi file tới ClamAV daemon
+	Driver         string `yaml:"driver"`
+	Network        string `yaml:"network"`
+	Address        string `yaml:"address"`
+	TimeoutSeconds int    `yaml:"timeout_seconds"`
+	// FailOpen = true thì vẫn nhận file khi không quét được, false thì từ chối upload
+	FailOpen bool `yaml:"fail_open"`
+	// Nơi lưu file bị phát hiện mã độc để kiểm tra lại, tách riêng khỏi storage avatar
+	Quarantine Storage `yaml:"quarantine"`
+}
+
+type Avatar struct {
+	MaxUploadBytes int64 `yaml:"max_upload_bytes"`
+	MinDimension   int   `yaml:"min_dimension"`
+	MaxDimension   int   `yaml:"max_dimension"`
+	MaxPixels      int   `yaml:"max_pixels"`
+	Sizes          []int `yaml:"sizes"`
+	JpegQuality    int   `yaml:"jpeg_quality"`
+	// Job dọn file avatar không còn tài khoản nào tham chiếu
+	SweepIntervalMinutes int `yaml:"sweep_interval_minutes"`
+	SweepGraceHours      int `yaml:"sweep_grace_hours"`
+	// Thời gian client được cache ảnh chữ viết tắt của tài khoản chưa có ảnh
+	PlaceholderMaxAgeSeconds int `yaml:"placeholder_max_age_seconds"`
+	// Khóa ký URL avatar công khai, phải khác jwt.secret_key; để trống thì tắt URL công khai
+	UrlSigningKey string `yaml:"url_signing_key"`
+	UrlTtlSeconds int    `yaml:"url_ttl_seconds"`
+}
+
+type Tus struct {
+	// Phiên upload chưa hoàn tất sau thời gian này bị hủy và dọn định kỳ
+	ExpirationHours        int `yaml:"expiration_hours"`
+	CleanupIntervalMinutes int `yaml:"cleanup_interval_minutes"`
+}
+
 type Config struct {
-	Server   Server   `yaml:"server"`
-	Database Database `yaml:"database"`
-	Jwt      Jwt      `yaml:"jwt"`
-	Email    Email    `yaml:"email"`
+	Server       Server       `yaml:"server"`
+	Database     Database     `yaml:"database"`
+	Jwt          Jwt          `yaml:"jwt"`
+	Email        Email        `yaml:"email"`
+	Import       Import       `yaml:"import"`
+	Bulk         Bulk         `yaml:"bulk"`
+	Purge        Purge        `yaml:"purge"`
+	Audit        Audit        `yaml:"audit"`
+	Webhook      Webhook      `yaml:"webhook"`
+	Outbox       Outbox       `yaml:"outbox"`
+	ChangeStream ChangeStream `yaml:"change_stream"`
+	Cache        Cache        `yaml:"cache"`
+	Storage      Storage      `yaml:"storage"`
+	Scanner      Scanner      `yaml:"scanner"`
+	Avatar       Avatar       `yaml:"avatar"`
+	Tus          Tus          `yaml:"tus"`
 }
 
 var AppConfig *Config
@@ -2,35 +2,30 @@ package controllers
 
 import (
 	"UserManagementVer/collections"
+	"UserManagementVer/imaging"
 	"UserManagementVer/models"
+	"UserManagementVer/repositories"
 	"UserManagementVer/services"
 	"UserManagementVer/utils"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"log"
 	"math"
 	"net/http"
-	"os"
-	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/xuri/excelize/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-const (
-	MinSize = 1 * 1024 * 1024 // 1MB
-	MaxSize = 5 * 1024 * 1024 // 5MB
-)
-
 type CreateAccount struct {
 	Name     string    `json:"name,omitempty" validate:"required"`
 	Email    string    `json:"email,omitempty" validate:"required,email"`
@@ -51,6 +46,14 @@ type AccountResponse struct {
 	Phone  string    `json:"phone,omitempty"`
 	Dob    time.Time `json:"dob,omitempty"`
 	ImgUrl string    `json:"img_url,omitempty"`
+	// AvatarUrl là URL đã ký, dùng trực tiếp trong thẻ <img> không cần header Authorization
+	AvatarUrl string `json:"avatar_url,omitempty"`
+}
+
+type ChangeStatusRequest struct {
+	Action string    `json:"action" validate:"required,oneof=activate suspend lock unlock delete restore"`
+	Reason string    `json:"reason,omitempty"`
+	Until  time.Time `json:"until,omitempty"`
 }
 
 type PasswordUpdateRequest struct {
@@ -60,14 +63,27 @@ type PasswordUpdateRequest struct {
 }
 
 type AccountController struct {
-	accountCollection *collections.AccountCollection
+	accountCollection repositories.AccountRepository
 	jwtService        *services.JwtService
+	purgeService      *services.PurgeService
+	historyService    *services.AccountHistoryService
+	auditService      *services.AuditService
+	avatarService     *services.AvatarService
+	// avatarUrlSigner nil khi chưa cấu hình khóa ký, URL công khai bị tắt
+	avatarUrlSigner *services.AvatarUrlSigner
+	tusService      *services.TusService
 }
 
-func NewAccountController(accountCollection *collections.AccountCollection, jwtService *services.JwtService) *AccountController {
+func NewAccountController(accountCollection repositories.AccountRepository, jwtService *services.JwtService, purgeService *services.PurgeService, historyService *services.AccountHistoryService, auditService *services.AuditService, avatarService *services.AvatarService, avatarUrlSigner *services.AvatarUrlSigner, tusService *services.TusService) *AccountController {
 	return &AccountController{
 		accountCollection: accountCollection,
 		jwtService:        jwtService,
+		purgeService:      purgeService,
+		historyService:    historyService,
+		auditService:      auditService,
+		avatarService:     avatarService,
+		avatarUrlSigner:   avatarUrlSigner,
+		tusService:        tusService,
 	}
 }
 
@@ -104,7 +120,7 @@ func (accountCon *AccountController) CreateAccount(c *gin.Context) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	_, checkExisted := accountCon.accountCollection.Find(ctx, bson.M{"email": createAccount.Email})
+	_, checkExisted := accountCon.accountCollection.Find(ctx, repositories.ByEmail(createAccount.Email))
 
 	if !errors.Is(checkExisted, mongo.ErrNoDocuments) {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -114,9 +130,7 @@ func (accountCon *AccountController) CreateAccount(c *gin.Context) {
 		return
 	}
 
-	createdByAccount, err := accountCon.accountCollection.Find(ctx, bson.M{
-		"email": jwtCustomClaims.Email,
-	})
+	createdByAccount, err := accountCon.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
@@ -132,9 +146,10 @@ func (accountCon *AccountController) CreateAccount(c *gin.Context) {
 		Phone:     createAccount.Phone,
 		Dob:       createAccount.Dob,
 		CreatedBy: createdByAccount.Id,
+		Status:    models.StatusPending,
 	}
 
-	err = accountCon.accountCollection.Create(ctx, CreateAccountModel)
+	accountId, err := accountCon.accountCollection.Create(ctx, CreateAccountModel)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
@@ -142,6 +157,9 @@ func (accountCon *AccountController) CreateAccount(c *gin.Context) {
 		})
 		return
 	}
+	accountCon.recordHistory(c, models.HistoryActionCreate, nil, accountId, createdByAccount.Id)
+	CreateAccountModel.Id = accountId
+	accountCon.auditService.Log(auditEvent(c, models.AuditActionAccountCreate, models.AuditOutcomeSuccess, models.AccountTarget(CreateAccountModel)))
 	c.JSON(http.StatusCreated, gin.H{
 		"status":    http.StatusCreated,
 		"message":   "Tài khoản đã được tạo thành công",
@@ -179,9 +197,7 @@ func (accountCon *AccountController) UpdateAccount(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	updatedByAccount, err := accountCon.accountCollection.Find(ctx, bson.M{
-		"email": jwtCustomClaims.Email,
-	})
+	updatedByAccount, err := accountCon.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
@@ -207,19 +223,14 @@ func (accountCon *AccountController) UpdateAccount(c *gin.Context) {
 		})
 		return
 	}
-	filter := bson.M{
-		"_id": objectId,
-	}
-	update := bson.M{
-		"$set": bson.M{
-			"phone":      updateAccountRequest.Phone,
-			"dob":        updateAccountRequest.Dob,
-			"updated_by": updatedByAccount.Id,
-			"updated_at": time.Now(),
-			"name":       updateAccountRequest.Name,
-		},
+	update := repositories.AccountUpdate{
+		Phone:     updateAccountRequest.Phone,
+		Dob:       updateAccountRequest.Dob,
+		UpdatedBy: &updatedByAccount.Id,
+		UpdatedAt: repositories.Ptr(time.Now()),
+		Name:      updateAccountRequest.Name,
 	}
-	err = accountCon.accountCollection.Update(ctx, filter, update)
+	err = accountCon.accountCollection.Update(ctx, repositories.ById(objectId), update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
@@ -227,6 +238,8 @@ func (accountCon *AccountController) UpdateAccount(c *gin.Context) {
 		})
 		return
 	}
+	accountCon.recordHistory(c, models.HistoryActionUpdate, &oldAccount, objectId, updatedByAccount.Id)
+	accountCon.auditService.Log(auditEvent(c, models.AuditActionAccountUpdate, models.AuditOutcomeSuccess, models.AccountTarget(oldAccount)))
 	c.JSON(http.StatusOK, gin.H{
 		"status":    http.StatusOK,
 		"timestamp": time.Now(),
@@ -262,9 +275,35 @@ func (accountCon *AccountController) FindAccountById(c *gin.Context) {
 	})
 }
 
-func (accountCon *AccountController) SoftDelete(c *gin.Context) {
+func (accountCon *AccountController) ChangeStatus(c *gin.Context) {
 	id := c.Param("id")
 	objectId, _ := primitive.ObjectIDFromHex(id)
+	var changeStatusRequest ChangeStatusRequest
+
+	if err := c.ShouldBindJSON(&changeStatusRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := utils.HandlerValidation(utils.Validator.Struct(changeStatusRequest)); len(err) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Lỗi định dạng: " + err,
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := changeStatusRequest.validateStatusRequest(now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
 
 	authHeader := c.GetHeader("Authorization")
 	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
@@ -281,38 +320,30 @@ func (accountCon *AccountController) SoftDelete(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	deletedByAccount, err := accountCon.accountCollection.Find(ctx, bson.M{
-		"email": jwtCustomClaims.Email,
-	})
+	changedByAccount, err := accountCon.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"status":  http.StatusUnauthorized,
-			"message": "Không tìm thấy thông tin người xóa",
+			"message": "Không tìm thấy thông tin người thực hiện",
 		})
 		return
 	}
-	existedAccount, checkExisted := accountCon.accountCollection.GetAccountById(ctx, objectId)
-	if errors.Is(checkExisted, mongo.ErrNoDocuments) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"status":  http.StatusNotFound,
-			"message": "Không thấy tài khoản người bị xóa",
-		})
-		return
-	}
-	if !existedAccount.DeletedAt.IsZero() {
+	if changedByAccount.Id == objectId {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
-			"message": "Tài khoản này đã bị xóa",
+			"message": "Không thể tự thay đổi trạng thái tài khoản của mình",
 		})
 		return
 	}
-	update := bson.M{
-		"$set": bson.M{
-			"deleted_at": time.Now(),
-			"deleted_by": deletedByAccount.Id,
-		},
+
+	existedAccount, err := accountCon.accountCollection.GetAccountById(ctx, objectId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không thấy tài khoản",
+		})
+		return
 	}
-	err = accountCon.accountCollection.Update(ctx, bson.M{"_id": objectId}, update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
@@ -320,40 +351,30 @@ func (accountCon *AccountController) SoftDelete(c *gin.Context) {
 		})
 		return
 	}
-	c.JSON(http.StatusNoContent, gin.H{
-		"status":    http.StatusNoContent,
-		"timestamp": time.Now(),
-		"message":   "Đã xóa tài khoản!",
-	})
-}
 
-func (accountCon *AccountController) RestoreAccount(c *gin.Context) {
-	id := c.Param("id")
-	objectId, _ := primitive.ObjectIDFromHex(id)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	existedAccount, checkExisted := accountCon.accountCollection.GetAccountById(ctx, objectId)
-	if errors.Is(checkExisted, mongo.ErrNoDocuments) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"status":  http.StatusNotFound,
-			"message": "Không thấy tài khoản",
+	transition, update, err := changeStatusRequest.buildStatusUpdate(existedAccount, changedByAccount.Id, now)
+	if err != nil {
+		event := auditEvent(c, models.AuditActionAccountStatus, models.AuditOutcomeDenied, models.AccountTarget(existedAccount))
+		event.Reason = err.Error()
+		event.Metadata = bson.M{"action": changeStatusRequest.Action}
+		accountCon.auditService.Log(event)
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": err.Error(),
 		})
 		return
 	}
-	if existedAccount.DeletedAt.IsZero() {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  http.StatusBadRequest,
-			"message": "Tài khoản này chưa bị xóa",
+	// Chỉ ghi khi trạng thái chưa bị đổi từ lúc đọc, ví dụ tài khoản vừa được job purge nhận xử lý
+	query := repositories.ById(objectId)
+	query.Status = existedAccount.StoredStatus()
+	err = accountCon.accountCollection.Update(ctx, query, update)
+	if errors.Is(err, repositories.ErrNoMatch) {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": "Trạng thái tài khoản vừa bị thay đổi, vui lòng thử lại",
 		})
 		return
 	}
-	update := bson.M{
-		"$set": bson.M{
-			"deleted_at": nil,
-			"deleted_by": nil,
-		},
-	}
-	err := accountCon.accountCollection.Update(ctx, bson.M{"_id": objectId}, update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
@@ -361,35 +382,27 @@ func (accountCon *AccountController) RestoreAccount(c *gin.Context) {
 		})
 		return
 	}
+	accountCon.recordHistory(c, models.HistoryActionStatus, &existedAccount, objectId, changedByAccount.Id)
+	event := auditEvent(c, models.AuditActionAccountStatus, models.AuditOutcomeSuccess, models.AccountTarget(existedAccount))
+	event.Reason = changeStatusRequest.Reason
+	event.Metadata = bson.M{"action": changeStatusRequest.Action, "from": transition.From, "to": transition.To}
+	accountCon.auditService.Log(event)
 	c.JSON(http.StatusOK, gin.H{
 		"status":    http.StatusOK,
 		"timestamp": time.Now(),
-		"message":   "Đã khôi phục tài khoản!",
+		"message":   "Đã cập nhật trạng thái tài khoản!",
+		"data":      transition,
 	})
 }
 
 func (accountCon *AccountController) SearchAccount(c *gin.Context) {
 	keyword := c.Query("keyword")
-	keyword = regexp.QuoteMeta(keyword)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	filter := bson.M{
-		"$and": []bson.M{
-			{
-				"$or": []bson.M{
-					{"name": bson.M{"$regex": keyword, "$options": "i"}},
-					{"email": bson.M{"$regex": keyword, "$options": "i"}},
-				},
-			},
-			{
-				"$or": []bson.M{
-					{"deleted_at": nil},
-					{"deleted_at": bson.M{"$exists": false}},
-				},
-			},
-		},
-	}
-	accounts, err := accountCon.accountCollection.FindAll(ctx, filter)
+	accounts, err := accountCon.accountCollection.FindAll(ctx, repositories.AccountQuery{
+		Keyword:    keyword,
+		NotDeleted: true,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
@@ -406,12 +419,14 @@ func (accountCon *AccountController) SearchAccount(c *gin.Context) {
 	}
 	accountRes := []AccountResponse{}
 	for _, account := range accounts {
+		avatarUrl, _ := accountCon.signAvatarUrl(account, 0, "")
 		accountRes = append(accountRes, AccountResponse{
-			Name:   account.Name,
-			Phone:  account.Phone,
-			ImgUrl: account.ImageUrl,
-			Dob:    account.Dob,
-			Email:  account.Email,
+			Name:      account.Name,
+			Phone:     account.Phone,
+			ImgUrl:    account.ImageUrl,
+			AvatarUrl: avatarUrl,
+			Dob:       account.Dob,
+			Email:     account.Email,
 		})
 	}
 	c.JSON(http.StatusOK, gin.H{
@@ -422,6 +437,31 @@ func (accountCon *AccountController) SearchAccount(c *gin.Context) {
 	})
 }
 
+// CacheStats trả về số lần hit/miss của cache tài khoản
+func (accountCon *AccountController) CacheStats(c *gin.Context) {
+	var cache *collections.AccountCache
+	if cached, ok := accountCon.accountCollection.(interface {
+		Cache() *collections.AccountCache
+	}); ok {
+		cache = cached.Cache()
+	}
+	if cache == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    http.StatusOK,
+			"timestamp": time.Now(),
+			"message":   "Cache tài khoản chưa được bật",
+			"data":      gin.H{"enabled": false},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Thống kê cache tài khoản",
+		"data":      gin.H{"enabled": true, "stats": cache.Stats()},
+	})
+}
+
 func (ac *AccountController) UploadImage(c *gin.Context) {
 	id := c.Param("id")
 	objectId, _ := primitive.ObjectIDFromHex(id)
@@ -454,12 +494,6 @@ func (ac *AccountController) UploadImage(c *gin.Context) {
 	}
 	// Lấy file từ request
 	file := files[0]
-	//Kiểm tra kích thước file upload
-	//if file.Size < MinSize || file.Size > MaxSize {
-	//	c.JSON(400, gin.H{"error": "File phải từ 1MB đến 5MB"})
-	//	return
-	//}
-
 	//Check valid file
 	if err := utils.ChechValidFile(file); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -477,57 +511,122 @@ func (ac *AccountController) UploadImage(c *gin.Context) {
 		return
 	}
 
-	// Đảm bảo thư mục uploads tồn tại
-	if err := ensureUploadDir("uploads"); err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	oldAccount, err := ac.accountCollection.GetAccountById(ctx, objectId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy",
+		})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
-			"message": "Không thể khởi tạo được thư mục uploads",
+			"message": "Không thể mở được file",
 		})
 		return
 	}
+	defer f.Close()
+	result, status := ac.replaceAvatar(ctx, c, oldAccount, f)
+	if status != http.StatusOK {
+		return
+	}
 
-	// Sinh UUID + tên file
-	u := uuid.New().String()
-	ext := filepath.Ext(file.Filename)
-	baseName := strings.TrimSuffix(file.Filename, ext)
-	fileName := fmt.Sprintf("%s_%s%s", u, baseName, ext)
-	filePath := filepath.Join("uploads", fileName)
+	c.JSON(http.StatusOK, gin.H{
+		"status":  http.StatusOK,
+		"message": "Upload thành công",
+		"path":    result.Key,
+		// deduplicated = true khi ảnh giống hệt avatar đã lưu trước đó và không lưu thêm file
+		"deduplicated": result.Deduplicated,
+	})
+}
 
-	// Lưu file vào server
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+// replaceAvatar quét, xử lý và lưu ảnh từ r làm avatar mới của oldAccount rồi dọn avatar cũ; dùng chung
+// cho upload multipart và tus. Thất bại thì lỗi đã được trả cho client và status là mã HTTP đã trả.
+func (ac *AccountController) replaceAvatar(ctx context.Context, c *gin.Context, oldAccount models.Account, r io.Reader) (services.UploadResult, int) {
+	objectId := oldAccount.Id
+	// Xử lý ảnh thành các biến thể và lưu vào storage, DB chỉ giữ key gốc
+	result, err := ac.avatarService.Upload(ctx, r)
+	var malwareErr *services.MalwareError
+	if errors.As(err, &malwareErr) {
+		log.Println("Từ chối avatar chứa mã độc", objectId.Hex(), malwareErr.Signature, malwareErr.QuarantineKey)
+		event := auditEvent(c, models.AuditActionAvatarUpdate, models.AuditOutcomeDenied, models.AccountTarget(oldAccount))
+		event.Metadata = bson.M{"reason": "malware", "signature": malwareErr.Signature, "quarantine_key": malwareErr.QuarantineKey}
+		ac.auditService.Log(event)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "File bị từ chối do phát hiện mã độc",
+		})
+		return result, http.StatusBadRequest
+	}
+	if errors.Is(err, services.ErrScanUnavailable) {
+		log.Println("Không thể quét avatar", objectId.Hex(), err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  http.StatusServiceUnavailable,
+			"message": "Chưa thể kiểm tra file, vui lòng thử lại sau",
+		})
+		return result, http.StatusServiceUnavailable
+	}
+	if errors.Is(err, imaging.ErrInvalidImage) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return result, http.StatusBadRequest
+	}
+	if err != nil {
+		log.Println("Không thể lưu avatar", objectId.Hex(), err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
 			"message": "Không thể lưu file",
 		})
-		return
+		return result, http.StatusInternalServerError
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := ac.accountCollection.Update(ctx, bson.M{"_id": objectId}, bson.M{"$set": bson.M{
-		"image_url":  filePath,
-		"updated_at": time.Now(),
-	}}); err != nil {
+	if err := ac.accountCollection.Update(ctx, repositories.ById(objectId), repositories.AccountUpdate{
+		ImageUrl:  &result.Key,
+		UpdatedAt: repositories.Ptr(time.Now()),
+	}); err != nil {
+		// Xóa file vừa lưu để không để lại file mồ côi, trừ khi đó vẫn là avatar hiện tại
+		if result.Key != oldAccount.ImageUrl {
+			_, err = ac.avatarService.Discard(ctx, objectId, result)
+		} else {
+			err = ac.avatarService.Confirm(ctx, result)
+		}
+		if err != nil {
+			log.Println("Không thể dọn avatar vừa upload", result.Key, err)
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  http.StatusInternalServerError,
 			"message": "Không thể cập nhật ảnh vào DB",
 		})
-		return
+		return result, http.StatusInternalServerError
+	}
+	// Avatar có thể đã bị tài khoản khác xóa trước khi image_url được cập nhật, khi đó lưu lại
+	if err := ac.avatarService.Confirm(ctx, result); err != nil {
+		log.Println("Không thể lưu lại avatar", objectId.Hex(), result.Key, err)
 	}
+	// Avatar cũ bị xóa nếu không còn tài khoản nào dùng, lỗi thì để job dọn avatar xử lý sau.
+	// Upload lại đúng ảnh đang dùng thì key không đổi và không được xóa.
+	if result.Key != oldAccount.ImageUrl {
+		ac.removeAvatarFiles(ctx, objectId, oldAccount.ImageUrl)
+	}
+	ac.recordHistory(c, models.HistoryActionAvatar, &oldAccount, objectId, currentAccountId(c))
+	ac.auditService.Log(auditEvent(c, models.AuditActionAvatarUpdate, models.AuditOutcomeSuccess, models.AccountTarget(oldAccount)))
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":  http.StatusOK,
-		"message": "Upload thành công",
-		"path":    filePath,
-	})
+	return result, http.StatusOK
 }
 
-func (ac *AccountController) GetAvatar(c *gin.Context) {
-	id := c.Param("id")
-	objectId, _ := primitive.ObjectIDFromHex(id)
+func (ac *AccountController) DeleteAvatar(c *gin.Context) {
+	objectId, _ := primitive.ObjectIDFromHex(c.Param("id"))
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
+
 	account, err := ac.accountCollection.GetAccountById(ctx, objectId)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -536,45 +635,44 @@ func (ac *AccountController) GetAvatar(c *gin.Context) {
 		})
 		return
 	}
-	fmt.Println(account.ImageUrl)
-	filePath := strings.ReplaceAll(account.ImageUrl, "\\", "/")
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	if account.ImageUrl == "" {
 		c.JSON(http.StatusNotFound, gin.H{
 			"status":  http.StatusNotFound,
-			"message": "Ảnh không tồn tại",
-		})
-		return
-	}
-	// 2. Trả file ảnh về
-	c.File(filePath)
-}
-
-func (ac *AccountController) UpdateTimeToLiveHardDelete(c *gin.Context) {
-	var ttl map[string]int
-	if err := c.ShouldBindJSON(&ttl); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status": int(http.StatusBadRequest),
-			"error":  err.Error(),
+			"message": "Tài khoản chưa có ảnh đại diện",
 		})
 		return
 	}
-
-	ac.accountCollection.DeleteIndex("deleted_at_1")
-	if err := ac.accountCollection.UpdateIndex(ttl["ttl"], "deleted_at"); err != nil {
+	if err := ac.accountCollection.Update(ctx, repositories.ById(objectId), repositories.AccountUpdate{
+		ImageUrl:  repositories.Ptr(""),
+		UpdatedAt: repositories.Ptr(time.Now()),
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"status": int(http.StatusInternalServerError),
-			"error":  err.Error(),
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể xóa ảnh khỏi DB",
 		})
 		return
 	}
+	ac.removeAvatarFiles(ctx, objectId, account.ImageUrl)
+	ac.recordHistory(c, models.HistoryActionAvatar, &account, objectId, currentAccountId(c))
+	ac.auditService.Log(auditEvent(c, models.AuditActionAvatarDelete, models.AuditOutcomeSuccess, models.AccountTarget(account)))
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":    int(http.StatusOK),
+		"status":    http.StatusOK,
 		"timestamp": time.Now(),
-		"message":   "Thành công",
+		"message":   "Đã xóa ảnh đại diện",
 	})
 }
 
+// removeAvatarFiles bỏ tham chiếu của tài khoản tới avatar, file chỉ bị xóa khi không còn ai dùng
+func (ac *AccountController) removeAvatarFiles(ctx context.Context, accountId primitive.ObjectID, imageUrl string) {
+	if imageUrl == "" {
+		return
+	}
+	if _, err := ac.avatarService.Release(ctx, accountId, imageUrl); err != nil {
+		log.Println("Không thể xóa avatar", imageUrl, err)
+	}
+}
+
 func (ac *AccountController) DownloadAccountsExcel(c *gin.Context) {
 	// Tạo file Excel
 	f := excelize.NewFile()
@@ -633,7 +731,14 @@ func (ac *AccountController) DownloadAccountsExcel(c *gin.Context) {
 	_ = f.SetCellStyle(sheet, "A2", "D2", styleHeader)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	accounts, err := ac.accountCollection.FindAll(ctx, bson.M{})
+	accounts, err := ac.accountCollection.FindAll(ctx, repositories.AccountQuery{})
+	exportEvent := auditEvent(c, models.AuditActionAccountExport, models.AuditOutcomeSuccess, models.AuditTarget{Type: models.AuditTargetAccount})
+	if err != nil {
+		exportEvent.Outcome = models.AuditOutcomeFailure
+		exportEvent.Reason = err.Error()
+	}
+	exportEvent.Metadata = bson.M{"format": "xlsx", "count": len(accounts)}
+	ac.auditService.Log(exportEvent)
 
 	sort.Slice(accounts, func(i, j int) bool {
 		return accounts[i].Dob.After(accounts[j].Dob)
@@ -745,9 +850,7 @@ func (a *AccountController) RestorePassword(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	updatedByAccount, err := a.accountCollection.Find(ctx, bson.M{
-		"email": jwtCustomClaims.Email,
-	})
+	updatedByAccount, err := a.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
 
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
@@ -757,7 +860,7 @@ func (a *AccountController) RestorePassword(c *gin.Context) {
 		return
 	}
 
-	existsAccout, checkExisted := a.accountCollection.GetAccountById(ctx, obejctId)
+	existsAccout, checkExisted := a.accountCollection.Find(ctx, repositories.AccountQuery{Ids: []primitive.ObjectID{obejctId}, WithPassword: true})
 	if errors.Is(checkExisted, mongo.ErrNoDocuments) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
@@ -767,6 +870,9 @@ func (a *AccountController) RestorePassword(c *gin.Context) {
 	}
 
 	if !utils.CheckPassword(existsAccout.Password, passwordUpdateRequest.OldPassword) {
+		event := auditEvent(c, models.AuditActionPasswordReset, models.AuditOutcomeFailure, models.AccountTarget(existsAccout))
+		event.Reason = "Mật khẩu cũ không đúng"
+		a.auditService.Log(event)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": "Mật khẩu cũ không đúng",
@@ -791,14 +897,10 @@ func (a *AccountController) RestorePassword(c *gin.Context) {
 	}
 
 	hashPass, _ := utils.HashPassword(passwordUpdateRequest.NewPassword)
-	err = a.accountCollection.Update(ctx, bson.M{
-		"_id": obejctId,
-	}, bson.M{
-		"$set": bson.M{
-			"password":   hashPass,
-			"updated_at": time.Now(),
-			"updated_by": updatedByAccount.Id,
-		},
+	err = a.accountCollection.Update(ctx, repositories.ById(obejctId), repositories.AccountUpdate{
+		Password:  &hashPass,
+		UpdatedAt: repositories.Ptr(time.Now()),
+		UpdatedBy: &updatedByAccount.Id,
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -807,6 +909,12 @@ func (a *AccountController) RestorePassword(c *gin.Context) {
 		})
 		return
 	}
+	a.recordHistory(c, models.HistoryActionPassword, &existsAccout, obejctId, updatedByAccount.Id, models.FieldChange{
+		Field: "password",
+		Old:   "******",
+		New:   "******",
+	})
+	a.auditService.Log(auditEvent(c, models.AuditActionPasswordReset, models.AuditOutcomeSuccess, models.AccountTarget(existsAccout)))
 	c.JSON(http.StatusOK, gin.H{
 		"status":    http.StatusOK,
 		"timestamp": time.Now(),
@@ -814,14 +922,6 @@ func (a *AccountController) RestorePassword(c *gin.Context) {
 	})
 }
 
-func ensureUploadDir(fileName string) error {
-	uploadDir := fileName
-	if _, err := os.Stat(uploadDir); os.IsNotExist(err) {
-		return os.MkdirAll(uploadDir, os.ModePerm)
-	}
-	return nil
-}
-
 func (updateAccountRequest UpdateAccount) handlerUpdateAccountRequest(oldAccount models.Account) UpdateAccount {
 	if updateAccountRequest.Name == nil {
 		updateAccountRequest.Name = &oldAccount.Name
@@ -834,3 +934,55 @@ func (updateAccountRequest UpdateAccount) handlerUpdateAccountRequest(oldAccount
 	}
 	return updateAccountRequest
 }
+
+func (changeStatusRequest ChangeStatusRequest) validateStatusRequest(now time.Time) error {
+	if changeStatusRequest.Action != models.ActionSuspend {
+		return nil
+	}
+	if strings.TrimSpace(changeStatusRequest.Reason) == "" {
+		return errors.New("Phải có lý do khi tạm ngưng tài khoản")
+	}
+	if !changeStatusRequest.Until.IsZero() && !changeStatusRequest.Until.After(now) {
+		return errors.New("Thời hạn tạm ngưng phải ở tương lai")
+	}
+	return nil
+}
+
+// buildStatusUpdate tính trạng thái mới của account và sinh thay đổi cần ghi kèm bản ghi lịch sử chuyển trạng thái
+func (changeStatusRequest ChangeStatusRequest) buildStatusUpdate(account models.Account, changedBy primitive.ObjectID, now time.Time) (models.StatusTransition, repositories.AccountUpdate, error) {
+	nextStatus, err := models.NextStatus(account, changeStatusRequest.Action, now)
+	if err != nil {
+		return models.StatusTransition{}, repositories.AccountUpdate{}, err
+	}
+
+	transition := models.StatusTransition{
+		Action:    changeStatusRequest.Action,
+		From:      account.CurrentStatus(now),
+		To:        nextStatus,
+		Reason:    changeStatusRequest.Reason,
+		ChangedBy: changedBy,
+		ChangedAt: now,
+	}
+	// Lý do và thời hạn rỗng sẽ xóa giá trị cũ của tài khoản
+	update := repositories.AccountUpdate{
+		Status:              &nextStatus,
+		StatusReason:        repositories.Ptr(changeStatusRequest.Reason),
+		SuspendedUntil:      &time.Time{},
+		StatusChangedAt:     &now,
+		StatusChangedBy:     &changedBy,
+		AppendStatusHistory: &transition,
+	}
+	if nextStatus == models.StatusSuspended && !changeStatusRequest.Until.IsZero() {
+		transition.Until = changeStatusRequest.Until
+		update.SuspendedUntil = repositories.Ptr(changeStatusRequest.Until)
+	}
+	switch changeStatusRequest.Action {
+	case models.ActionDelete:
+		update.DeletedAt = &now
+		update.DeletedBy = &changedBy
+	case models.ActionRestore:
+		update.DeletedAt = &time.Time{}
+		update.DeletedBy = &primitive.ObjectID{}
+	}
+	return transition, update, nil
+}
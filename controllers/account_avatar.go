@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"UserManagementVer/configs"
+	"UserManagementVer/models"
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// URL có ?v= khớp phiên bản hiện tại không bao giờ đổi nội dung nên được cache vĩnh viễn
+const immutableMaxAge = 365 * 24 * 60 * 60
+
+func (ac *AccountController) GetAvatar(c *gin.Context) {
+	id := c.Param("id")
+	objectId, _ := primitive.ObjectIDFromHex(id)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	account, err := ac.accountCollection.GetAccountById(ctx, objectId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy",
+		})
+		return
+	}
+	ac.serveAvatar(ctx, c, account, avatarSize(c), c.Query("format"), c.Query("v"), time.Time{})
+}
+
+// GetPublicAvatar trả avatar qua URL đã ký (không cần đăng nhập) để dùng trong thẻ <img>
+func (ac *AccountController) GetPublicAvatar(c *gin.Context) {
+	id := c.Param("id")
+	objectId, _ := primitive.ObjectIDFromHex(id)
+	if ac.avatarUrlSigner == nil {
+		ac.avatarUrlDisabled(c)
+		return
+	}
+	params, err := ac.avatarUrlSigner.Verify(objectId, c.Request.URL.Query(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": err.Error(),
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	account, err := ac.accountCollection.GetAccountById(ctx, objectId)
+	if err != nil || !account.DeletedAt.IsZero() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy",
+		})
+		return
+	}
+	ac.serveAvatar(ctx, c, account, params.Size, params.Format, params.Version, params.Expires)
+}
+
+// GetAvatarUrl tạo URL công khai đã ký cho avatar hiện tại của tài khoản
+func (ac *AccountController) GetAvatarUrl(c *gin.Context) {
+	if ac.avatarUrlSigner == nil {
+		ac.avatarUrlDisabled(c)
+		return
+	}
+	id := c.Param("id")
+	objectId, _ := primitive.ObjectIDFromHex(id)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	account, err := ac.accountCollection.GetAccountById(ctx, objectId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy",
+		})
+		return
+	}
+	size, format := avatarSize(c), c.Query("format")
+	if err := ac.avatarService.CheckVariant(size, format); err != nil {
+		ac.invalidAvatarVariant(c, err)
+		return
+	}
+	url, expiresAt := ac.signAvatarUrl(account, size, format)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tạo liên kết ảnh thành công",
+		"data": gin.H{
+			"url":        url,
+			"version":    services.AvatarVersion(account),
+			"expires_at": expiresAt,
+		},
+	})
+}
+
+// serveAvatar trả ảnh đã upload hoặc ảnh mặc định kèm ETag/Last-Modified để client hỏi lại bằng
+// If-None-Match/If-Modified-Since và nhận 304. version khớp phiên bản hiện tại thì cache vĩnh viễn.
+// expires khác zero là URL công khai đã ký, cache không được giữ lâu hơn hạn của URL.
+func (ac *AccountController) serveAvatar(ctx context.Context, c *gin.Context, account models.Account, size int, format string, version string, expires time.Time) {
+	scope := "private"
+	if !expires.IsZero() {
+		scope = "public"
+	}
+	maxAge := func(seconds int) int {
+		if remaining := int(time.Until(expires).Seconds()); !expires.IsZero() && remaining < seconds {
+			return max(remaining, 0)
+		}
+		return seconds
+	}
+	immutable := version != "" && version == services.AvatarVersion(account)
+	cacheControl := func(revalidate string) string {
+		if immutable {
+			return fmt.Sprintf("%s, max-age=%d, immutable", scope, maxAge(immutableMaxAge))
+		}
+		return scope + ", " + revalidate
+	}
+
+	// Chưa có ảnh hoặc file đã mất thì trả ảnh mặc định để client không phải tự xử lý
+	if account.ImageUrl == "" {
+		ac.placeholderAvatar(c, account, size, format, cacheControl(fmt.Sprintf("max-age=%d", maxAge(configs.AppConfig.Avatar.PlaceholderMaxAgeSeconds))))
+		return
+	}
+	etag, err := ac.avatarService.ETag(account.ImageUrl, size)
+	if errors.Is(err, services.ErrInvalidAvatarSize) {
+		ac.invalidAvatarVariant(c, err)
+		return
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		ac.placeholderAvatar(c, account, size, format, scope+", no-cache")
+		return
+	}
+	// ETag suy ra từ key nên trả 304 mà không cần mở file trên storage
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", cacheControl("no-cache"))
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	object, url, err := ac.avatarService.Open(ctx, account.ImageUrl, size)
+	if errors.Is(err, storage.ErrNotFound) {
+		ac.placeholderAvatar(c, account, size, format, scope+", no-cache")
+		return
+	}
+	if err != nil {
+		log.Println("Không thể đọc avatar", account.ImageUrl, err)
+		c.Header("ETag", "")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể đọc ảnh",
+		})
+		return
+	}
+	// Storage hỗ trợ URL tạm thời (S3) thì chuyển hướng client tải trực tiếp,
+	// ETag và Cache-Control ở trên vẫn áp dụng cho lần hỏi lại sau
+	if url != "" {
+		c.Redirect(http.StatusFound, url)
+		return
+	}
+	defer object.Body.Close()
+	c.Header("Content-Type", object.ContentType)
+	if body, ok := object.Body.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, "", object.ModTime, body)
+		return
+	}
+	c.DataFromReader(http.StatusOK, object.Size, object.ContentType, object.Body, nil)
+}
+
+// etagMatches kiểm tra header If-None-Match (có thể gồm nhiều ETag hoặc *) có khớp etag không
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderAvatar trả ảnh chữ viết tắt theo format (png hoặc svg)
+func (ac *AccountController) placeholderAvatar(c *gin.Context, account models.Account, size int, format string, cacheControl string) {
+	data, contentType, err := ac.avatarService.Placeholder(account.Id, account.Name, size, format)
+	if errors.Is(err, services.ErrInvalidAvatarSize) || errors.Is(err, services.ErrInvalidAvatarFormat) {
+		ac.invalidAvatarVariant(c, err)
+		return
+	}
+	if err != nil {
+		log.Println("Không thể tạo avatar mặc định", account.Id.Hex(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể đọc ảnh",
+		})
+		return
+	}
+	serveImage(c, data, contentType, time.Time{}, cacheControl)
+}
+
+// serveImage gắn ETag theo hash nội dung rồi để http.ServeContent xử lý If-None-Match,
+// If-Modified-Since (khi biết modTime), Range và HEAD
+func serveImage(c *gin.Context, data []byte, contentType string, modTime time.Time, cacheControl string) {
+	sum := sha256.Sum256(data)
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	c.Header("Cache-Control", cacheControl)
+	c.Header("Content-Type", contentType)
+	http.ServeContent(c.Writer, c.Request, "", modTime, bytes.NewReader(data))
+}
+
+func (ac *AccountController) invalidAvatarVariant(c *gin.Context, err error) {
+	message := "Định dạng ảnh phải là png hoặc svg"
+	if errors.Is(err, services.ErrInvalidAvatarSize) {
+		message = fmt.Sprintf("Kích thước ảnh phải là một trong %v", ac.avatarService.Sizes())
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"status":  http.StatusBadRequest,
+		"message": message,
+	})
+}
+
+func (ac *AccountController) avatarUrlDisabled(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"status":  http.StatusNotFound,
+		"message": "URL công khai của avatar chưa được bật",
+	})
+}
+
+// signAvatarUrl tạo URL công khai có phiên bản, đổi avatar hoặc tên thì URL cũ không còn được cache vĩnh viễn.
+// Trả URL rỗng khi chưa cấu hình khóa ký.
+func (ac *AccountController) signAvatarUrl(account models.Account, size int, format string) (string, time.Time) {
+	if ac.avatarUrlSigner == nil {
+		return "", time.Time{}
+	}
+	query, expiresAt := ac.avatarUrlSigner.Sign(account.Id, services.AvatarUrlParams{
+		Version: services.AvatarVersion(account),
+		Size:    size,
+		Format:  format,
+	}, time.Now())
+	return fmt.Sprintf("%s/api/v1/avatars/%s?%s", strings.TrimSuffix(configs.AppConfig.Server.BaseUrl, "/"), account.Id.Hex(), query.Encode()), expiresAt
+}
+
+// avatarSize đọc ?size=, rỗng là biến thể lớn nhất, giá trị không phải số trả -1 để bị từ chối
+func avatarSize(c *gin.Context) int {
+	value := c.Query("size")
+	if value == "" {
+		return 0
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil {
+		return -1
+	}
+	return size
+}
@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"UserManagementVer/configs"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const ActionReassign = "reassign"
+
+type BulkAccountFilter struct {
+	Keyword string `json:"keyword,omitempty"`
+	Status  string `json:"status,omitempty" validate:"omitempty,oneof=pending active suspended locked deleted"`
+}
+
+type BulkAccountRequest struct {
+	Action       string             `json:"action" validate:"required,oneof=activate suspend lock unlock delete restore reassign"`
+	Ids          []string           `json:"ids,omitempty"`
+	Filter       *BulkAccountFilter `json:"filter,omitempty"`
+	Reason       string             `json:"reason,omitempty"`
+	Until        time.Time          `json:"until,omitempty"`
+	AssigneeId   string             `json:"assignee_id,omitempty"`
+	ConfirmToken string             `json:"confirm_token,omitempty"`
+}
+
+type BulkItemResult struct {
+	Id      string `json:"id"`
+	Success bool   `json:"success"`
+	Message string `json:"message,omitempty"`
+}
+
+func (accountCon *AccountController) BulkAccounts(c *gin.Context) {
+	var bulkRequest BulkAccountRequest
+
+	if err := c.ShouldBindJSON(&bulkRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := utils.HandlerValidation(utils.Validator.Struct(bulkRequest)); len(err) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Lỗi định dạng: " + err,
+		})
+		return
+	}
+
+	if (len(bulkRequest.Ids) == 0) == (bulkRequest.Filter == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Phải truyền một trong hai: ids hoặc filter",
+		})
+		return
+	}
+
+	now := time.Now()
+	statusRequest := ChangeStatusRequest{
+		Action: bulkRequest.Action,
+		Reason: bulkRequest.Reason,
+		Until:  bulkRequest.Until,
+	}
+	if err := statusRequest.validateStatusRequest(now); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	jwtCustomClaims, err := accountCon.jwtService.ExtractCustomClaims(authHeader)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	changedByAccount, err := accountCon.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Không tìm thấy thông tin người thực hiện",
+		})
+		return
+	}
+
+	var assignee models.Account
+	if bulkRequest.Action == ActionReassign {
+		assigneeId, err := primitive.ObjectIDFromHex(bulkRequest.AssigneeId)
+		if err == nil {
+			assignee, err = accountCon.accountCollection.GetAccountById(ctx, assigneeId)
+		}
+		if err == nil {
+			err = assignee.CheckCanLogin(now)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "Người được giao không hợp lệ",
+			})
+			return
+		}
+	}
+
+	results := []BulkItemResult{}
+	var query repositories.AccountQuery
+	if bulkRequest.Filter != nil {
+		query = bulkRequest.Filter.toQuery()
+	} else {
+		objectIds := []primitive.ObjectID{}
+		for _, id := range bulkRequest.Ids {
+			objectId, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				results = append(results, BulkItemResult{Id: id, Message: "Id không hợp lệ"})
+				continue
+			}
+			objectIds = append(objectIds, objectId)
+		}
+		query = repositories.AccountQuery{Ids: objectIds}
+	}
+	// Đọc thêm một tài khoản để biết có vượt MaxItems hay không mà không nạp hết kết quả của filter
+	query.Limit = configs.AppConfig.Bulk.MaxItems + 1
+
+	accounts, err := accountCon.accountCollection.FindAll(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(accounts) > configs.AppConfig.Bulk.MaxItems {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": fmt.Sprintf("Chỉ được thao tác tối đa %d tài khoản mỗi lần", configs.AppConfig.Bulk.MaxItems),
+		})
+		return
+	}
+	if bulkRequest.Filter == nil {
+		found := map[string]bool{}
+		for _, account := range accounts {
+			found[account.Id.Hex()] = true
+		}
+		for _, id := range bulkRequest.Ids {
+			if _, err := primitive.ObjectIDFromHex(id); err == nil && !found[id] {
+				results = append(results, BulkItemResult{Id: id, Message: "Không thấy tài khoản"})
+			}
+		}
+	}
+
+	// Thao tác trên nhiều tài khoản cần token xác nhận được sinh từ chính danh sách tài khoản khớp
+	if len(accounts) > configs.AppConfig.Bulk.ConfirmThreshold {
+		digest := bulkRequest.digest(accounts)
+		if bulkRequest.ConfirmToken == "" {
+			confirmToken, _, err := accountCon.jwtService.GenerateConfirmJwt(jwtCustomClaims.Email, digest, configs.AppConfig.Bulk.ConfirmTokenExpirationTime, "bulk_confirm")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"status":  http.StatusInternalServerError,
+					"message": "Không thể sinh được token",
+				})
+				return
+			}
+			c.JSON(http.StatusPreconditionRequired, gin.H{
+				"status":  http.StatusPreconditionRequired,
+				"message": fmt.Sprintf("Thao tác ảnh hưởng %d tài khoản, vui lòng gửi lại kèm confirm_token", len(accounts)),
+				"data": gin.H{
+					"matched":       len(accounts),
+					"confirm_token": confirmToken,
+				},
+			})
+			return
+		}
+		if err := accountCon.checkBulkConfirmToken(bulkRequest.ConfirmToken, jwtCustomClaims.Email, digest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": err.Error(),
+			})
+			return
+		}
+	}
+
+	items := []repositories.AccountBulkUpdate{}
+	for _, account := range accounts {
+		if account.Id == changedByAccount.Id {
+			results = append(results, BulkItemResult{Id: account.Id.Hex(), Message: "Không thể tự thao tác trên tài khoản của mình"})
+			continue
+		}
+		item := repositories.AccountBulkUpdate{Id: account.Id}
+		if bulkRequest.Action == ActionReassign {
+			item.Update = repositories.AccountUpdate{
+				ManagedBy: &assignee.Id,
+				UpdatedAt: &now,
+				UpdatedBy: &changedByAccount.Id,
+			}
+		} else {
+			_, item.Update, err = statusRequest.buildStatusUpdate(account, changedByAccount.Id, now)
+			if err != nil {
+				results = append(results, BulkItemResult{Id: account.Id.Hex(), Message: err.Error()})
+				continue
+			}
+			item.Status = account.StoredStatus()
+		}
+		items = append(items, item)
+	}
+
+	before := map[primitive.ObjectID]models.Account{}
+	for _, account := range accounts {
+		before[account.Id] = account
+	}
+	writeErrors, err := accountCon.accountCollection.BulkUpdate(ctx, items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	succeededIds := []primitive.ObjectID{}
+	for i, item := range items {
+		id := item.Id
+		if writeErr, ok := writeErrors[i]; ok {
+			message := writeErr.Error()
+			if errors.Is(writeErr, repositories.ErrNoMatch) {
+				message = "Trạng thái tài khoản vừa bị thay đổi, vui lòng thử lại"
+			}
+			results = append(results, BulkItemResult{Id: id.Hex(), Message: message})
+			continue
+		}
+		succeededIds = append(succeededIds, id)
+		results = append(results, BulkItemResult{Id: id.Hex(), Success: true})
+	}
+	historyAction := models.HistoryActionStatus
+	if bulkRequest.Action == ActionReassign {
+		historyAction = models.HistoryActionReassign
+	}
+	accountCon.recordBulkHistory(c, historyAction, before, succeededIds, changedByAccount.Id)
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	event := auditEvent(c, models.AuditActionAccountBulk, models.AuditOutcomeSuccess, models.AuditTarget{Type: models.AuditTargetAccount})
+	event.Reason = bulkRequest.Reason
+	event.Metadata = bson.M{
+		"action":      bulkRequest.Action,
+		"matched":     len(accounts),
+		"succeeded":   succeeded,
+		"failed":      len(results) - succeeded,
+		"account_ids": succeededIds,
+		"assignee_id": bulkRequest.AssigneeId,
+		"by_filter":   bulkRequest.Filter != nil,
+	}
+	accountCon.auditService.Log(event)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã xử lý thao tác hàng loạt",
+		"data": gin.H{
+			"matched":   len(accounts),
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+			"results":   results,
+		},
+	})
+}
+
+func (accountCon *AccountController) checkBulkConfirmToken(token string, email string, digest string) error {
+	if _, err := accountCon.jwtService.ValidateToken(token); err != nil {
+		return err
+	}
+	claims, err := accountCon.jwtService.ExtractCustomClaims(token)
+	if err != nil {
+		return err
+	}
+	if claims.Type != "bulk_confirm" || claims.Email != email || claims.Subject != digest {
+		return errors.New("Confirm token không khớp với thao tác, danh sách tài khoản có thể đã thay đổi")
+	}
+	return nil
+}
+
+func (filter BulkAccountFilter) toQuery() repositories.AccountQuery {
+	return repositories.AccountQuery{
+		Keyword: filter.Keyword,
+		Status:  filter.Status,
+	}
+}
+
+// digest đại diện cho thao tác hàng loạt: hành động, tham số và danh sách tài khoản bị ảnh hưởng
+func (bulkRequest BulkAccountRequest) digest(accounts []models.Account) string {
+	ids := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		ids = append(ids, account.Id.Hex())
+	}
+	sort.Strings(ids)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s|%s|%s|%s|", bulkRequest.Action, bulkRequest.Reason, bulkRequest.Until.UTC().Format(time.RFC3339), bulkRequest.AssigneeId)
+	h.Write([]byte(strings.Join(ids, ",")))
+	return hex.EncodeToString(h.Sum(nil))
+}
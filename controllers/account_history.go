@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"UserManagementVer/middlewares"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (accountCon *AccountController) GetHistory(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Id không hợp lệ",
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// ?at=... trả về trạng thái của tài khoản tại thời điểm đó
+	if at := c.Query("at"); at != "" {
+		atTime, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "at phải theo định dạng RFC3339",
+			})
+			return
+		}
+		history, err := accountCon.historyService.At(ctx, objectId, atTime)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  http.StatusNotFound,
+				"message": "Không có phiên bản nào tại thời điểm này",
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status":    http.StatusOK,
+			"timestamp": time.Now(),
+			"message":   "Tìm thấy!",
+			"data":      history,
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	histories, total, err := accountCon.historyService.List(ctx, objectId, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data": gin.H{
+			"items": histories,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+func (accountCon *AccountController) RevertHistory(c *gin.Context) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Id không hợp lệ",
+		})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Version không hợp lệ",
+		})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	jwtCustomClaims, err := accountCon.jwtService.ExtractCustomClaims(authHeader)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	updatedByAccount, err := accountCon.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Không tìm thấy thông tin người update",
+		})
+		return
+	}
+
+	history, err := accountCon.historyService.GetVersion(ctx, objectId, version)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy phiên bản",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	oldAccount, err := accountCon.accountCollection.GetAccountById(ctx, objectId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không thấy tài khoản",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	update := repositories.AccountUpdate{}
+	changed := false
+	current := oldAccount.Snapshot()
+	for _, field := range models.RevertableFields {
+		value := snapshotValue(history.Snapshot[field])
+		if value == current[field] {
+			continue
+		}
+		if err := setRevertField(&update, field, value); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": err.Error(),
+			})
+			return
+		}
+		changed = true
+	}
+	if !changed {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Tài khoản đang giống với phiên bản này",
+		})
+		return
+	}
+	// Avatar cũ bị xóa khi tài khoản đổi ảnh, không khôi phục image_url trỏ tới file không còn
+	if update.ImageUrl != nil && *update.ImageUrl != "" {
+		exists, err := accountCon.avatarService.Exists(ctx, *update.ImageUrl)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": err.Error(),
+			})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  http.StatusConflict,
+				"message": "Avatar của phiên bản này đã bị xóa, không thể khôi phục",
+			})
+			return
+		}
+	}
+	update.UpdatedAt = repositories.Ptr(time.Now())
+	update.UpdatedBy = &updatedByAccount.Id
+	if err := accountCon.accountCollection.Update(ctx, repositories.ById(objectId), update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	newAccount, err := accountCon.accountCollection.GetAccountById(ctx, objectId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	revertHistory, err := accountCon.historyService.RecordRevert(ctx, oldAccount, newAccount, version, updatedByAccount.Id, c.GetString(middlewares.RequestIdKey))
+	if err != nil {
+		log.Println("Không thể ghi lịch sử tài khoản", objectId.Hex(), err)
+	}
+	event := auditEvent(c, models.AuditActionAccountRevert, models.AuditOutcomeSuccess, models.AccountTarget(oldAccount))
+	event.Metadata = bson.M{"version": version}
+	accountCon.auditService.Log(event)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã khôi phục tài khoản về phiên bản cũ",
+		"data":      revertHistory,
+	})
+}
+
+// recordHistory đọc lại tài khoản sau khi thay đổi và ghi phiên bản mới,
+// lỗi ghi lịch sử chỉ được log để không làm hỏng thao tác chính.
+func (accountCon *AccountController) recordHistory(c *gin.Context, action string, before *models.Account, accountId primitive.ObjectID, actorId primitive.ObjectID, extra ...models.FieldChange) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	after, err := accountCon.accountCollection.GetAccountById(ctx, accountId)
+	if err == nil {
+		_, err = accountCon.historyService.Record(ctx, action, before, after, actorId, c.GetString(middlewares.RequestIdKey), extra...)
+	}
+	if err != nil {
+		log.Println("Không thể ghi lịch sử tài khoản", accountId.Hex(), err)
+	}
+}
+
+func (accountCon *AccountController) recordBulkHistory(c *gin.Context, action string, before map[primitive.ObjectID]models.Account, accountIds []primitive.ObjectID, actorId primitive.ObjectID) {
+	if len(accountIds) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	accounts, err := accountCon.accountCollection.FindAll(ctx, repositories.AccountQuery{Ids: accountIds})
+	if err != nil {
+		log.Println("Không thể ghi lịch sử tài khoản", err)
+		return
+	}
+	for _, after := range accounts {
+		old := before[after.Id]
+		if _, err := accountCon.historyService.Record(ctx, action, &old, after, actorId, c.GetString(middlewares.RequestIdKey)); err != nil {
+			log.Println("Không thể ghi lịch sử tài khoản", after.Id.Hex(), err)
+		}
+	}
+}
+
+// currentAccountId lấy id tài khoản đang đăng nhập do middleware AuthorizeJWT gắn vào context
+func currentAccountId(c *gin.Context) primitive.ObjectID {
+	if value, ok := c.Get(middlewares.CurrentAccountKey); ok {
+		if account, ok := value.(models.Account); ok {
+			return account.Id
+		}
+	}
+	return primitive.NilObjectID
+}
+
+// snapshotValue chuyển giá trị đọc từ snapshot trong MongoDB về kiểu dùng trong Account.Snapshot
+func snapshotValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case primitive.DateTime:
+		return v.Time().UTC()
+	default:
+		return v
+	}
+}
+
+// setRevertField gán giá trị lấy từ snapshot vào trường tương ứng của update, nil nghĩa là xóa trường
+func setRevertField(update *repositories.AccountUpdate, field string, value interface{}) error {
+	text, _ := value.(string)
+	switch field {
+	case "name":
+		update.Name = &text
+	case "phone":
+		update.Phone = &text
+	case "image_url":
+		update.ImageUrl = &text
+	case "dob":
+		dob, _ := value.(time.Time)
+		update.Dob = &dob
+	case "managed_by":
+		managedBy, _ := value.(primitive.ObjectID)
+		update.ManagedBy = &managedBy
+	default:
+		return fmt.Errorf("Không thể khôi phục trường %s", field)
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package controllers
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/configs"
+	"UserManagementVer/middlewares"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/services"
+	"UserManagementVer/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	dobLayouts          = []string{"2006-01-02", "02/01/2006", time.RFC3339}
+	phoneMissingZeroReg = regexp.MustCompile(`^(3|5|7|8|9)\d{8}$`)
+)
+
+const (
+	// importJobHeartbeat là khoảng thời gian tối đa giữa hai lần lưu tiến độ của job đang chạy
+	importJobHeartbeat = time.Minute
+	// importJobStaleAfter là thời gian job running không cập nhật tiến độ thì bị coi là đã dừng
+	importJobStaleAfter = 10 * time.Minute
+)
+
+type importAccountRow struct {
+	Row     int
+	Account CreateAccount
+}
+
+type AccountImportController struct {
+	accountCollection   repositories.AccountRepository
+	importJobCollection *collections.ImportJobCollection
+	jwtService          *services.JwtService
+	historyService      *services.AccountHistoryService
+	auditService        *services.AuditService
+}
+
+func NewAccountImportController(accountCollection repositories.AccountRepository, importJobCollection *collections.ImportJobCollection, jwtService *services.JwtService, historyService *services.AccountHistoryService, auditService *services.AuditService) *AccountImportController {
+	return &AccountImportController{
+		accountCollection:   accountCollection,
+		importJobCollection: importJobCollection,
+		jwtService:          jwtService,
+		historyService:      historyService,
+		auditService:        auditService,
+	}
+}
+
+func (ic *AccountImportController) ImportAccounts(c *gin.Context) {
+	dryRun := c.Query("dry_run") == "true"
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Chưa có file được upload",
+		})
+		return
+	}
+
+	rows, err := utils.ReadImportRows(fileHeader, configs.AppConfig.Import.MaxRows)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "File không có dữ liệu",
+		})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	jwtCustomClaims, err := ic.jwtService.ExtractCustomClaims(authHeader)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	createdByAccount, err := ic.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Không tìm thấy thông tin người import",
+		})
+		return
+	}
+
+	validRows, rowErrors, err := ic.validateImportRows(ctx, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	job := models.ImportJob{
+		FileName:  filepath.Base(fileHeader.Filename),
+		DryRun:    dryRun,
+		Status:    models.ImportJobRunning,
+		Total:     len(rows),
+		Processed: len(rowErrors),
+		Failed:    len(rowErrors),
+		Errors:    rowErrors,
+		CreatedBy: createdByAccount.Id,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if dryRun {
+		// Dry-run chỉ kiểm tra dữ liệu, các dòng hợp lệ được tính là thành công
+		job.Status = models.ImportJobCompleted
+		job.Processed = job.Total
+		job.Succeeded = len(validRows)
+		job.FinishedAt = time.Now()
+	}
+	job.Id, err = ic.importJobCollection.Create(ctx, job)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	event := auditEvent(c, models.AuditActionAccountImport, models.AuditOutcomeSuccess, models.AuditTarget{Type: models.AuditTargetAccount})
+	event.Metadata = bson.M{"job_id": job.Id, "file_name": job.FileName, "dry_run": dryRun, "total": job.Total, "valid": len(validRows)}
+	ic.auditService.Log(event)
+
+	if dryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    http.StatusOK,
+			"timestamp": time.Now(),
+			"message":   "Kiểm tra file import thành công",
+			"data":      job,
+		})
+		return
+	}
+
+	// File lớn được xử lý nền, client theo dõi qua job id
+	if len(validRows) > configs.AppConfig.Import.SyncLimit {
+		go ic.runImport(job, validRows, c.GetString(middlewares.RequestIdKey))
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":    http.StatusAccepted,
+			"timestamp": time.Now(),
+			"message":   "Đang import tài khoản",
+			"data":      gin.H{"job_id": job.Id},
+		})
+		return
+	}
+
+	job = ic.runImport(job, validRows, c.GetString(middlewares.RequestIdKey))
+	if job.Status == models.ImportJobFailed {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":    http.StatusInternalServerError,
+			"timestamp": time.Now(),
+			"message":   job.Message,
+			"data":      job,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Import tài khoản hoàn tất",
+		"data":      job,
+	})
+}
+
+func (ic *AccountImportController) GetImportJob(c *gin.Context) {
+	job, ok := ic.findImportJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data":      job,
+	})
+}
+
+func (ic *AccountImportController) DownloadImportReport(c *gin.Context) {
+	job, ok := ic.findImportJob(c)
+	if !ok {
+		return
+	}
+
+	f := excelize.NewFile()
+	sheet := "Sheet1"
+	_ = f.SetSheetName(f.GetSheetName(0), sheet)
+	headers := []string{"Row", "Email", "Error"}
+	for i, h := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		_ = f.SetCellValue(sheet, cell, h)
+	}
+	for i, rowError := range job.Errors {
+		_ = f.SetCellValue(sheet, fmt.Sprintf("A%d", i+2), rowError.Row)
+		_ = f.SetCellValue(sheet, fmt.Sprintf("B%d", i+2), rowError.Email)
+		_ = f.SetCellValue(sheet, fmt.Sprintf("C%d", i+2), rowError.Message)
+	}
+	_ = f.SetColWidth(sheet, "B", "B", 30)
+	_ = f.SetColWidth(sheet, "C", "C", 60)
+
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="import_report_%s.xlsx"`, job.Id.Hex()))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Expires", "0")
+
+	if err := f.Write(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export excel"})
+		return
+	}
+}
+
+func (ic *AccountImportController) findImportJob(c *gin.Context) (models.ImportJob, bool) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Job id không hợp lệ",
+		})
+		return models.ImportJob{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// Job bị dừng giữa chừng (server restart...) không bao giờ tự kết thúc nên được đánh dấu failed khi đọc
+	if _, err := ic.importJobCollection.FailStale(ctx, time.Now().Add(-importJobStaleAfter), "Job import bị dừng giữa chừng"); err != nil {
+		log.Println("Không thể đánh dấu job import bị dừng", err)
+	}
+	job, err := ic.importJobCollection.GetById(ctx, objectId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy job import",
+		})
+		return job, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return job, false
+	}
+	return job, true
+}
+
+// validateImportRows kiểm tra từng dòng theo cùng quy tắc với CreateAccount:
+// định dạng dữ liệu, email trùng trong file và email đã tồn tại trong hệ thống.
+func (ic *AccountImportController) validateImportRows(ctx context.Context, rows []utils.ImportRow) ([]importAccountRow, []models.ImportRowError, error) {
+	validRows := []importAccountRow{}
+	rowErrors := []models.ImportRowError{}
+	seen := map[string]int{}
+	emails := []string{}
+
+	for _, row := range rows {
+		account := CreateAccount{
+			Name:     row.Values["name"],
+			Email:    strings.ToLower(row.Values["email"]),
+			Password: row.Values["password"],
+			Phone:    row.Values["phone"],
+		}
+		// Excel thường làm mất số 0 ở đầu số điện thoại
+		if phoneMissingZeroReg.MatchString(account.Phone) {
+			account.Phone = "0" + account.Phone
+		}
+		if dob := row.Values["dob"]; dob != "" {
+			parsed, err := parseDob(dob)
+			if err != nil {
+				rowErrors = append(rowErrors, models.ImportRowError{Row: row.Row, Email: account.Email, Message: err.Error()})
+				continue
+			}
+			account.Dob = parsed
+		}
+		if err := utils.HandlerValidation(utils.Validator.Struct(account)); len(err) > 0 {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row.Row, Email: account.Email, Message: "Lỗi định dạng: " + err})
+			continue
+		}
+		if firstRow, ok := seen[account.Email]; ok {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row.Row, Email: account.Email, Message: fmt.Sprintf("Email bị trùng với dòng %d", firstRow)})
+			continue
+		}
+		seen[account.Email] = row.Row
+		emails = append(emails, account.Email)
+		validRows = append(validRows, importAccountRow{Row: row.Row, Account: account})
+	}
+
+	if len(emails) == 0 {
+		return validRows, rowErrors, nil
+	}
+	existedAccounts, err := ic.accountCollection.FindAll(ctx, repositories.AccountQuery{Emails: emails})
+	if err != nil {
+		return nil, nil, err
+	}
+	existed := map[string]bool{}
+	for _, account := range existedAccounts {
+		existed[strings.ToLower(account.Email)] = true
+	}
+	filtered := []importAccountRow{}
+	for _, row := range validRows {
+		if existed[row.Account.Email] {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row.Row, Email: row.Account.Email, Message: "Email người dùng này đã tồn tại trong hệ thống"})
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+	return filtered, rowErrors, nil
+}
+
+// runImport tạo lần lượt các tài khoản hợp lệ và cập nhật tiến độ của job. Email trùng chỉ làm lỗi dòng đó,
+// lỗi khác của DB hoặc panic dừng import và chuyển job sang failed, các tài khoản đã tạo được giữ lại.
+func (ic *AccountImportController) runImport(job models.ImportJob, rows []importAccountRow, requestId string) (result models.ImportJob) {
+	const progressEvery = 50
+	lastSaved := time.Now()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Println("Job import bị lỗi", job.Id.Hex(), recovered)
+			job.Status = models.ImportJobFailed
+			job.Message = fmt.Sprintf("Import bị lỗi: %v", recovered)
+			job.FinishedAt = time.Now()
+			ic.saveImportJob(job)
+			result = job
+		}
+	}()
+
+	for i, row := range rows {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		account := models.Account{
+			Name:      row.Account.Name,
+			Email:     row.Account.Email,
+			Password:  row.Account.Password,
+			Phone:     row.Account.Phone,
+			Dob:       row.Account.Dob,
+			CreatedBy: job.CreatedBy,
+			Status:    models.StatusPending,
+		}
+		accountId, err := ic.accountCollection.Create(ctx, account)
+		if err == nil {
+			account.Id = accountId
+			if _, historyErr := ic.historyService.Record(ctx, models.HistoryActionCreate, nil, account, job.CreatedBy, requestId); historyErr != nil {
+				log.Println("Không thể ghi lịch sử tài khoản", accountId.Hex(), historyErr)
+			}
+		}
+		cancel()
+		if err != nil && !repositories.IsDuplicate(err) {
+			log.Println("Job import dừng do lỗi DB", job.Id.Hex(), err)
+			job.Status = models.ImportJobFailed
+			job.Message = fmt.Sprintf("Import dừng ở dòng %d: %s", row.Row, err.Error())
+			job.FinishedAt = time.Now()
+			ic.saveImportJob(job)
+			return job
+		}
+		job.Processed++
+		if err != nil {
+			job.Failed++
+			job.Errors = append(job.Errors, models.ImportRowError{Row: row.Row, Email: row.Account.Email, Message: "Email người dùng này đã tồn tại trong hệ thống"})
+		} else {
+			job.Succeeded++
+		}
+		// Lưu tiến độ định kỳ, updated_at cho biết job vẫn đang chạy
+		if (i+1)%progressEvery == 0 || time.Since(lastSaved) > importJobHeartbeat {
+			ic.saveImportJob(job)
+			lastSaved = time.Now()
+		}
+	}
+
+	job.Status = models.ImportJobCompleted
+	job.FinishedAt = time.Now()
+	ic.saveImportJob(job)
+	return job
+}
+
+func (ic *AccountImportController) saveImportJob(job models.ImportJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err := ic.importJobCollection.Update(ctx, job.Id, bson.M{
+		"$set": bson.M{
+			"status":      job.Status,
+			"processed":   job.Processed,
+			"succeeded":   job.Succeeded,
+			"failed":      job.Failed,
+			"errors":      job.Errors,
+			"message":     job.Message,
+			"updated_at":  time.Now(),
+			"finished_at": job.FinishedAt,
+		},
+	})
+	if err != nil {
+		log.Println("Không thể cập nhật job import", job.Id.Hex(), err)
+	}
+}
+
+func parseDob(value string) (time.Time, error) {
+	for _, layout := range dobLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("dob %s không đúng định dạng YYYY-MM-DD", value)
+}
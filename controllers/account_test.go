@@ -0,0 +1,277 @@
+package controllers_test
+
+import (
+	"UserManagementVer/configs"
+	"UserManagementVer/controllers"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/utils"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCreateAccount(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+
+	request := controllers.CreateAccount{
+		Name:     "Chi",
+		Email:    "chi@example.com",
+		Password: "Chi@12345",
+		Phone:    "0912345678",
+	}
+	status, response := server.do(t, http.MethodPost, "/api/v1/accounts/add", token, request)
+	expectStatus(t, status, http.StatusCreated, response)
+
+	created, err := server.accounts.Find(context.Background(), repositories.AccountQuery{Email: "chi@example.com", WithPassword: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != models.StatusPending || created.CreatedBy != admin.Id {
+		t.Fatalf("tài khoản vừa tạo = %+v", created)
+	}
+	if !utils.CheckPassword(created.Password, "Chi@12345") {
+		t.Fatal("mật khẩu phải được hash khi lưu")
+	}
+
+	status, response = server.do(t, http.MethodPost, "/api/v1/accounts/add", token, request)
+	expectStatus(t, status, http.StatusBadRequest, response)
+
+	request.Email = "dung@example.com"
+	request.Phone = "12345"
+	status, response = server.do(t, http.MethodPost, "/api/v1/accounts/add", token, request)
+	expectStatus(t, status, http.StatusBadRequest, response)
+}
+
+func TestUpdateAccountKeepsMissingFields(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	account := server.seedAccount(t, models.Account{Name: "Chi", Email: "chi@example.com", Phone: "0912345678"})
+
+	status, response := server.do(t, http.MethodPatch, "/api/v1/accounts/"+account.Id.Hex(), server.token(t, admin.Email), map[string]string{
+		"name": "Chi Nguyễn",
+	})
+	expectStatus(t, status, http.StatusOK, response)
+
+	updated, err := server.accounts.GetAccountById(context.Background(), account.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Chi Nguyễn" || updated.Phone != "0912345678" || updated.UpdatedBy != admin.Id {
+		t.Fatalf("tài khoản sau khi cập nhật = %+v", updated)
+	}
+
+	status, response = server.do(t, http.MethodPatch, "/api/v1/accounts/"+account.Id.Hex(), server.token(t, admin.Email), map[string]string{
+		"role": "admin",
+	})
+	expectStatus(t, status, http.StatusBadRequest, response)
+}
+
+func TestFindAccountById(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+
+	status, response := server.do(t, http.MethodGet, "/api/v1/accounts/"+admin.Id.Hex()+"/detail", token, nil)
+	expectStatus(t, status, http.StatusOK, response)
+	var found models.Account
+	decodeData(t, response, &found)
+	if found.Email != admin.Email {
+		t.Fatalf("email = %q, muốn %q", found.Email, admin.Email)
+	}
+
+	status, response = server.do(t, http.MethodGet, "/api/v1/accounts/"+primitive.NewObjectID().Hex()+"/detail", token, nil)
+	expectStatus(t, status, http.StatusNotFound, response)
+}
+
+func TestChangeStatus(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	account := server.seedAccount(t, models.Account{Name: "Chi", Email: "chi@example.com"})
+	token := server.token(t, admin.Email)
+	path := "/api/v1/accounts/" + account.Id.Hex() + "/status"
+
+	status, response := server.do(t, http.MethodPatch, path, token, controllers.ChangeStatusRequest{Action: models.ActionSuspend})
+	expectStatus(t, status, http.StatusBadRequest, response)
+
+	until := time.Now().Add(24 * time.Hour)
+	status, response = server.do(t, http.MethodPatch, path, token, controllers.ChangeStatusRequest{
+		Action: models.ActionSuspend,
+		Reason: "Vi phạm điều khoản",
+		Until:  until,
+	})
+	expectStatus(t, status, http.StatusOK, response)
+
+	suspended, _ := server.accounts.GetAccountById(context.Background(), account.Id)
+	if suspended.Status != models.StatusSuspended || suspended.StatusReason != "Vi phạm điều khoản" || len(suspended.StatusHistory) != 1 {
+		t.Fatalf("tài khoản sau khi tạm ngưng = %+v", suspended)
+	}
+
+	// Token của tài khoản bị tạm ngưng không còn dùng được
+	status, response = server.do(t, http.MethodGet, "/api/v1/accounts/"+account.Id.Hex()+"/detail", server.token(t, account.Email), nil)
+	expectStatus(t, status, http.StatusForbidden, response)
+
+	// Kích hoạt lại xóa lý do và thời hạn tạm ngưng
+	status, response = server.do(t, http.MethodPatch, path, token, controllers.ChangeStatusRequest{Action: models.ActionActivate})
+	expectStatus(t, status, http.StatusOK, response)
+	activated, _ := server.accounts.GetAccountById(context.Background(), account.Id)
+	if activated.Status != models.StatusActive || activated.StatusReason != "" || !activated.SuspendedUntil.IsZero() || len(activated.StatusHistory) != 2 {
+		t.Fatalf("tài khoản sau khi kích hoạt = %+v", activated)
+	}
+
+	status, response = server.do(t, http.MethodPatch, path, token, controllers.ChangeStatusRequest{Action: models.ActionRestore})
+	expectStatus(t, status, http.StatusConflict, response)
+
+	status, response = server.do(t, http.MethodPatch, "/api/v1/accounts/"+admin.Id.Hex()+"/status", token, controllers.ChangeStatusRequest{Action: models.ActionLock})
+	expectStatus(t, status, http.StatusBadRequest, response)
+}
+
+func TestDeleteAndRestoreAccount(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	account := server.seedAccount(t, models.Account{Name: "Chi", Email: "chi@example.com"})
+	token := server.token(t, admin.Email)
+	path := "/api/v1/accounts/" + account.Id.Hex() + "/status"
+
+	status, response := server.do(t, http.MethodPatch, path, token, controllers.ChangeStatusRequest{Action: models.ActionDelete})
+	expectStatus(t, status, http.StatusOK, response)
+	deleted, _ := server.accounts.GetAccountById(context.Background(), account.Id)
+	if deleted.DeletedAt.IsZero() || deleted.DeletedBy != admin.Id {
+		t.Fatalf("tài khoản sau khi xóa = %+v", deleted)
+	}
+
+	status, response = server.do(t, http.MethodGet, "/api/v1/accounts/search?keyword=chi", token, nil)
+	expectStatus(t, status, http.StatusNotFound, response)
+
+	status, response = server.do(t, http.MethodPatch, path, token, controllers.ChangeStatusRequest{Action: models.ActionRestore})
+	expectStatus(t, status, http.StatusOK, response)
+	restored, _ := server.accounts.GetAccountById(context.Background(), account.Id)
+	if restored.Status != models.StatusActive || !restored.DeletedAt.IsZero() || !restored.DeletedBy.IsZero() {
+		t.Fatalf("tài khoản sau khi khôi phục = %+v", restored)
+	}
+}
+
+// Job purge đã nhận xử lý tài khoản (deleted -> purging) thì không thể khôi phục nữa
+func TestRestoreDuringPurge(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	account := server.seedAccount(t, models.Account{Name: "Chi", Email: "chi@example.com", Status: models.StatusDeleted, DeletedAt: time.Now()})
+	token := server.token(t, admin.Email)
+
+	claim := repositories.AccountQuery{Ids: []primitive.ObjectID{account.Id}, Status: models.StatusDeleted}
+	if err := server.accounts.Update(context.Background(), claim, repositories.AccountUpdate{Status: repositories.Ptr(models.StatusPurging)}); err != nil {
+		t.Fatalf("nhận xử lý purge: %v", err)
+	}
+
+	status, response := server.do(t, http.MethodPatch, "/api/v1/accounts/"+account.Id.Hex()+"/status", token, controllers.ChangeStatusRequest{Action: models.ActionRestore})
+	expectStatus(t, status, http.StatusConflict, response)
+	if purging, _ := server.accounts.GetAccountById(context.Background(), account.Id); purging.Status != models.StatusPurging {
+		t.Fatalf("tài khoản đang purge bị khôi phục: %+v", purging)
+	}
+}
+
+func TestSearchAccount(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	server.seedAccount(t, models.Account{Name: "Trần Chi", Email: "chi@example.com"})
+	server.seedAccount(t, models.Account{Name: "Lê Dũng", Email: "dung@example.com"})
+	server.seedAccount(t, models.Account{Name: "Chi Cũ", Email: "old@example.com", Status: models.StatusDeleted, DeletedAt: time.Now()})
+	token := server.token(t, admin.Email)
+
+	status, response := server.do(t, http.MethodGet, "/api/v1/accounts/search?keyword=CHI", token, nil)
+	expectStatus(t, status, http.StatusOK, response)
+	var accounts []controllers.AccountResponse
+	decodeData(t, response, &accounts)
+	if len(accounts) != 1 || accounts[0].Email != "chi@example.com" {
+		t.Fatalf("kết quả tìm kiếm = %+v", accounts)
+	}
+
+	// Từ khóa được tìm nguyên văn, không phải biểu thức chính quy
+	status, response = server.do(t, http.MethodGet, "/api/v1/accounts/search?keyword=.*", token, nil)
+	expectStatus(t, status, http.StatusNotFound, response)
+}
+
+func TestRestorePassword(t *testing.T) {
+	server := newTestServer(t)
+	account := server.seedAccount(t, models.Account{Name: "Chi", Email: "chi@example.com"})
+	token := server.token(t, account.Email)
+	path := "/api/v1/accounts/" + account.Id.Hex() + "/forgot-password"
+
+	status, response := server.do(t, http.MethodPost, path, token, controllers.PasswordUpdateRequest{
+		OldPassword:     "wrong",
+		NewPassword:     "New@12345",
+		ConfirmPassword: "New@12345",
+	})
+	expectStatus(t, status, http.StatusBadRequest, response)
+
+	status, response = server.do(t, http.MethodPost, path, token, controllers.PasswordUpdateRequest{
+		OldPassword:     testPassword,
+		NewPassword:     "New@12345",
+		ConfirmPassword: "New@12345",
+	})
+	expectStatus(t, status, http.StatusOK, response)
+
+	updated, _ := server.accounts.Find(context.Background(), repositories.AccountQuery{Ids: []primitive.ObjectID{account.Id}, WithPassword: true})
+	if !utils.CheckPassword(updated.Password, "New@12345") {
+		t.Fatal("mật khẩu mới chưa được lưu")
+	}
+}
+
+func TestBulkAccounts(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	chi := server.seedAccount(t, models.Account{Name: "Chi", Email: "chi@example.com"})
+	dung := server.seedAccount(t, models.Account{Name: "Dũng", Email: "dung@example.com"})
+	locked := server.seedAccount(t, models.Account{Name: "Em", Email: "em@example.com", Status: models.StatusLocked})
+	token := server.token(t, admin.Email)
+
+	status, response := server.do(t, http.MethodPost, "/api/v1/accounts/bulk", token, controllers.BulkAccountRequest{
+		Action: models.ActionLock,
+		Ids:    []string{chi.Id.Hex(), dung.Id.Hex(), locked.Id.Hex(), admin.Id.Hex(), "not-an-id"},
+		Reason: "Kiểm tra",
+	})
+	expectStatus(t, status, http.StatusOK, response)
+	var data struct {
+		Matched   int                          `json:"matched"`
+		Succeeded int                          `json:"succeeded"`
+		Results   []controllers.BulkItemResult `json:"results"`
+	}
+	decodeData(t, response, &data)
+	if data.Matched != 4 || data.Succeeded != 2 || len(data.Results) != 5 {
+		t.Fatalf("kết quả bulk = %+v", data)
+	}
+	for _, account := range []models.Account{chi, dung} {
+		stored, _ := server.accounts.GetAccountById(context.Background(), account.Id)
+		if stored.Status != models.StatusLocked {
+			t.Fatalf("%s có trạng thái %q, muốn locked", stored.Email, stored.Status)
+		}
+	}
+
+	status, response = server.do(t, http.MethodPost, "/api/v1/accounts/bulk", token, controllers.BulkAccountRequest{
+		Action:     controllers.ActionReassign,
+		Filter:     &controllers.BulkAccountFilter{Status: models.StatusLocked},
+		AssigneeId: admin.Id.Hex(),
+	})
+	expectStatus(t, status, http.StatusOK, response)
+	decodeData(t, response, &data)
+	if data.Matched != 3 || data.Succeeded != 3 {
+		t.Fatalf("kết quả reassign = %+v", data)
+	}
+	reassigned, _ := server.accounts.GetAccountById(context.Background(), locked.Id)
+	if reassigned.ManagedBy != admin.Id {
+		t.Fatalf("managed_by = %s, muốn %s", reassigned.ManagedBy.Hex(), admin.Id.Hex())
+	}
+	// Filter khớp nhiều hơn MaxItems bị từ chối trước khi thao tác
+	configs.AppConfig.Bulk.MaxItems = 2
+	status, response = server.do(t, http.MethodPost, "/api/v1/accounts/bulk", token, controllers.BulkAccountRequest{
+		Action:     controllers.ActionReassign,
+		Filter:     &controllers.BulkAccountFilter{Status: models.StatusLocked},
+		AssigneeId: admin.Id.Hex(),
+	})
+	expectStatus(t, status, http.StatusBadRequest, response)
+}
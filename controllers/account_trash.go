@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/utils"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HardDeleteRequest struct {
+	Ids []string `json:"ids" validate:"required,min=1"`
+}
+
+type AccountActorResponse struct {
+	Id    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type TrashAccountResponse struct {
+	Id        string                `json:"id"`
+	Email     string                `json:"email,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	DeletedAt time.Time             `json:"deleted_at"`
+	DeletedBy *AccountActorResponse `json:"deleted_by,omitempty"`
+	PurgeOn   *time.Time            `json:"purge_on,omitempty"`
+}
+
+func (accountCon *AccountController) ListTrash(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := repositories.AccountQuery{
+		Status:  models.StatusDeleted,
+		Keyword: c.Query("keyword"),
+		Sort:    repositories.SortDeletedAtDesc,
+		Skip:    (page - 1) * limit,
+		Limit:   limit,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := accountCon.accountCollection.Count(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	accounts, err := accountCon.accountCollection.FindAll(ctx, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	retentionDays, err := accountCon.purgeService.RetentionDays(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Lấy thông tin người xóa
+	deleterIds := []primitive.ObjectID{}
+	for _, account := range accounts {
+		if !account.DeletedBy.IsZero() {
+			deleterIds = append(deleterIds, account.DeletedBy)
+		}
+	}
+	deleters := map[primitive.ObjectID]models.Account{}
+	if len(deleterIds) > 0 {
+		deleterAccounts, err := accountCon.accountCollection.FindAll(ctx, repositories.AccountQuery{Ids: deleterIds})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": err.Error(),
+			})
+			return
+		}
+		for _, deleter := range deleterAccounts {
+			deleters[deleter.Id] = deleter
+		}
+	}
+
+	items := []TrashAccountResponse{}
+	for _, account := range accounts {
+		item := TrashAccountResponse{
+			Id:        account.Id.Hex(),
+			Email:     account.Email,
+			Name:      account.Name,
+			DeletedAt: account.DeletedAt,
+		}
+		if !account.DeletedBy.IsZero() {
+			deleter := deleters[account.DeletedBy]
+			item.DeletedBy = &AccountActorResponse{
+				Id:    account.DeletedBy.Hex(),
+				Name:  deleter.Name,
+				Email: deleter.Email,
+			}
+		}
+		// Job purge chạy định kỳ nên tài khoản bị xóa vào hoặc ngay sau purge_on
+		if retentionDays > 0 && !account.DeletedAt.IsZero() {
+			purgeOn := account.DeletedAt.AddDate(0, 0, retentionDays)
+			item.PurgeOn = &purgeOn
+		}
+		items = append(items, item)
+	}
+
+	retention := gin.H{
+		"enabled": retentionDays > 0,
+		"days":    retentionDays,
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data": gin.H{
+			"items":     items,
+			"total":     total,
+			"page":      page,
+			"limit":     limit,
+			"retention": retention,
+		},
+	})
+}
+
+func (accountCon *AccountController) HardDelete(c *gin.Context) {
+	var hardDeleteRequest HardDeleteRequest
+	if err := c.ShouldBindJSON(&hardDeleteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := utils.HandlerValidation(utils.Validator.Struct(hardDeleteRequest)); len(err) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Lỗi định dạng: " + err,
+		})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	jwtCustomClaims, err := accountCon.jwtService.ExtractCustomClaims(authHeader)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	results := []BulkItemResult{}
+	objectIds := []primitive.ObjectID{}
+	for _, id := range hardDeleteRequest.Ids {
+		objectId, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			results = append(results, BulkItemResult{Id: id, Message: "Id không hợp lệ"})
+			continue
+		}
+		objectIds = append(objectIds, objectId)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	purgedByAccount, err := accountCon.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Không tìm thấy thông tin người xóa",
+		})
+		return
+	}
+
+	// Chỉ xóa vĩnh viễn các tài khoản đang nằm trong thùng rác
+	trashAccounts, err := accountCon.accountCollection.FindAll(ctx, repositories.AccountQuery{
+		Ids:    objectIds,
+		Status: models.StatusDeleted,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	inTrash := map[primitive.ObjectID]bool{}
+	for _, account := range trashAccounts {
+		inTrash[account.Id] = true
+	}
+	for _, objectId := range objectIds {
+		if !inTrash[objectId] {
+			results = append(results, BulkItemResult{Id: objectId.Hex(), Message: "Tài khoản không nằm trong thùng rác"})
+		}
+	}
+
+	deleted := 0
+	for _, account := range trashAccounts {
+		event := auditEvent(c, models.AuditActionAccountPurge, models.AuditOutcomeSuccess, models.AccountTarget(account))
+		event.Metadata = bson.M{"trigger": models.PurgeTriggerManual}
+		if _, err := accountCon.purgeService.PurgeAccount(ctx, account, models.PurgeTriggerManual, purgedByAccount.Id); err != nil {
+			event.Outcome = models.AuditOutcomeFailure
+			event.Reason = err.Error()
+			accountCon.auditService.Log(event)
+			results = append(results, BulkItemResult{Id: account.Id.Hex(), Message: err.Error()})
+			continue
+		}
+		accountCon.auditService.Log(event)
+		deleted++
+		results = append(results, BulkItemResult{Id: account.Id.Hex(), Success: true})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã xóa vĩnh viễn tài khoản!",
+		"data": gin.H{
+			"deleted": deleted,
+			"results": results,
+		},
+	})
+}
@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"UserManagementVer/services"
+	"UserManagementVer/utils"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upload avatar có thể tiếp tục theo giao thức tus 1.0 (https://tus.io/protocols/resumable-upload),
+// hỗ trợ các extension creation, termination và expiration
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+	// tusContentType là Content-Type bắt buộc của request PATCH
+	tusContentType = "application/offset+octet-stream"
+)
+
+// TusOptions trả về thông tin server tus, không cần đăng nhập và không kiểm tra Tus-Resumable
+func (ac *AccountController) TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(ac.tusService.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// CreateTusUpload tạo phiên upload với Upload-Length, tên file lấy từ metadata filename
+func (ac *AccountController) CreateTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	id := c.Param("id")
+	objectId, _ := primitive.ObjectIDFromHex(id)
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Upload-Length không hợp lệ",
+		})
+		return
+	}
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Upload-Metadata không hợp lệ",
+		})
+		return
+	}
+	if err := utils.CheckValidFileName(metadata["filename"]); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := ac.accountCollection.GetAccountById(ctx, objectId); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy",
+		})
+		return
+	}
+	upload, err := ac.tusService.Create(ctx, objectId, length, metadata)
+	if errors.Is(err, services.ErrUploadTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"status":  http.StatusRequestEntityTooLarge,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Println("Không thể tạo phiên upload", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể tạo phiên upload",
+		})
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.Id)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.JSON(http.StatusCreated, gin.H{
+		"status":    http.StatusCreated,
+		"timestamp": time.Now(),
+		"message":   "Tạo phiên upload thành công",
+		"data":      gin.H{"id": upload.Id, "expires_at": upload.ExpiresAt},
+	})
+}
+
+// HeadTusUpload trả về Upload-Offset để client biết cần gửi tiếp từ byte nào
+func (ac *AccountController) HeadTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	objectId, _ := primitive.ObjectIDFromHex(c.Param("id"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	upload, err := ac.tusService.Get(ctx, objectId, c.Param("uploadId"))
+	if err != nil {
+		// HEAD không có body nên chỉ trả mã lỗi
+		c.Status(tusErrorStatus(err))
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload ghi tiếp dữ liệu tại Upload-Offset. Khi nhận đủ Upload-Length byte, file được kiểm tra
+// và lưu làm avatar như UploadImage; lỗi lưu tạm thời (5xx) giữ lại phiên để client gửi lại PATCH rỗng.
+func (ac *AccountController) PatchTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	if c.ContentType() != tusContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"status":  http.StatusUnsupportedMediaType,
+			"message": "Content-Type phải là " + tusContentType,
+		})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Upload-Offset không hợp lệ",
+		})
+		return
+	}
+	objectId, _ := primitive.ObjectIDFromHex(c.Param("id"))
+	uploadId := c.Param("uploadId")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	upload, err := ac.tusService.Get(ctx, objectId, uploadId)
+	cancel()
+	if err != nil {
+		tusError(c, err)
+		return
+	}
+	if offset != upload.Offset {
+		tusError(c, services.ErrOffsetMismatch)
+		return
+	}
+
+	// Đọc body không giới hạn thời gian vì mạng chậm là lý do dùng tus, chỉ đọc tối đa phần còn thiếu + 1 byte
+	// để phát hiện client gửi thừa. Kết nối đứt giữa chừng thì vẫn lưu phần đã nhận.
+	data, readErr := io.ReadAll(io.LimitReader(c.Request.Body, upload.Length-upload.Offset+1))
+	if int64(len(data)) > upload.Length-upload.Offset {
+		tusError(c, services.ErrUploadTooLarge)
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	upload, err = ac.tusService.Append(ctx, objectId, uploadId, offset, data)
+	if err != nil {
+		tusError(c, err)
+		return
+	}
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if readErr != nil {
+		log.Println("Kết nối upload bị ngắt", uploadId, readErr)
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if upload.Offset < upload.Length {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	if status := ac.completeTusUpload(ctx, c, upload); status == http.StatusOK {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// completeTusUpload kiểm tra nội dung và thay avatar bằng file đã nhận đủ, trả mã HTTP như replaceAvatar
+func (ac *AccountController) completeTusUpload(ctx context.Context, c *gin.Context, upload services.TusUpload) int {
+	data, err := ac.tusService.Open(ctx, upload)
+	if err != nil {
+		log.Println("Không thể đọc phiên upload", upload.Id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể đọc file đã upload",
+		})
+		return http.StatusInternalServerError
+	}
+	status := http.StatusBadRequest
+	if err := utils.CheckValidContent(data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+	} else if oldAccount, err := ac.accountCollection.GetAccountById(ctx, upload.AccountId); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy",
+		})
+		status = http.StatusNotFound
+	} else {
+		_, status = ac.replaceAvatar(ctx, c, oldAccount, bytes.NewReader(data))
+	}
+	// File không hợp lệ thì gửi lại cũng không thành công nên phiên bị hủy ngay
+	if status < http.StatusInternalServerError {
+		if err := ac.tusService.Delete(ctx, upload.AccountId, upload.Id); err != nil {
+			log.Println("Không thể xóa phiên upload", upload.Id, err)
+		}
+	}
+	return status
+}
+
+// DeleteTusUpload hủy phiên upload và xóa phần đã nhận (tus termination)
+func (ac *AccountController) DeleteTusUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+	objectId, _ := primitive.ObjectIDFromHex(c.Param("id"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := ac.tusService.Delete(ctx, objectId, c.Param("uploadId")); err != nil {
+		tusError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// tusResumable đặt header Tus-Resumable cho response và từ chối client dùng phiên bản giao thức khác
+func tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") == tusVersion {
+		return true
+	}
+	c.Header("Tus-Version", tusVersion)
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"status":  http.StatusPreconditionFailed,
+		"message": "Tus-Resumable phải là " + tusVersion,
+	})
+	return false
+}
+
+func tusError(c *gin.Context, err error) {
+	status := tusErrorStatus(err)
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		log.Println("Lỗi phiên upload", c.Param("uploadId"), err)
+		message = "Không thể xử lý phiên upload"
+	}
+	c.JSON(status, gin.H{
+		"status":  status,
+		"message": message,
+	})
+}
+
+func tusErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUploadNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUploadExpired):
+		return http.StatusGone
+	case errors.Is(err, services.ErrOffsetMismatch):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInternalServerError
+}
+
+// parseTusMetadata đọc Upload-Metadata dạng "key base64,key2 base64", key không có giá trị được phép
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("metadata thiếu key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
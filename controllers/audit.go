@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"UserManagementVer/middlewares"
+	"UserManagementVer/models"
+	"UserManagementVer/services"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditController struct {
+	auditService *services.AuditService
+}
+
+func NewAuditController(auditService *services.AuditService) *AuditController {
+	return &AuditController{auditService: auditService}
+}
+
+func (ac *AuditController) ListEvents(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	filter := services.AuditFilter{
+		Action:     c.Query("action"),
+		Outcome:    c.Query("outcome"),
+		TargetType: c.Query("target_type"),
+		TargetId:   c.Query("target_id"),
+		Ip:         c.Query("ip"),
+		RequestId:  c.Query("request_id"),
+	}
+	if actorId := c.Query("actor_id"); actorId != "" {
+		objectId, err := primitive.ObjectIDFromHex(actorId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  http.StatusBadRequest,
+				"message": "actor_id không hợp lệ",
+			})
+			return
+		}
+		filter.ActorId = objectId
+	}
+	for key, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := c.Query(key); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"status":  http.StatusBadRequest,
+					"message": key + " phải theo định dạng RFC3339",
+				})
+				return
+			}
+			*value = parsed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	events, total, err := ac.auditService.List(ctx, filter, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data": gin.H{
+			"items": events,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+// VerifyChain kiểm tra chuỗi hash của audit log, trả về mắt xích hỏng đầu tiên nếu có
+func (ac *AuditController) VerifyChain(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	result, err := ac.auditService.Verify(ctx, services.AuditVerifyOptions{})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	message := "Chuỗi audit log toàn vẹn"
+	if !result.Valid {
+		message = "Chuỗi audit log đã bị thay đổi"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   message,
+		"data":      result,
+	})
+}
+
+// auditEvent tạo sự kiện audit kèm thông tin của request: IP, user agent, request id
+// và người thực hiện nếu request đã qua AuthorizeJWT.
+func auditEvent(c *gin.Context, action string, outcome string, target models.AuditTarget) models.AuditEvent {
+	event := models.AuditEvent{
+		Action:    action,
+		Outcome:   outcome,
+		Target:    target,
+		Ip:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestId: c.GetString(middlewares.RequestIdKey),
+	}
+	if value, ok := c.Get(middlewares.CurrentAccountKey); ok {
+		if account, ok := value.(models.Account); ok {
+			event.Actor = models.AuditActor{Id: account.Id, Email: account.Email}
+		}
+	}
+	return event
+}
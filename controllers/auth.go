@@ -1,9 +1,9 @@
 package controllers
 
 import (
-	"UserManagementVer/collections"
 	"UserManagementVer/configs"
 	"UserManagementVer/models"
+	"UserManagementVer/repositories"
 	"UserManagementVer/services"
 	"UserManagementVer/utils"
 	"context"
@@ -14,22 +14,22 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type AuthController struct {
-	sessionCollection *collections.SessionCollection
-	accountCollection *collections.AccountCollection
+	sessionCollection repositories.SessionRepository
+	accountCollection repositories.AccountRepository
 	emailService      *services.EmailService
 	jwtService        *services.JwtService
+	auditService      *services.AuditService
 }
 type LoginRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 }
 
-func NewAuthController(sessionController *collections.SessionCollection, accountController *collections.AccountCollection, emailService *services.EmailService, jwtService *services.JwtService) *AuthController {
-	return &AuthController{sessionCollection: sessionController, accountCollection: accountController, emailService: emailService, jwtService: jwtService}
+func NewAuthController(sessionController repositories.SessionRepository, accountController repositories.AccountRepository, emailService *services.EmailService, jwtService *services.JwtService, auditService *services.AuditService) *AuthController {
+	return &AuthController{sessionCollection: sessionController, accountCollection: accountController, emailService: emailService, jwtService: jwtService, auditService: auditService}
 }
 
 var MaxDevice int = 1
@@ -56,25 +56,40 @@ func (auth *AuthController) Login(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	account, err := auth.accountCollection.Find(ctx, bson.M{"email": loginRequest.Email})
+	account, err := auth.accountCollection.Find(ctx, repositories.AccountQuery{Email: loginRequest.Email, WithPassword: true})
+	loginEvent := auditEvent(c, models.AuditActionLogin, models.AuditOutcomeSuccess, models.AccountTarget(account))
+	loginEvent.Actor = models.AuditActor{Id: account.Id, Email: loginRequest.Email}
+	loginEvent.Target.Label = loginRequest.Email
+	loginEvent.Metadata = bson.M{"device_id": deviceId}
 	if errors.Is(err, mongo.ErrNoDocuments) || !utils.CheckPassword(account.Password, loginRequest.Password) {
+		loginEvent.Outcome = models.AuditOutcomeFailure
+		loginEvent.Reason = "Sai tài khoản hoặc mật khẩu"
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			loginEvent.Target = models.AuditTarget{Type: models.AuditTargetAccount, Label: loginRequest.Email}
+		}
+		auth.auditService.Log(loginEvent)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":   http.StatusBadRequest,
 			"messsage": "tài khoản hoặc mật khẩu không chính xác",
 		})
 		return
 	}
-	//Lấy danh sách các deviceId cùng đăng nhập với user
-	filer := bson.M{
-		"user_id":        account.Id,
-		"trusted_device": true,
-		"device_id": bson.M{
-			"$ne": deviceId,
-		},
+	if err := account.CheckCanLogin(time.Now()); err != nil {
+		loginEvent.Outcome = models.AuditOutcomeDenied
+		loginEvent.Reason = err.Error()
+		auth.auditService.Log(loginEvent)
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  http.StatusForbidden,
+			"message": err.Error(),
+		})
+		return
 	}
-
-	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
-	loginAccounts, _ := auth.sessionCollection.Find(ctx, filer, opts)
+	//Lấy danh sách các deviceId cùng đăng nhập với user
+	loginAccounts, _ := auth.sessionCollection.Find(ctx, repositories.SessionQuery{
+		UserId:          account.Id,
+		TrustedOnly:     true,
+		ExcludeDeviceId: deviceId,
+	})
 
 	countAccount := len(loginAccounts)
 	if countAccount >= MaxDevice {
@@ -82,6 +97,10 @@ func (auth *AuthController) Login(c *gin.Context) {
 		oldestAccount, _ := auth.accountCollection.GetAccountById(ctx, loginAccounts[0].UserId)
 		auth.emailService.SendNewDeviceAlert(oldestAccount.Email, deviceId, time.Now().Format("2006-01-02"))
 		approvedToken, _, _ := auth.jwtService.GenerateJwt(account.Email, configs.AppConfig.Jwt.JwtAprrovedTokenExpirationTime, "approved")
+		alertEvent := auditEvent(c, models.AuditActionNewDeviceAlert, models.AuditOutcomeSuccess, models.AccountTarget(account))
+		alertEvent.Actor = loginEvent.Actor
+		alertEvent.Metadata = bson.M{"device_id": deviceId, "notified_email": oldestAccount.Email}
+		auth.auditService.Log(alertEvent)
 
 		_, err := auth.sessionCollection.FindAndUpdate(ctx, models.Session{
 			ExpiresAt:     time.Time{},
@@ -137,6 +156,7 @@ func (auth *AuthController) Login(c *gin.Context) {
 		DeviceId:      deviceId,
 		ApprovedToken: "",
 	})
+	auth.auditService.Log(loginEvent)
 
 	c.JSON(http.StatusOK, bson.M{
 		"status":    int(http.StatusOK),
@@ -176,9 +196,22 @@ func (auth *AuthController) ConfirmLogin(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if confirm == "true" {
-		filter := bson.M{
-			"approved_token": approvedToken,
+		account, err := auth.accountCollection.Find(ctx, repositories.ByEmail(approvedClaims.Email))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  http.StatusUnauthorized,
+				"message": "Không tìm thấy tài khoản",
+			})
+			return
 		}
+		if err := account.CheckCanLogin(time.Now()); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  http.StatusForbidden,
+				"message": err.Error(),
+			})
+			return
+		}
+		filter := repositories.SessionQuery{ApprovedToken: approvedToken}
 		existsSession, checkExists := auth.sessionCollection.FindOne(ctx, filter)
 		if errors.Is(checkExists, mongo.ErrNoDocuments) {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -187,16 +220,11 @@ func (auth *AuthController) ConfirmLogin(c *gin.Context) {
 			})
 			return
 		}
-		filter = bson.M{
-			"user_id":        existsSession.UserId,
-			"trusted_device": true,
-			"device_id": bson.M{
-				"$ne": existsSession.DeviceId,
-			},
-		}
-		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
-
-		sessions, _ := auth.sessionCollection.Find(ctx, filter, opts)
+		sessions, _ := auth.sessionCollection.Find(ctx, repositories.SessionQuery{
+			UserId:          existsSession.UserId,
+			TrustedOnly:     true,
+			ExcludeDeviceId: existsSession.DeviceId,
+		})
 
 		if len(sessions) == 0 {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -235,10 +263,7 @@ func (auth *AuthController) ConfirmLogin(c *gin.Context) {
 			return
 		}
 		//Xóa session cũ nhất
-		filter = bson.M{
-			"_id": oldestAccount.Id,
-		}
-		err = auth.sessionCollection.DeleteSession(ctx, filter)
+		err = auth.sessionCollection.DeleteSession(ctx, repositories.SessionQuery{Id: oldestAccount.Id})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"status":  http.StatusInternalServerError,
@@ -254,6 +279,10 @@ func (auth *AuthController) ConfirmLogin(c *gin.Context) {
 			})
 			return
 		}
+		approveEvent := auditEvent(c, models.AuditActionLoginApprove, models.AuditOutcomeSuccess, models.AccountTarget(account))
+		approveEvent.Actor = models.AuditActor{Id: account.Id, Email: account.Email}
+		approveEvent.Metadata = bson.M{"device_id": existsSession.DeviceId, "revoked_session_id": oldestAccount.Id}
+		auth.auditService.Log(approveEvent)
 		c.JSON(http.StatusOK, bson.M{
 			"status":    http.StatusOK,
 			"message":   "Login account successfully",
@@ -269,9 +298,8 @@ func (auth *AuthController) ConfirmLogin(c *gin.Context) {
 	} else {
 
 		//Xóa session bị từ chối
-		filter := bson.M{
-			"approved_token": approvedToken,
-		}
+		filter := repositories.SessionQuery{ApprovedToken: approvedToken}
+		deniedSession, _ := auth.sessionCollection.FindOne(ctx, filter)
 		err = auth.sessionCollection.DeleteSession(ctx, filter)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -280,6 +308,10 @@ func (auth *AuthController) ConfirmLogin(c *gin.Context) {
 			})
 			return
 		}
+		denyEvent := auditEvent(c, models.AuditActionLoginDeny, models.AuditOutcomeSuccess, models.AuditTarget{Type: models.AuditTargetAccount, Id: deniedSession.UserId.Hex(), Label: approvedClaims.Email})
+		denyEvent.Actor = models.AuditActor{Email: approvedClaims.Email}
+		denyEvent.Metadata = bson.M{"device_id": deniedSession.DeviceId}
+		auth.auditService.Log(denyEvent)
 		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  http.StatusBadRequest,
 			"message": "Từ chối đăng nhập",
@@ -0,0 +1,85 @@
+package controllers_test
+
+import (
+	"UserManagementVer/controllers"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLogin(t *testing.T) {
+	server := newTestServer(t)
+	active := server.seedAccount(t, models.Account{Name: "An", Email: "an@example.com"})
+	server.seedAccount(t, models.Account{
+		Name:           "Bình",
+		Email:          "binh@example.com",
+		Status:         models.StatusSuspended,
+		SuspendedUntil: time.Now().Add(time.Hour),
+	})
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     int
+	}{
+		{name: "đúng mật khẩu", email: "an@example.com", password: testPassword, want: http.StatusOK},
+		{name: "sai mật khẩu", email: "an@example.com", password: "wrong", want: http.StatusBadRequest},
+		{name: "email không tồn tại", email: "nobody@example.com", password: testPassword, want: http.StatusBadRequest},
+		{name: "tài khoản bị tạm ngưng", email: "binh@example.com", password: testPassword, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := server.do(t, http.MethodPost, "/api/v1/auth/login", "", controllers.LoginRequest{
+				Email:    tt.email,
+				Password: tt.password,
+			}, "Device-Id", "device-1")
+			expectStatus(t, status, tt.want, response)
+		})
+	}
+
+	sessions, err := server.sessions.Find(context.Background(), repositories.SessionQuery{UserId: active.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].TrustedDevice || sessions[0].DeviceId != "device-1" || sessions[0].RefreshToken == "" {
+		t.Fatalf("sessions sau khi đăng nhập = %+v", sessions)
+	}
+}
+
+func TestLoginReturnsUsableAccessToken(t *testing.T) {
+	server := newTestServer(t)
+	account := server.seedAccount(t, models.Account{Name: "An", Email: "an@example.com"})
+
+	status, response := server.do(t, http.MethodPost, "/api/v1/auth/login", "", controllers.LoginRequest{
+		Email:    "an@example.com",
+		Password: testPassword,
+	}, "Device-Id", "device-1")
+	expectStatus(t, status, http.StatusOK, response)
+	var data struct {
+		AccessToken string `json:"access_token"`
+	}
+	decodeData(t, response, &data)
+
+	status, response = server.do(t, http.MethodGet, "/api/v1/accounts/"+account.Id.Hex()+"/detail", data.AccessToken, nil)
+	expectStatus(t, status, http.StatusOK, response)
+}
+
+func TestAuthorizeRejectsInvalidTokens(t *testing.T) {
+	server := newTestServer(t)
+	account := server.seedAccount(t, models.Account{Name: "An", Email: "an@example.com"})
+	path := "/api/v1/accounts/" + account.Id.Hex() + "/detail"
+
+	status, response := server.do(t, http.MethodGet, path, "", nil)
+	expectStatus(t, status, http.StatusBadRequest, response)
+
+	approved, _, _ := server.jwtService.GenerateJwt(account.Email, 300, "approved")
+	status, response = server.do(t, http.MethodGet, path, approved, nil)
+	expectStatus(t, status, http.StatusUnauthorized, response)
+
+	status, response = server.do(t, http.MethodGet, path, server.token(t, "ghost@example.com"), nil)
+	expectStatus(t, status, http.StatusUnauthorized, response)
+}
@@ -0,0 +1,427 @@
+package controllers_test
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUploadAndGetAvatar(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+
+	data := pngImage(t, 300, 200)
+	recorder := server.upload(t, "/api/v1/accounts/"+admin.Id.Hex()+"/update-avatar", token, "avatar.png", data)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("upload status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var response struct {
+		Path string `json:"path"`
+	}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	digest := sha256.Sum256(data)
+	if response.Path != "avatars/"+hex.EncodeToString(digest[:1])+"/"+hex.EncodeToString(digest[:]) {
+		t.Fatalf("key avatar không đúng dạng: %q", response.Path)
+	}
+
+	stored, _ := server.accounts.GetAccountById(context.Background(), admin.Id)
+	if stored.ImageUrl != response.Path {
+		t.Fatalf("image_url = %q, muốn lưu key %q", stored.ImageUrl, response.Path)
+	}
+
+	// Không có size thì trả biến thể lớn nhất
+	for query, size := range map[string]int{"": 64, "?size=16": 16, "?size=64": 64} {
+		recorder = server.get(t, "/api/v1/accounts/"+admin.Id.Hex()+"/avatar"+query, token)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("avatar%s status = %d", query, recorder.Code)
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "image/jpeg" {
+			t.Fatalf("avatar%s Content-Type = %q", query, contentType)
+		}
+		img, _, err := image.Decode(recorder.Body)
+		if err != nil || img.Bounds().Dx() != size || img.Bounds().Dy() != size {
+			t.Fatalf("avatar%s phải là ảnh vuông %dpx, nhận %v %v", query, size, img.Bounds(), err)
+		}
+	}
+
+	recorder = server.get(t, "/api/v1/accounts/"+admin.Id.Hex()+"/avatar?size=100", token)
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("size không hỗ trợ phải trả 400, nhận %d", recorder.Code)
+	}
+}
+
+func TestReplaceAndDeleteAvatar(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+	path := "/api/v1/accounts/" + admin.Id.Hex()
+
+	first := server.upload(t, path+"/update-avatar", token, "first.png", pngImage(t, 100, 100))
+	second := server.upload(t, path+"/update-avatar", token, "second.png", pngImage(t, 120, 100))
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("upload status = %d, %d", first.Code, second.Code)
+	}
+	keys := server.avatarKeys(t)
+	if len(keys) != 2 {
+		t.Fatalf("avatar cũ phải bị xóa khi thay ảnh, storage còn %v", keys)
+	}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, decodePath(t, second)+"/") {
+			t.Fatalf("file %s không thuộc avatar mới", key)
+		}
+	}
+
+	status, response := server.do(t, http.MethodDelete, path+"/avatar", token, nil)
+	expectStatus(t, status, http.StatusOK, response)
+	if keys := server.avatarKeys(t); len(keys) != 0 {
+		t.Fatalf("xóa avatar phải xóa mọi biến thể, storage còn %v", keys)
+	}
+	stored, _ := server.accounts.GetAccountById(context.Background(), admin.Id)
+	if stored.ImageUrl != "" {
+		t.Fatalf("image_url phải bị xóa, nhận %q", stored.ImageUrl)
+	}
+	if recorder := server.get(t, path+"/avatar", token); recorder.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("avatar đã xóa phải trả ảnh mặc định, nhận %d %s", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+
+	status, response = server.do(t, http.MethodDelete, path+"/avatar", token, nil)
+	expectStatus(t, status, http.StatusNotFound, response)
+}
+
+// Khôi phục phiên bản cũ kiểm tra Exists trước khi gán lại image_url, avatar đã bị thay phải không còn
+func TestReplacedAvatarIsNotRestorable(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+	path := "/api/v1/accounts/" + admin.Id.Hex()
+
+	first := server.upload(t, path+"/update-avatar", token, "first.png", pngImage(t, 100, 100))
+	if first.Code != http.StatusOK {
+		t.Fatalf("upload status = %d", first.Code)
+	}
+	firstUrl := decodePath(t, first)
+	if exists, err := server.avatarSvc.Exists(context.Background(), firstUrl); err != nil || !exists {
+		t.Fatalf("avatar đang dùng phải tồn tại, nhận %v %v", exists, err)
+	}
+	if second := server.upload(t, path+"/update-avatar", token, "second.png", pngImage(t, 120, 100)); second.Code != http.StatusOK {
+		t.Fatalf("upload status = %d", second.Code)
+	}
+	if exists, err := server.avatarSvc.Exists(context.Background(), firstUrl); err != nil || exists {
+		t.Fatalf("avatar đã bị thay không được khôi phục, nhận %v %v", exists, err)
+	}
+}
+
+func TestDeduplicatedAvatar(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	other := server.seedAccount(t, models.Account{Name: "Other", Email: "other@example.com"})
+	token := server.token(t, admin.Email)
+	image := pngImage(t, 100, 100)
+
+	var results [3]struct {
+		Path         string `json:"path"`
+		Deduplicated bool   `json:"deduplicated"`
+	}
+	for i, account := range []models.Account{admin, other, admin} {
+		recorder := server.upload(t, "/api/v1/accounts/"+account.Id.Hex()+"/update-avatar", token, "avatar.png", image)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("upload %d: status = %d", i, recorder.Code)
+		}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &results[i]); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	if results[0].Deduplicated || !results[1].Deduplicated || !results[2].Deduplicated {
+		t.Fatalf("chỉ lần upload đầu được lưu mới, nhận %+v", results)
+	}
+	if results[0].Path != results[1].Path || results[1].Path != results[2].Path {
+		t.Fatalf("ảnh giống nhau phải dùng chung key, nhận %+v", results)
+	}
+	// Upload lại đúng ảnh đang dùng không được xóa mất avatar
+	if keys := server.avatarKeys(t); len(keys) != 2 {
+		t.Fatalf("ảnh giống nhau chỉ được lưu một lần, storage có %v", keys)
+	}
+
+	status, response := server.do(t, http.MethodDelete, "/api/v1/accounts/"+admin.Id.Hex()+"/avatar", token, nil)
+	expectStatus(t, status, http.StatusOK, response)
+	if keys := server.avatarKeys(t); len(keys) != 2 {
+		t.Fatalf("avatar còn tài khoản khác dùng không được bị xóa, storage còn %v", keys)
+	}
+	if recorder := server.get(t, "/api/v1/accounts/"+other.Id.Hex()+"/avatar", token); recorder.Header().Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("avatar dùng chung phải vẫn đọc được, nhận %d", recorder.Code)
+	}
+
+	status, response = server.do(t, http.MethodDelete, "/api/v1/accounts/"+other.Id.Hex()+"/avatar", token, nil)
+	expectStatus(t, status, http.StatusOK, response)
+	if keys := server.avatarKeys(t); len(keys) != 0 {
+		t.Fatalf("tham chiếu cuối cùng bị bỏ thì phải xóa file, storage còn %v", keys)
+	}
+}
+
+func TestUploadHeicAvatar(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+	data, err := os.ReadFile("../imaging/testdata/sample.heic")
+	if err != nil {
+		t.Fatalf("đọc file mẫu: %v", err)
+	}
+
+	// Ảnh từ iPhone có đuôi viết hoa
+	recorder := server.upload(t, "/api/v1/accounts/"+admin.Id.Hex()+"/update-avatar", token, "IMG_0001.HEIC", data)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("upload HEIC status = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	recorder = server.get(t, "/api/v1/accounts/"+admin.Id.Hex()+"/avatar", token)
+	if contentType := recorder.Header().Get("Content-Type"); recorder.Code != http.StatusOK || contentType != "image/jpeg" {
+		t.Fatalf("avatar HEIC phải được trả về dạng JPEG, nhận %d %q", recorder.Code, contentType)
+	}
+}
+
+func TestGetLegacyAvatar(t *testing.T) {
+	server := newTestServer(t)
+	data := pngImage(t, 4, 4)
+	if err := server.avatars.Put(context.Background(), "legacy_photo.png", bytes.NewReader(data), int64(len(data)), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com", ImageUrl: `uploads\legacy_photo.png`})
+	token := server.token(t, admin.Email)
+
+	recorder := server.get(t, "/api/v1/accounts/"+admin.Id.Hex()+"/avatar", token)
+	if recorder.Code != http.StatusOK || !bytes.Equal(recorder.Body.Bytes(), data) {
+		t.Fatalf("avatar cũ phải đọc được qua storage local, status = %d", recorder.Code)
+	}
+}
+
+func TestGetAvatarPlaceholder(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Nguyễn Văn An", Email: "admin@example.com"})
+	missing := server.seedAccount(t, models.Account{Name: "Missing", Email: "missing@example.com", ImageUrl: "avatars/x/missing.png"})
+	traversal := server.seedAccount(t, models.Account{Name: "Traversal", Email: "traversal@example.com", ImageUrl: "../go.mod"})
+	token := server.token(t, admin.Email)
+
+	// Chưa có ảnh, file đã mất hay key không hợp lệ đều trả ảnh mặc định
+	for _, account := range []models.Account{admin, missing, traversal} {
+		recorder := server.get(t, "/api/v1/accounts/"+account.Id.Hex()+"/avatar?size=16", token)
+		if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("%s: status = %d %s, muốn ảnh PNG", account.Email, recorder.Code, recorder.Header().Get("Content-Type"))
+		}
+		config, _, err := image.DecodeConfig(recorder.Body)
+		if err != nil || config.Width != 16 || config.Height != 16 {
+			t.Fatalf("%s: ảnh mặc định phải 16x16, nhận %+v %v", account.Email, config, err)
+		}
+	}
+
+	path := "/api/v1/accounts/" + admin.Id.Hex() + "/avatar"
+	first := server.get(t, path, token)
+	second := server.get(t, path, token)
+	etag := first.Header().Get("ETag")
+	if etag == "" || !strings.HasPrefix(first.Header().Get("Cache-Control"), "private, max-age=") {
+		t.Fatalf("ảnh mặc định phải có header cache, nhận %v", first.Header())
+	}
+	if etag != second.Header().Get("ETag") || !bytes.Equal(first.Body.Bytes(), second.Body.Bytes()) {
+		t.Fatal("ảnh mặc định phải giống nhau giữa các lần gọi")
+	}
+	if recorder := server.getWithHeader(t, path, token, http.Header{"If-None-Match": {etag}}); recorder.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match khớp phải trả 304, nhận %d", recorder.Code)
+	}
+
+	svg := server.get(t, path+"?format=svg&size=64", token)
+	if svg.Code != http.StatusOK || svg.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(svg.Body.String(), ">NA</text>") {
+		t.Fatalf("svg: status = %d, body = %s", svg.Code, svg.Body.String())
+	}
+	for _, query := range []string{"?format=gif", "?size=20"} {
+		if recorder := server.get(t, path+query, token); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, muốn 400", query, recorder.Code)
+		}
+	}
+	if recorder := server.get(t, "/api/v1/accounts/"+primitive.NewObjectID().Hex()+"/avatar", token); recorder.Code != http.StatusNotFound {
+		t.Fatalf("tài khoản không tồn tại phải trả 404, nhận %d", recorder.Code)
+	}
+}
+
+func TestAvatarConditionalRequests(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+	path := "/api/v1/accounts/" + admin.Id.Hex() + "/avatar"
+	if recorder := server.upload(t, path[:len(path)-len("/avatar")]+"/update-avatar", token, "avatar.png", pngImage(t, 100, 100)); recorder.Code != http.StatusOK {
+		t.Fatalf("upload status = %d", recorder.Code)
+	}
+
+	recorder := server.get(t, path+"?size=16", token)
+	etag, lastModified := recorder.Header().Get("ETag"), recorder.Header().Get("Last-Modified")
+	if recorder.Code != http.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("avatar phải có ETag và Last-Modified, nhận %d %v", recorder.Code, recorder.Header())
+	}
+	if cacheControl := recorder.Header().Get("Cache-Control"); cacheControl != "private, no-cache" {
+		t.Fatalf("URL không có phiên bản phải hỏi lại server, nhận %q", cacheControl)
+	}
+	for name, header := range map[string]http.Header{
+		"If-None-Match":     {"If-None-Match": {etag}},
+		"If-Modified-Since": {"If-Modified-Since": {lastModified}},
+	} {
+		if recorder := server.getWithHeader(t, path+"?size=16", token, header); recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+			t.Fatalf("%s: status = %d, muốn 304", name, recorder.Code)
+		}
+	}
+	if recorder := server.getWithHeader(t, path+"?size=64", token, http.Header{"If-None-Match": {etag}}); recorder.Code != http.StatusOK {
+		t.Fatalf("ETag của biến thể khác không được khớp, nhận %d", recorder.Code)
+	}
+
+	var data struct {
+		Url     string `json:"url"`
+		Version string `json:"version"`
+	}
+	status, response := server.do(t, http.MethodGet, path+"-url?size=16", token, nil)
+	expectStatus(t, status, http.StatusOK, response)
+	if err := json.Unmarshal(response.Data, &data); err != nil || data.Url == "" {
+		t.Fatalf("avatar-url: %s %v", response.Data, err)
+	}
+	recorder = server.get(t, path+"?size=16&v="+data.Version, token)
+	if cacheControl := recorder.Header().Get("Cache-Control"); cacheControl != "private, max-age=31536000, immutable" {
+		t.Fatalf("URL có phiên bản phải được cache vĩnh viễn, nhận %q", cacheControl)
+	}
+
+	// URL đã ký dùng được không cần đăng nhập
+	public := server.getWithHeader(t, data.Url, "", nil)
+	if public.Code != http.StatusOK || public.Header().Get("Content-Type") != "image/jpeg" || public.Header().Get("ETag") != etag {
+		t.Fatalf("URL công khai: status = %d %v", public.Code, public.Header())
+	}
+	// URL công khai chỉ được cache tới khi URL hết hạn
+	var maxAge int
+	if _, err := fmt.Sscanf(public.Header().Get("Cache-Control"), "public, max-age=%d, immutable", &maxAge); err != nil || maxAge <= 0 || maxAge > int(time.Hour.Seconds()*3/2) {
+		t.Fatalf("URL công khai phải được cache tới khi hết hạn, nhận %q", public.Header().Get("Cache-Control"))
+	}
+	expired, _ := server.avatarUrls.Sign(admin.Id, services.AvatarUrlParams{Version: data.Version, Size: 16}, time.Now().Add(-3*time.Hour))
+	for name, url := range map[string]string{
+		"sửa size":   strings.Replace(data.Url, "size=16", "size=64", 1),
+		"sửa id":     strings.Replace(data.Url, admin.Id.Hex(), primitive.NewObjectID().Hex(), 1),
+		"không ký":   strings.Split(data.Url, "&sig=")[0],
+		"đã hết hạn": "/api/v1/avatars/" + admin.Id.Hex() + "?" + expired.Encode(),
+	} {
+		if recorder := server.getWithHeader(t, url, "", nil); recorder.Code != http.StatusForbidden {
+			t.Fatalf("%s: status = %d, muốn 403", name, recorder.Code)
+		}
+	}
+
+	// Đổi avatar thì URL cũ vẫn hợp lệ nhưng không còn được cache vĩnh viễn
+	server.upload(t, path[:len(path)-len("/avatar")]+"/update-avatar", token, "avatar.png", pngImage(t, 120, 100))
+	stale := server.getWithHeader(t, data.Url, "", nil)
+	if stale.Code != http.StatusOK || stale.Header().Get("Cache-Control") != "public, no-cache" {
+		t.Fatalf("URL cũ: status = %d %v", stale.Code, stale.Header())
+	}
+}
+
+func TestUploadAvatarRejectsInvalidImages(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+
+	cases := map[string][]byte{
+		"không phải ảnh": []byte("không phải ảnh"),
+		"ảnh quá nhỏ":    pngImage(t, 8, 8),
+		"file quá lớn":   append(pngImage(t, 32, 32), make([]byte, 1<<20)...),
+	}
+	for name, data := range cases {
+		recorder := server.upload(t, "/api/v1/accounts/"+admin.Id.Hex()+"/update-avatar", token, "avatar.png", data)
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, muốn 400", name, recorder.Code)
+		}
+	}
+	stored, _ := server.accounts.GetAccountById(context.Background(), admin.Id)
+	if stored.ImageUrl != "" {
+		t.Fatalf("không được đổi avatar khi upload bị từ chối: %q", stored.ImageUrl)
+	}
+}
+
+func (s *testServer) upload(t *testing.T, path string, token string, fileName string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", fileName)
+	if err != nil {
+		t.Fatalf("tạo form: %v", err)
+	}
+	part.Write(data)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (s *testServer) avatarKeys(t *testing.T) []string {
+	t.Helper()
+	keys := []string{}
+	err := s.avatars.List(context.Background(), "", func(object storage.ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return keys
+}
+
+func decodePath(t *testing.T, recorder *httptest.ResponseRecorder) string {
+	t.Helper()
+	var response struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return response.Path
+}
+
+func (s *testServer) get(t *testing.T, path string, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.getWithHeader(t, path, token, nil)
+}
+
+// getWithHeader gửi GET kèm header, token rỗng thì không gửi Authorization
+func (s *testServer) getWithHeader(t *testing.T, path string, token string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func pngImage(t *testing.T, width int, height int) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,161 @@
+package controllers_test
+
+import (
+	"UserManagementVer/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTusAvatarUpload(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+	uploads := "/api/v1/accounts/" + admin.Id.Hex() + "/avatar/uploads"
+
+	recorder := server.tusRequest(t, http.MethodOptions, uploads, "", nil)
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Tus-Version") != "1.0.0" || !strings.Contains(recorder.Header().Get("Tus-Extension"), "termination") {
+		t.Fatalf("OPTIONS = %d %v", recorder.Code, recorder.Header())
+	}
+
+	data := pngImage(t, 300, 200)
+	location := server.createTusUpload(t, uploads, token, len(data), "avatar.png")
+
+	// Gửi nửa đầu, HEAD cho biết offset để gửi tiếp
+	half := len(data) / 2
+	recorder = server.tusRequest(t, http.MethodPatch, location, token, data[:half], "Upload-Offset", "0")
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Upload-Offset") != strconv.Itoa(half) {
+		t.Fatalf("PATCH đầu = %d offset %q: %s", recorder.Code, recorder.Header().Get("Upload-Offset"), recorder.Body.String())
+	}
+	recorder = server.tusRequest(t, http.MethodHead, location, token, nil)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Upload-Offset") != strconv.Itoa(half) || recorder.Header().Get("Upload-Length") != strconv.Itoa(len(data)) {
+		t.Fatalf("HEAD = %d %v", recorder.Code, recorder.Header())
+	}
+	if stored, _ := server.accounts.GetAccountById(context.Background(), admin.Id); stored.ImageUrl != "" {
+		t.Fatalf("chưa nhận đủ file không được đổi avatar, image_url = %q", stored.ImageUrl)
+	}
+
+	// Offset sai (vd: client gửi lại phần đã nhận) trả 409
+	recorder = server.tusRequest(t, http.MethodPatch, location, token, data[:half], "Upload-Offset", "0")
+	if recorder.Code != http.StatusConflict {
+		t.Fatalf("PATCH sai offset phải trả 409, nhận %d", recorder.Code)
+	}
+
+	recorder = server.tusRequest(t, http.MethodPatch, location, token, data[half:], "Upload-Offset", strconv.Itoa(half))
+	if recorder.Code != http.StatusNoContent || recorder.Header().Get("Upload-Offset") != strconv.Itoa(len(data)) {
+		t.Fatalf("PATCH cuối = %d: %s", recorder.Code, recorder.Body.String())
+	}
+	digest := sha256.Sum256(data)
+	stored, _ := server.accounts.GetAccountById(context.Background(), admin.Id)
+	if stored.ImageUrl != "avatars/"+hex.EncodeToString(digest[:1])+"/"+hex.EncodeToString(digest[:]) {
+		t.Fatalf("upload xong phải lưu avatar như UploadImage, image_url = %q", stored.ImageUrl)
+	}
+	for _, key := range server.avatarKeys(t) {
+		if strings.HasPrefix(key, "tus/") {
+			t.Fatalf("phiên upload hoàn tất phải được dọn, còn %s", key)
+		}
+	}
+	if recorder := server.tusRequest(t, http.MethodHead, location, token, nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("phiên đã hoàn tất phải trả 404, nhận %d", recorder.Code)
+	}
+}
+
+func TestTusAvatarUploadValidation(t *testing.T) {
+	server := newTestServer(t)
+	admin := server.seedAccount(t, models.Account{Name: "Admin", Email: "admin@example.com"})
+	token := server.token(t, admin.Email)
+	uploads := "/api/v1/accounts/" + admin.Id.Hex() + "/avatar/uploads"
+	metadata := "filename " + base64.StdEncoding.EncodeToString([]byte("avatar.png"))
+
+	req := httptest.NewRequest(http.MethodPost, uploads, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Upload-Length", "10")
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusPreconditionFailed {
+		t.Fatalf("thiếu Tus-Resumable phải trả 412, nhận %d", recorder.Code)
+	}
+
+	for name, headers := range map[string][]string{
+		"thiếu Upload-Length": {"Upload-Metadata", metadata},
+		"sai đuôi file":       {"Upload-Length", "10", "Upload-Metadata", "filename " + base64.StdEncoding.EncodeToString([]byte("avatar.exe"))},
+		"metadata hỏng":       {"Upload-Length", "10", "Upload-Metadata", "filename !!!"},
+	} {
+		if recorder := server.tusRequest(t, http.MethodPost, uploads, token, nil, headers...); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("%s phải trả 400, nhận %d", name, recorder.Code)
+		}
+	}
+	if recorder := server.tusRequest(t, http.MethodPost, uploads, token, nil, "Upload-Length", strconv.Itoa(2<<20), "Upload-Metadata", metadata); recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("file quá lớn phải trả 413, nhận %d", recorder.Code)
+	}
+
+	// Nội dung không phải ảnh bị từ chối khi nhận đủ và phiên bị hủy
+	location := server.createTusUpload(t, uploads, token, 10, "avatar.png")
+	recorder = server.tusRequest(t, http.MethodPatch, location, token, []byte("0123456789ab"), "Upload-Offset", "0")
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("gửi quá Upload-Length phải trả 413, nhận %d", recorder.Code)
+	}
+	recorder = server.tusRequest(t, http.MethodPatch, location, token, []byte("0123456789"), "Upload-Offset", "0")
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("nội dung không phải ảnh phải trả 400, nhận %d", recorder.Code)
+	}
+	if recorder := server.tusRequest(t, http.MethodHead, location, token, nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("phiên có file không hợp lệ phải bị hủy, HEAD trả %d", recorder.Code)
+	}
+
+	// Termination xóa phần đã nhận
+	location = server.createTusUpload(t, uploads, token, 100, "avatar.png")
+	server.tusRequest(t, http.MethodPatch, location, token, []byte("partial"), "Upload-Offset", "0")
+	if recorder := server.tusRequest(t, http.MethodDelete, location, token, nil); recorder.Code != http.StatusNoContent {
+		t.Fatalf("DELETE = %d", recorder.Code)
+	}
+	if keys := server.avatarKeys(t); len(keys) != 0 {
+		t.Fatalf("hủy phiên phải xóa phần đã nhận, storage còn %v", keys)
+	}
+
+	// Tài khoản khác không truy cập được phiên upload
+	other := server.seedAccount(t, models.Account{Name: "Other", Email: "other@example.com"})
+	location = server.createTusUpload(t, uploads, token, 100, "avatar.png")
+	otherLocation := strings.Replace(location, admin.Id.Hex(), other.Id.Hex(), 1)
+	if recorder := server.tusRequest(t, http.MethodHead, otherLocation, token, nil); recorder.Code != http.StatusNotFound {
+		t.Fatalf("phiên của tài khoản khác phải trả 404, nhận %d", recorder.Code)
+	}
+}
+
+func (s *testServer) createTusUpload(t *testing.T, uploads string, token string, length int, fileName string) string {
+	t.Helper()
+	recorder := s.tusRequest(t, http.MethodPost, uploads, token, nil,
+		"Upload-Length", strconv.Itoa(length),
+		"Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(fileName)))
+	location := recorder.Header().Get("Location")
+	if recorder.Code != http.StatusCreated || !strings.HasPrefix(location, uploads+"/") || recorder.Header().Get("Upload-Expires") == "" {
+		t.Fatalf("tạo phiên upload = %d %v: %s", recorder.Code, recorder.Header(), recorder.Body.String())
+	}
+	return location
+}
+
+// tusRequest gửi request tus 1.0, PATCH có body dạng application/offset+octet-stream
+func (s *testServer) tusRequest(t *testing.T, method string, path string, token string, body []byte, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	if method == http.MethodPatch {
+		req.Header.Set("Content-Type", "application/offset+octet-stream")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+	return recorder
+}
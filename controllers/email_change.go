@@ -0,0 +1,431 @@
+package controllers
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/configs"
+	"UserManagementVer/middlewares"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/services"
+	"UserManagementVer/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+}
+
+type EmailChangeController struct {
+	accountCollection     repositories.AccountRepository
+	sessionCollection     repositories.SessionRepository
+	emailChangeCollection *collections.EmailChangeCollection
+	emailService          *services.EmailService
+	jwtService            *services.JwtService
+	historyService        *services.AccountHistoryService
+	auditService          *services.AuditService
+}
+
+func NewEmailChangeController(accountCollection repositories.AccountRepository, sessionCollection repositories.SessionRepository, emailChangeCollection *collections.EmailChangeCollection, emailService *services.EmailService, jwtService *services.JwtService, historyService *services.AccountHistoryService, auditService *services.AuditService) *EmailChangeController {
+	return &EmailChangeController{
+		accountCollection:     accountCollection,
+		sessionCollection:     sessionCollection,
+		emailChangeCollection: emailChangeCollection,
+		emailService:          emailService,
+		jwtService:            jwtService,
+		historyService:        historyService,
+		auditService:          auditService,
+	}
+}
+
+func (ec *EmailChangeController) RequestEmailChange(c *gin.Context) {
+	id := c.Param("id")
+	objectId, _ := primitive.ObjectIDFromHex(id)
+	var emailChangeRequest EmailChangeRequest
+
+	if err := c.ShouldBindJSON(&emailChangeRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	emailChangeRequest.NewEmail = strings.TrimSpace(emailChangeRequest.NewEmail)
+	if err := utils.HandlerValidation(utils.Validator.Struct(emailChangeRequest)); len(err) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Lỗi định dạng: " + err,
+		})
+		return
+	}
+
+	authHeader := c.GetHeader("Authorization")
+	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	jwtCustomClaims, err := ec.jwtService.ExtractCustomClaims(authHeader)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	requestedByAccount, err := ec.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Không tìm thấy thông tin người yêu cầu",
+		})
+		return
+	}
+
+	account, err := ec.accountCollection.GetAccountById(ctx, objectId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không thấy tài khoản",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if strings.EqualFold(account.Email, emailChangeRequest.NewEmail) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Email mới trùng với email hiện tại",
+		})
+		return
+	}
+
+	_, checkExisted := ec.accountCollection.Find(ctx, repositories.ByEmail(emailChangeRequest.NewEmail))
+	if !errors.Is(checkExisted, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Email người dùng này đã tồn tại trong hệ thống",
+		})
+		return
+	}
+
+	confirmToken, _, err := ec.jwtService.GenerateJwt(emailChangeRequest.NewEmail, configs.AppConfig.Jwt.JwtEmailChangeExpirationTime, "email_change")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể sinh được token",
+		})
+		return
+	}
+	undoToken, _, err := ec.jwtService.GenerateJwt(account.Email, configs.AppConfig.Jwt.JwtEmailUndoExpirationTime, "email_undo")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể sinh được token",
+		})
+		return
+	}
+
+	// Chỉ giữ lại yêu cầu mới nhất của tài khoản
+	err = ec.emailChangeCollection.UpdateMany(ctx, bson.M{
+		"user_id": account.Id,
+		"status":  models.EmailChangePending,
+	}, bson.M{
+		"$set": bson.M{"status": models.EmailChangeCancelled},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err = ec.emailChangeCollection.Create(ctx, models.EmailChange{
+		UserId:       account.Id,
+		OldEmail:     account.Email,
+		NewEmail:     emailChangeRequest.NewEmail,
+		ConfirmToken: confirmToken,
+		UndoToken:    undoToken,
+		Status:       models.EmailChangePending,
+		RequestedBy:  requestedByAccount.Id,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := ec.emailService.SendEmailChangeConfirmation(emailChangeRequest.NewEmail, emailChangeLink("confirm", confirmToken)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": "Không thể gửi email xác nhận",
+		})
+		return
+	}
+	ec.emailService.SendEmailChangeNotice(account.Email, emailChangeRequest.NewEmail, emailChangeLink("undo", undoToken))
+	event := auditEvent(c, models.AuditActionEmailChangeRequest, models.AuditOutcomeSuccess, models.AccountTarget(account))
+	event.Metadata = bson.M{"old_email": account.Email, "new_email": emailChangeRequest.NewEmail}
+	ec.auditService.Log(event)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":    http.StatusAccepted,
+		"timestamp": time.Now(),
+		"message":   "Đã gửi email xác nhận tới địa chỉ mới",
+	})
+}
+
+func (ec *EmailChangeController) ConfirmEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := ec.validateEmailChangeToken(token, "email_change"); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	emailChange, err := ec.emailChangeCollection.FindOne(ctx, bson.M{
+		"confirm_token": token,
+		"status":        models.EmailChangePending,
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Yêu cầu đổi email không tồn tại hoặc đã được xử lý",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	confirmEvent := ec.emailChangeAuditEvent(c, models.AuditActionEmailChangeConfirm, emailChange)
+	if err := ec.switchEmail(ctx, emailChange.UserId, emailChange.OldEmail, emailChange.NewEmail, c.GetString(middlewares.RequestIdKey)); err != nil {
+		confirmEvent.Outcome = models.AuditOutcomeFailure
+		confirmEvent.Reason = err.Error()
+		ec.auditService.Log(confirmEvent)
+		status := switchEmailStatus(err)
+		c.JSON(status, gin.H{
+			"status":  status,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err = ec.emailChangeCollection.Update(ctx, bson.M{"_id": emailChange.Id}, bson.M{
+		"$set": bson.M{
+			"status":       models.EmailChangeConfirmed,
+			"confirmed_at": time.Now(),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ec.auditService.Log(confirmEvent)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đổi email thành công, vui lòng đăng nhập lại",
+	})
+}
+
+func (ec *EmailChangeController) UndoEmailChange(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := ec.validateEmailChangeToken(token, "email_undo"); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	emailChange, err := ec.emailChangeCollection.FindOne(ctx, bson.M{
+		"undo_token": token,
+		"status": bson.M{
+			"$in": []string{models.EmailChangePending, models.EmailChangeConfirmed},
+		},
+	})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Yêu cầu đổi email không tồn tại hoặc đã được xử lý",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Chưa xác nhận thì chỉ cần hủy yêu cầu
+	if emailChange.Status == models.EmailChangePending {
+		err = ec.emailChangeCollection.Update(ctx, bson.M{"_id": emailChange.Id}, bson.M{
+			"$set": bson.M{"status": models.EmailChangeCancelled},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": err.Error(),
+			})
+			return
+		}
+		cancelEvent := ec.emailChangeAuditEvent(c, models.AuditActionEmailChangeUndo, emailChange)
+		cancelEvent.Metadata["cancelled"] = true
+		ec.auditService.Log(cancelEvent)
+		c.JSON(http.StatusOK, gin.H{
+			"status":    http.StatusOK,
+			"timestamp": time.Now(),
+			"message":   "Đã hủy yêu cầu đổi email",
+		})
+		return
+	}
+
+	undoEvent := ec.emailChangeAuditEvent(c, models.AuditActionEmailChangeUndo, emailChange)
+	if err := ec.switchEmail(ctx, emailChange.UserId, emailChange.NewEmail, emailChange.OldEmail, c.GetString(middlewares.RequestIdKey)); err != nil {
+		undoEvent.Outcome = models.AuditOutcomeFailure
+		undoEvent.Reason = err.Error()
+		ec.auditService.Log(undoEvent)
+		status := switchEmailStatus(err)
+		c.JSON(status, gin.H{
+			"status":  status,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	err = ec.emailChangeCollection.Update(ctx, bson.M{"_id": emailChange.Id}, bson.M{
+		"$set": bson.M{
+			"status":      models.EmailChangeReverted,
+			"reverted_at": time.Now(),
+		},
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ec.auditService.Log(undoEvent)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã khôi phục email cũ, vui lòng đăng nhập lại",
+	})
+}
+
+// emailChangeAuditEvent tạo sự kiện cho các link xác nhận/hoàn tác,
+// người thực hiện là chủ tài khoản vì link chỉ được gửi tới email của họ.
+func (ec *EmailChangeController) emailChangeAuditEvent(c *gin.Context, action string, emailChange models.EmailChange) models.AuditEvent {
+	event := auditEvent(c, action, models.AuditOutcomeSuccess, models.AuditTarget{Type: models.AuditTargetAccount, Id: emailChange.UserId.Hex(), Label: emailChange.OldEmail})
+	event.Actor = models.AuditActor{Id: emailChange.UserId}
+	event.Metadata = bson.M{"old_email": emailChange.OldEmail, "new_email": emailChange.NewEmail}
+	return event
+}
+
+func (ec *EmailChangeController) validateEmailChangeToken(token string, typeToken string) (*services.JwtCustomClaim, error) {
+	if _, err := ec.jwtService.ValidateToken(token); err != nil {
+		return nil, err
+	}
+	claims, err := ec.jwtService.ExtractCustomClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != typeToken {
+		return nil, errors.New("Token không hợp lệ")
+	}
+	return claims, nil
+}
+
+var (
+	errEmailTaken   = errors.New("Email đã được tài khoản khác sử dụng")
+	errEmailChanged = errors.New("Email của tài khoản đã bị thay đổi trước đó")
+)
+
+// switchEmailStatus trả 409 khi không đổi được email vì xung đột, lỗi khác là lỗi của hệ thống
+func switchEmailStatus(err error) int {
+	if errors.Is(err, errEmailTaken) || errors.Is(err, errEmailChanged) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// switchEmail đổi email của tài khoản từ `from` sang `to` và thu hồi toàn bộ phiên đăng nhập.
+// Email trùng được phát hiện bởi unique index tại thời điểm ghi nên không thể bị chen ngang.
+func (ec *EmailChangeController) switchEmail(ctx context.Context, userId primitive.ObjectID, from string, to string, requestId string) error {
+	before, err := ec.accountCollection.GetAccountById(ctx, userId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errEmailChanged
+	}
+	if err != nil {
+		return err
+	}
+	err = ec.accountCollection.Update(ctx, repositories.AccountQuery{
+		Ids:   []primitive.ObjectID{userId},
+		Email: from,
+	}, repositories.AccountUpdate{
+		Email:     &to,
+		UpdatedAt: repositories.Ptr(time.Now()),
+	})
+	if repositories.IsDuplicate(err) {
+		return fmt.Errorf("%w: %s", errEmailTaken, to)
+	}
+	if errors.Is(err, repositories.ErrNoMatch) {
+		return errEmailChanged
+	}
+	if err != nil {
+		return err
+	}
+	if after, err := ec.accountCollection.GetAccountById(ctx, userId); err == nil {
+		if _, err := ec.historyService.Record(ctx, models.HistoryActionEmail, &before, after, userId, requestId); err != nil {
+			log.Println("Không thể ghi lịch sử tài khoản", userId.Hex(), err)
+		}
+	}
+
+	_, err = ec.sessionCollection.DeleteSessions(ctx, repositories.SessionQuery{UserId: userId})
+	return err
+}
+
+func emailChangeLink(action string, token string) string {
+	return fmt.Sprintf("%s/api/v1/auth/email-change/%s?token=%s", strings.TrimSuffix(configs.AppConfig.Server.BaseUrl, "/"), action, url.QueryEscape(token))
+}
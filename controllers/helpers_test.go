@@ -0,0 +1,163 @@
+package controllers_test
+
+import (
+	"UserManagementVer/configs"
+	"UserManagementVer/controllers"
+	"UserManagementVer/imaging"
+	"UserManagementVer/middlewares"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/routers"
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testPassword = "Secret@123"
+
+// testServer dựng toàn bộ route account/auth trên repository trong bộ nhớ,
+// các service cần MongoDB hoặc SMTP (audit, lịch sử, email, purge) được để nil.
+type testServer struct {
+	router     *gin.Engine
+	accounts   *memory.AccountRepository
+	sessions   *memory.SessionRepository
+	jwtService *services.JwtService
+	avatars    *storage.LocalStorage
+	avatarSvc  *services.AvatarService
+	avatarUrls *services.AvatarUrlSigner
+	tus        *services.TusService
+}
+
+type testResponse struct {
+	Status  int             `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	configs.AppConfig = &configs.Config{
+		Jwt: configs.Jwt{
+			SecretKey:                      "test-secret",
+			Issuer:                         "test",
+			JwtAccessTokenExpirationTime:   300,
+			JwtRefreshTokenExpirationTime:  3600,
+			JwtAprrovedTokenExpirationTime: 300,
+		},
+		Bulk: configs.Bulk{
+			ConfirmThreshold:           20,
+			MaxItems:                   1000,
+			ConfirmTokenExpirationTime: 300,
+		},
+	}
+
+	server := &testServer{
+		router:     gin.New(),
+		accounts:   memory.NewAccountRepository(),
+		sessions:   memory.NewSessionRepository(),
+		jwtService: services.NewJwtService("test-secret", "test"),
+	}
+	avatars, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("tạo storage: %v", err)
+	}
+	server.avatars = avatars
+	server.avatarUrls = services.NewAvatarUrlSigner("test-secret", time.Hour)
+	server.tus = services.NewTusService(server.avatars, 1<<20, time.Hour)
+	v1 := server.router.Group("/api/v1")
+	v1.Use(middlewares.RequestId())
+	authorize := middlewares.AuthorizeJWT(server.jwtService, server.accounts)
+	server.avatarSvc = services.NewAvatarService(server.avatars, server.accounts, nil, 1<<20, imaging.Options{
+		MinDimension: 16,
+		MaxDimension: 2000,
+		MaxPixels:    4000000,
+		Sizes:        []int{16, 64},
+		Quality:      85,
+	})
+	accountController := controllers.NewAccountController(server.accounts, server.jwtService, nil, nil, nil, server.avatarSvc, server.avatarUrls, server.tus)
+	authController := controllers.NewAuthController(server.sessions, server.accounts, nil, server.jwtService, nil)
+	routers.NewAccountRouter(accountController).RegisterRoutes(v1, authorize)
+	routers.NewAuthRouter(authController).Register(v1)
+	return server
+}
+
+// seedAccount lưu tài khoản với mật khẩu testPassword, trạng thái mặc định là active
+func (s *testServer) seedAccount(t *testing.T, account models.Account) models.Account {
+	t.Helper()
+	if account.Password == "" {
+		account.Password = testPassword
+	}
+	if account.Status == "" {
+		account.Status = models.StatusActive
+	}
+	id, err := s.accounts.Create(context.Background(), account)
+	if err != nil {
+		t.Fatalf("seed %s: %v", account.Email, err)
+	}
+	stored, err := s.accounts.GetAccountById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("seed %s: %v", account.Email, err)
+	}
+	return stored
+}
+
+func (s *testServer) token(t *testing.T, email string) string {
+	t.Helper()
+	token, _, err := s.jwtService.GenerateJwt(email, 300, "access")
+	if err != nil {
+		t.Fatalf("sinh token: %v", err)
+	}
+	return token
+}
+
+func (s *testServer) do(t *testing.T, method string, path string, token string, body interface{}, headers ...string) (int, testResponse) {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("encode body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	recorder := httptest.NewRecorder()
+	s.router.ServeHTTP(recorder, req)
+
+	var response testResponse
+	if recorder.Body.Len() > 0 && recorder.Header().Get("Content-Type") != "" {
+		_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+	}
+	return recorder.Code, response
+}
+
+func decodeData(t *testing.T, response testResponse, target interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(response.Data, target); err != nil {
+		t.Fatalf("decode data %s: %v", response.Data, err)
+	}
+}
+
+func expectStatus(t *testing.T, got int, want int, response testResponse) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("status = %d, muốn %d (message: %q)", got, want, response.Message)
+	}
+}
@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/services"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type RetentionPolicyRequest struct {
+	Days *int `json:"days"`
+}
+
+// TimeToLiveRequest là body của API cũ PATCH /accounts/time-to-live
+type TimeToLiveRequest struct {
+	Ttl *int `json:"ttl"`
+}
+
+type RetentionController struct {
+	retentionService  *services.RetentionService
+	accountCollection repositories.AccountRepository
+	jwtService        *services.JwtService
+	auditService      *services.AuditService
+}
+
+func NewRetentionController(retentionService *services.RetentionService, accountCollection repositories.AccountRepository, jwtService *services.JwtService, auditService *services.AuditService) *RetentionController {
+	return &RetentionController{
+		retentionService:  retentionService,
+		accountCollection: accountCollection,
+		jwtService:        jwtService,
+		auditService:      auditService,
+	}
+}
+
+func (rc *RetentionController) ListPolicies(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	policies, err := rc.retentionService.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data":      policies,
+	})
+}
+
+func (rc *RetentionController) GetPolicy(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	policy, err := rc.retentionService.Get(ctx, c.Param("target"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data":      policy,
+	})
+}
+
+func (rc *RetentionController) UpdatePolicy(c *gin.Context) {
+	var policyRequest RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&policyRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	if policyRequest.Days == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "days không được trống",
+		})
+		return
+	}
+	rc.applyPolicy(c, c.Param("target"), *policyRequest.Days)
+}
+
+// UpdateTimeToLiveHardDelete giữ API cũ đặt số ngày lưu tài khoản trong thùng rác,
+// đã thay bằng PUT /retention-policies/accounts.
+//
+// Deprecated: dùng UpdatePolicy với target accounts.
+func (rc *RetentionController) UpdateTimeToLiveHardDelete(c *gin.Context) {
+	c.Header("Deprecation", "true")
+	c.Header("Link", `</api/v1/retention-policies/accounts>; rel="successor-version"`)
+	var ttlRequest TimeToLiveRequest
+	if err := c.ShouldBindJSON(&ttlRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	if ttlRequest.Ttl == nil || *ttlRequest.Ttl < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "ttl phải là số ngày không âm",
+		})
+		return
+	}
+	rc.applyPolicy(c, services.RetentionTargetAccounts, *ttlRequest.Ttl)
+}
+
+func (rc *RetentionController) applyPolicy(c *gin.Context, target string, days int) {
+	authHeader := c.GetHeader("Authorization")
+	authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	jwtCustomClaims, err := rc.jwtService.ExtractCustomClaims(authHeader)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	updatedByAccount, err := rc.accountCollection.Find(ctx, repositories.ByEmail(jwtCustomClaims.Email))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  http.StatusUnauthorized,
+			"message": "Không tìm thấy thông tin người update",
+		})
+		return
+	}
+
+	if _, err := rc.retentionService.Get(ctx, target); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	policy, err := rc.retentionService.Apply(ctx, target, days, updatedByAccount.Id)
+	event := auditEvent(c, models.AuditActionRetentionUpdate, models.AuditOutcomeSuccess, models.AuditTarget{Type: models.AuditTargetRetention, Id: target})
+	event.Metadata = bson.M{"days": days, "previous_days": policy.PreviousDays, "applied": policy.Applied}
+	if err != nil {
+		event.Outcome = models.AuditOutcomeFailure
+		event.Reason = err.Error()
+	}
+	rc.auditService.Log(event)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+			"data":    policy,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã cập nhật chính sách lưu trữ",
+		"data":      policy,
+	})
+}
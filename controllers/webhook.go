@@ -0,0 +1,388 @@
+package controllers
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/models"
+	"UserManagementVer/services"
+	"UserManagementVer/utils"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRequest struct {
+	Url         string   `json:"url" validate:"required,url"`
+	Events      []string `json:"events" validate:"required,min=1"`
+	Description string   `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+type UpdateWebhookRequest struct {
+	Url         *string  `json:"url"`
+	Events      []string `json:"events"`
+	Description *string  `json:"description"`
+	Active      *bool    `json:"active"`
+}
+
+type WebhookController struct {
+	subscriptionCollection *collections.WebhookSubscriptionCollection
+	deliveryCollection     *collections.WebhookDeliveryCollection
+	webhookService         *services.WebhookService
+}
+
+func NewWebhookController(subscriptionCollection *collections.WebhookSubscriptionCollection, deliveryCollection *collections.WebhookDeliveryCollection, webhookService *services.WebhookService) *WebhookController {
+	return &WebhookController{
+		subscriptionCollection: subscriptionCollection,
+		deliveryCollection:     deliveryCollection,
+		webhookService:         webhookService,
+	}
+}
+
+func (wc *WebhookController) CreateWebhook(c *gin.Context) {
+	var webhookRequest WebhookRequest
+	if err := c.ShouldBindJSON(&webhookRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err := utils.HandlerValidation(utils.Validator.Struct(webhookRequest)); len(err) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Lỗi định dạng: " + err,
+		})
+		return
+	}
+	if err := validateWebhook(webhookRequest.Url, webhookRequest.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	secret, err := services.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	subscription := models.WebhookSubscription{
+		Url:         webhookRequest.Url,
+		Events:      webhookRequest.Events,
+		Secret:      secret,
+		Description: webhookRequest.Description,
+		Active:      webhookRequest.Active == nil || *webhookRequest.Active,
+		CreatedBy:   currentAccountId(c),
+		CreatedAt:   time.Now(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscription.Id, err = wc.subscriptionCollection.Create(ctx, subscription)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	// Secret chỉ được trả về một lần khi tạo webhook
+	c.JSON(http.StatusCreated, gin.H{
+		"status":    http.StatusCreated,
+		"timestamp": time.Now(),
+		"message":   "Đã tạo webhook, hãy lưu lại secret để kiểm tra chữ ký",
+		"data": gin.H{
+			"webhook": subscription,
+			"secret":  secret,
+		},
+	})
+}
+
+func (wc *WebhookController) ListWebhooks(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscriptions, err := wc.subscriptionCollection.FindAll(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data":      subscriptions,
+	})
+}
+
+func (wc *WebhookController) GetWebhook(c *gin.Context) {
+	subscription, ok := wc.findWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data":      subscription,
+	})
+}
+
+func (wc *WebhookController) UpdateWebhook(c *gin.Context) {
+	var updateRequest UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+	subscription, ok := wc.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	if updateRequest.Url != nil {
+		subscription.Url = *updateRequest.Url
+		set["url"] = subscription.Url
+	}
+	if updateRequest.Events != nil {
+		subscription.Events = updateRequest.Events
+		set["events"] = subscription.Events
+	}
+	if updateRequest.Description != nil {
+		set["description"] = *updateRequest.Description
+	}
+	if updateRequest.Active != nil {
+		set["active"] = *updateRequest.Active
+	}
+	if err := validateWebhook(subscription.Url, subscription.Events); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := wc.subscriptionCollection.Update(ctx, subscription.Id, bson.M{"$set": set}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	subscription, _ = wc.subscriptionCollection.GetById(ctx, subscription.Id)
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã cập nhật webhook",
+		"data":      subscription,
+	})
+}
+
+func (wc *WebhookController) DeleteWebhook(c *gin.Context) {
+	subscription, ok := wc.findWebhook(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := wc.subscriptionCollection.Delete(ctx, subscription.Id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Đã xóa webhook",
+	})
+}
+
+func (wc *WebhookController) PingWebhook(c *gin.Context) {
+	subscription, ok := wc.findWebhook(c)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	delivery, err := wc.webhookService.Ping(ctx, subscription)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":    http.StatusAccepted,
+		"timestamp": time.Now(),
+		"message":   "Đã gửi sự kiện thử",
+		"data":      delivery,
+	})
+}
+
+func (wc *WebhookController) ListDeliveries(c *gin.Context) {
+	subscription, ok := wc.findWebhook(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	filter := bson.M{"subscription_id": subscription.Id}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+	if event := c.Query("event"); event != "" {
+		filter["event"] = event
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	total, err := wc.deliveryCollection.Count(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	deliveries, err := wc.deliveryCollection.FindAll(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":    http.StatusOK,
+		"timestamp": time.Now(),
+		"message":   "Tìm thấy!",
+		"data": gin.H{
+			"items": deliveries,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
+
+func (wc *WebhookController) Redeliver(c *gin.Context) {
+	deliveryId, err := primitive.ObjectIDFromHex(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Id không hợp lệ",
+		})
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	delivery, err := wc.webhookService.Redeliver(ctx, deliveryId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy delivery",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  http.StatusConflict,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":    http.StatusAccepted,
+		"timestamp": time.Now(),
+		"message":   "Đã đưa delivery vào hàng đợi gửi lại",
+		"data":      delivery,
+	})
+}
+
+func (wc *WebhookController) findWebhook(c *gin.Context) (models.WebhookSubscription, bool) {
+	objectId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  http.StatusBadRequest,
+			"message": "Id không hợp lệ",
+		})
+		return models.WebhookSubscription{}, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	subscription, err := wc.subscriptionCollection.GetById(ctx, objectId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  http.StatusNotFound,
+			"message": "Không tìm thấy webhook",
+		})
+		return subscription, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  http.StatusInternalServerError,
+			"message": err.Error(),
+		})
+		return subscription, false
+	}
+	return subscription, true
+}
+
+func validateWebhook(rawUrl string, events []string) error {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return errors.New("Url của webhook phải là http hoặc https")
+	}
+	if len(events) == 0 {
+		return errors.New("Phải đăng ký ít nhất một sự kiện")
+	}
+	for _, event := range events {
+		if event == "*" {
+			continue
+		}
+		known := false
+		for _, webhookEvent := range models.WebhookEvents {
+			if event == webhookEvent {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return errors.New("Sự kiện không được hỗ trợ: " + event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"github.com/gen2brain/heic"
+)
+
+// heifBrands là các brand HEIF/HEIC trong hộp ftyp mà decoder đọc được (ảnh HEVC),
+// mif1/msf1 là brand chung của HEIF nên phải kèm một brand HEVC trong danh sách tương thích
+var heifBrands = map[string]bool{
+	"heic": true,
+	"heix": true,
+	"heim": true,
+	"heis": true,
+	"hevc": true,
+	"hevx": true,
+	"hevm": true,
+	"hevs": true,
+}
+
+// IsHeif kiểm tra chữ ký file HEIF/HEIC (ảnh mặc định của iPhone) qua hộp ftyp ở đầu file,
+// http.DetectContentType không nhận ra định dạng này
+func IsHeif(data []byte) bool {
+	if len(data) < 16 || string(data[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	if size < 16 || size > len(data) {
+		size = len(data)
+	}
+	// Brand chính nằm ở byte 8-12, các brand tương thích từ byte 16 tới hết hộp
+	if heifBrands[string(data[8:12])] {
+		return true
+	}
+	for offset := 16; offset+4 <= size; offset += 4 {
+		if heifBrands[string(data[offset:offset+4])] {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeConfig và decode dùng decoder HEIC khi nhận ra chữ ký HEIF vì gói heic chỉ đăng ký
+// brand "heic" với package image, ảnh có brand chính mif1/heix... sẽ không được nhận
+func decodeConfig(data []byte) (image.Config, string, error) {
+	if IsHeif(data) {
+		config, err := heic.DecodeConfig(bytes.NewReader(data))
+		return config, "heic", err
+	}
+	return image.DecodeConfig(bytes.NewReader(data))
+}
+
+func decode(data []byte) (image.Image, error) {
+	if IsHeif(data) {
+		return heic.Decode(bytes.NewReader(data))
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
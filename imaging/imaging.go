@@ -0,0 +1,107 @@
+// Package imaging chuẩn hóa ảnh upload (JPEG, PNG, GIF, BMP, WebP, HEIC): kiểm tra kích thước,
+// xoay theo EXIF, bỏ metadata bằng cách mã hóa lại và tạo các biến thể vuông kích thước cố định.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Định dạng chuẩn của mọi biến thể sau khi xử lý
+const (
+	ContentType = "image/jpeg"
+	Extension   = ".jpg"
+)
+
+// ErrInvalidImage được bọc trong mọi lỗi do ảnh không đạt yêu cầu (khác lỗi hệ thống)
+var ErrInvalidImage = errors.New("Ảnh không hợp lệ")
+
+type Options struct {
+	// MinDimension là cạnh ngắn nhất tối thiểu, MaxDimension là cạnh dài nhất tối đa (px)
+	MinDimension int
+	MaxDimension int
+	// MaxPixels giới hạn tổng số điểm ảnh để chặn ảnh "bom giải nén"
+	MaxPixels int
+	// Sizes là cạnh của các biến thể vuông sẽ tạo
+	Sizes   []int
+	Quality int
+}
+
+type Variant struct {
+	Size int
+	Data []byte
+}
+
+// Process giải mã ảnh, kiểm tra kích thước rồi tạo mỗi biến thể trong opts.Sizes bằng cách cắt
+// phần vuông ở giữa và co giãn. Ảnh được xoay theo EXIF và mã hóa lại nên EXIF/GPS không còn.
+func Process(data []byte, opts Options) ([]Variant, error) {
+	// Đọc header trước để từ chối ảnh quá lớn mà không phải giải mã
+	config, format, err := decodeConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: định dạng không được hỗ trợ", ErrInvalidImage)
+	}
+	if err := opts.checkDimensions(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+	img, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: không thể giải mã ảnh", ErrInvalidImage)
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	square := cropSquare(img)
+	variants := make([]Variant, 0, len(opts.Sizes))
+	for _, size := range opts.Sizes {
+		scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), square, square.Bounds(), draw.Src, nil)
+		// Cắt vuông ở giữa không đổi khi xoay/lật nên xoay sau khi đã thu nhỏ cho nhanh
+		oriented := orient(scaled, orientation)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, oriented, &jpeg.Options{Quality: opts.Quality}); err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{Size: size, Data: buf.Bytes()})
+	}
+	return variants, nil
+}
+
+func (opts Options) checkDimensions(width int, height int) error {
+	shortSide, longSide := min(width, height), max(width, height)
+	if opts.MinDimension > 0 && shortSide < opts.MinDimension {
+		return fmt.Errorf("%w: cạnh ngắn nhất phải từ %dpx, ảnh có %dx%d", ErrInvalidImage, opts.MinDimension, width, height)
+	}
+	if opts.MaxDimension > 0 && longSide > opts.MaxDimension {
+		return fmt.Errorf("%w: cạnh dài nhất không được quá %dpx, ảnh có %dx%d", ErrInvalidImage, opts.MaxDimension, width, height)
+	}
+	if opts.MaxPixels > 0 && width*height > opts.MaxPixels {
+		return fmt.Errorf("%w: ảnh không được quá %d điểm ảnh", ErrInvalidImage, opts.MaxPixels)
+	}
+	return nil
+}
+
+// cropSquare lấy phần vuông ở giữa ảnh và phủ lên nền trắng vì JPEG không có kênh trong suốt
+func cropSquare(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	offset := bounds.Min.Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, offset, draw.Over)
+	return square
+}
@@ -0,0 +1,226 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"testing"
+)
+
+var testOptions = Options{MinDimension: 16, MaxDimension: 1000, MaxPixels: 500000, Sizes: []int{8, 32}, Quality: 90}
+
+func TestProcessCreatesSquareVariants(t *testing.T) {
+	variants, err := Process(encodePng(t, halfImage(60, 40)), testOptions)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if len(variants) != 2 {
+		t.Fatalf("muốn 2 biến thể, nhận %d", len(variants))
+	}
+	for i, variant := range variants {
+		img, format, err := image.Decode(bytes.NewReader(variant.Data))
+		if err != nil || format != "jpeg" {
+			t.Fatalf("biến thể %d không phải JPEG: %s %v", variant.Size, format, err)
+		}
+		if variant.Size != testOptions.Sizes[i] || img.Bounds().Dx() != variant.Size || img.Bounds().Dy() != variant.Size {
+			t.Fatalf("biến thể %d có kích thước %v", variant.Size, img.Bounds())
+		}
+	}
+}
+
+func TestProcessRejectsInvalidImages(t *testing.T) {
+	cases := map[string][]byte{
+		"không phải ảnh":  []byte("hello"),
+		"quá nhỏ":         encodePng(t, halfImage(10, 100)),
+		"quá dài":         encodePng(t, halfImage(1200, 20)),
+		"quá nhiều pixel": encodePng(t, halfImage(800, 800)),
+	}
+	for name, data := range cases {
+		if _, err := Process(data, testOptions); !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("%s: muốn ErrInvalidImage, nhận %v", name, err)
+		}
+	}
+}
+
+func TestProcessAppliesOrientationAndStripsExif(t *testing.T) {
+	// Nửa trái đỏ, nửa phải xanh; Orientation 6 nghĩa là phải xoay 90 độ theo chiều kim đồng hồ
+	data := withExifOrientation(t, encodeJpeg(t, halfImage(64, 64)), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, muốn 6", got)
+	}
+	variants, err := Process(data, Options{Sizes: []int{32}, Quality: 90})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(variants[0].Data, []byte("Exif")) {
+		t.Fatal("biến thể vẫn còn EXIF")
+	}
+	img, _, _ := image.Decode(bytes.NewReader(variants[0].Data))
+	if !isRed(img.At(16, 4)) || isRed(img.At(16, 28)) {
+		t.Fatal("ảnh chưa được xoay: nửa đỏ phải nằm ở phía trên")
+	}
+}
+
+func TestOrient(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	marked := color.RGBA{R: 255, A: 255}
+	src.SetRGBA(0, 0, marked)
+	want := map[int]image.Point{1: {0, 0}, 2: {1, 0}, 3: {1, 1}, 4: {0, 1}, 5: {0, 0}, 6: {1, 0}, 7: {1, 1}, 8: {0, 1}}
+	for orientation, point := range want {
+		if got := orient(src, orientation).RGBAAt(point.X, point.Y); got != marked {
+			t.Errorf("orientation %d: điểm (0,0) không nằm ở %v", orientation, point)
+		}
+	}
+}
+
+func halfImage(width int, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > 0xC000 && b < 0x4000
+}
+
+func encodePng(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJpeg(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withExifOrientation chèn segment APP1 Exif chỉ chứa tag Orientation ngay sau SOI
+func withExifOrientation(t *testing.T, data []byte, orientation uint16) []byte {
+	t.Helper()
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, exifOrientationTag)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	result := append([]byte{}, data[:2]...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestProcessHeic(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.heic")
+	if err != nil {
+		t.Fatalf("đọc file mẫu: %v", err)
+	}
+	if !IsHeif(data) {
+		t.Fatal("IsHeif không nhận ra file HEIC")
+	}
+	variants, err := Process(data, Options{Sizes: []int{32}, Quality: 85})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(variants[0].Data))
+	if err != nil || format != "jpeg" || img.Bounds().Dx() != 32 {
+		t.Fatalf("HEIC phải được chuyển thành JPEG 32px, nhận %s %v %v", format, img.Bounds(), err)
+	}
+}
+
+func TestIsHeif(t *testing.T) {
+	cases := map[string]bool{
+		"\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic": true,
+		"\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00mif1heic": true,
+		"\x00\x00\x00\x14ftypheix\x00\x00\x00\x00mif1":     true,
+		// AVIF cũng là HEIF nhưng dùng AV1, decoder HEIC không đọc được
+		"\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf": false,
+		"\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom":     false,
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR":                false,
+	}
+	for data, want := range cases {
+		if got := IsHeif([]byte(data)); got != want {
+			t.Errorf("IsHeif(%q) = %v, muốn %v", data, got, want)
+		}
+	}
+}
+
+func TestInitials(t *testing.T) {
+	cases := map[string]string{
+		"Nguyễn Văn An": "NA",
+		"  an  ":        "A",
+		"Ưng Hoàng Đức": "ƯĐ",
+		"(Bảo) Trân":    "BT",
+		"":              "?",
+		"-- !!":         "?",
+	}
+	for name, want := range cases {
+		if got := Initials(name); got != want {
+			t.Fatalf("Initials(%q) = %q, muốn %q", name, got, want)
+		}
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	background := PlaceholderColor([]byte("account-1"))
+	if background != PlaceholderColor([]byte("account-1")) {
+		t.Fatal("màu nền phải cố định theo seed")
+	}
+	data, contentType, err := Placeholder("ƯA", background, 64, PlaceholderPNG)
+	if err != nil || contentType != "image/png" {
+		t.Fatalf("Placeholder png: %s %v", contentType, err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil || img.Bounds().Dx() != 64 || img.Bounds().Dy() != 64 {
+		t.Fatalf("ảnh png phải 64x64: %v", err)
+	}
+	// Góc ảnh là màu nền, giữa ảnh có chữ trắng
+	if r, g, b, _ := img.At(0, 0).RGBA(); uint8(r>>8) != background.R || uint8(g>>8) != background.G || uint8(b>>8) != background.B {
+		t.Fatalf("góc ảnh phải là màu nền, nhận %v", img.At(0, 0))
+	}
+	white := 0
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r == 0xFFFF && g == 0xFFFF && b == 0xFFFF {
+				white++
+			}
+		}
+	}
+	if white == 0 {
+		t.Fatal("ảnh png phải có chữ")
+	}
+
+	data, contentType, err = Placeholder(`<a&"`, background, 64, PlaceholderSVG)
+	if err != nil || contentType != "image/svg+xml" || !bytes.Contains(data, []byte("&lt;a&amp;&#34;")) {
+		t.Fatalf("Placeholder svg phải escape chữ: %s %v", data, err)
+	}
+	if _, _, err := Placeholder("A", background, 64, "gif"); err == nil {
+		t.Fatal("định dạng không hỗ trợ phải lỗi")
+	}
+}
@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// exifOrientationTag là tag Orientation trong IFD0 của EXIF
+const exifOrientationTag = 0x0112
+
+// jpegOrientation đọc giá trị Orientation (1-8) trong segment APP1 Exif, không có hoặc lỗi thì trả về 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			// Byte đệm trước marker
+			pos++
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			// Marker không có dữ liệu
+			pos += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// Tới phần dữ liệu ảnh mà chưa thấy EXIF
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+		value := int(order.Uint16(tiff[entry+8:]))
+		if value < 1 || value > 8 {
+			return 1
+		}
+		return value
+	}
+	return 1
+}
+
+// orient xoay/lật ảnh vuông để hiển thị đúng chiều theo giá trị Orientation của EXIF
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // lật ngang
+				dx, dy = width-1-x, y
+			case 3: // xoay 180 độ
+				dx, dy = width-1-x, height-1-y
+			case 4: // lật dọc
+				dx, dy = x, height-1-y
+			case 5: // lật theo đường chéo chính
+				dx, dy = y, x
+			case 6: // xoay 90 độ theo chiều kim đồng hồ
+				dx, dy = height-1-y, x
+			case 7: // lật theo đường chéo phụ
+				dx, dy = height-1-y, width-1-x
+			case 8: // xoay 90 độ ngược chiều kim đồng hồ
+				dx, dy = y, width-1-x
+			}
+			dst.SetRGBA(dx, dy, img.RGBAAt(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -0,0 +1,143 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Định dạng của ảnh đại diện mặc định
+const (
+	PlaceholderPNG = "png"
+	PlaceholderSVG = "svg"
+)
+
+// placeholderColors là bảng màu nền, đều đủ tương phản với chữ trắng
+var placeholderColors = []color.RGBA{
+	{0xE5, 0x39, 0x35, 0xFF},
+	{0xD8, 0x1B, 0x60, 0xFF},
+	{0x8E, 0x24, 0xAA, 0xFF},
+	{0x5E, 0x35, 0xB1, 0xFF},
+	{0x39, 0x49, 0xAB, 0xFF},
+	{0x1E, 0x88, 0xE5, 0xFF},
+	{0x03, 0x9B, 0xE5, 0xFF},
+	{0x00, 0x89, 0x7B, 0xFF},
+	{0x43, 0xA0, 0x47, 0xFF},
+	{0x6D, 0x4C, 0x41, 0xFF},
+	{0xF4, 0x51, 0x1E, 0xFF},
+	{0x54, 0x6E, 0x7A, 0xFF},
+}
+
+var placeholderFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(gobold.TTF)
+})
+
+// Initials lấy chữ cái đầu của từ đầu tiên và từ cuối cùng trong tên ("Nguyễn Văn An" -> "NA"),
+// tên không có chữ cái nào trả về "?"
+func Initials(name string) string {
+	letters := []rune{}
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				letters = append(letters, unicode.ToUpper(r))
+				break
+			}
+		}
+	}
+	switch len(letters) {
+	case 0:
+		return "?"
+	case 1:
+		return string(letters[0])
+	}
+	return string([]rune{letters[0], letters[len(letters)-1]})
+}
+
+// PlaceholderColor chọn màu nền cố định theo seed (vd: id tài khoản)
+func PlaceholderColor(seed []byte) color.RGBA {
+	hash := fnv.New32a()
+	hash.Write(seed)
+	return placeholderColors[hash.Sum32()%uint32(len(placeholderColors))]
+}
+
+// Placeholder vẽ ảnh vuông cạnh size gồm chữ viết tắt màu trắng trên nền background.
+// format là PlaceholderPNG hoặc PlaceholderSVG, trả về nội dung và content type.
+func Placeholder(initials string, background color.RGBA, size int, format string) ([]byte, string, error) {
+	switch format {
+	case PlaceholderSVG:
+		return placeholderSVG(initials, background, size), "image/svg+xml", nil
+	case PlaceholderPNG:
+		data, err := placeholderPNG(initials, background, size)
+		return data, "image/png", err
+	}
+	return nil, "", fmt.Errorf("định dạng %q không được hỗ trợ", format)
+}
+
+func placeholderSVG(initials string, background color.RGBA, size int) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 100 100">`, size, size)
+	fmt.Fprintf(&buf, `<rect width="100" height="100" fill="#%02X%02X%02X"/>`, background.R, background.G, background.B)
+	fmt.Fprintf(&buf, `<text x="50" y="50" dy=".35em" fill="#FFFFFF" font-family="Helvetica, Arial, sans-serif" font-size="42" font-weight="bold" text-anchor="middle">%s</text>`, html.EscapeString(initials))
+	buf.WriteString(`</svg>`)
+	return buf.Bytes()
+}
+
+func placeholderPNG(initials string, background color.RGBA, size int) ([]byte, error) {
+	f, err := placeholderFont()
+	if err != nil {
+		return nil, err
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: float64(size) * 0.42, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	text := drawableText(f, initials)
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	drawer := font.Drawer{Dst: img, Src: image.White, Face: face}
+	// Căn giữa theo khung bao thực của các ký tự thay vì theo ascent/descent của font
+	bounds, advance := drawer.BoundString(text)
+	drawer.Dot = fixed.Point26_6{
+		X: (fixed.I(size) - advance) / 2,
+		Y: (fixed.I(size)-(bounds.Max.Y-bounds.Min.Y))/2 - bounds.Min.Y,
+	}
+	drawer.DrawString(text)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// drawableText bỏ dấu các ký tự font không có glyph (vd: "Ư" -> "U"), font Go không phủ hết tiếng Việt
+func drawableText(f *opentype.Font, text string) string {
+	var buf sfnt.Buffer
+	runes := []rune(text)
+	for i, r := range runes {
+		if index, err := f.GlyphIndex(&buf, r); err == nil && index != 0 {
+			continue
+		}
+		if base := []rune(norm.NFD.String(string(r))); len(base) > 0 {
+			runes[i] = base[0]
+		}
+	}
+	return string(runes)
+}
@@ -1,21 +1,26 @@
 package middlewares
 
 import (
+	"UserManagementVer/repositories"
 	"UserManagementVer/services"
+	"context"
 	"log"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 var (
-	unAvailableType = []string{"approved"}
+	unAvailableType = []string{"approved", "email_change", "email_undo", "bulk_confirm"}
 )
 
-func AuthorizeJWT(jwtServce *services.JwtService) gin.HandlerFunc {
+const CurrentAccountKey = "currentAccount"
+
+func AuthorizeJWT(jwtServce *services.JwtService, accountCollection repositories.AccountRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		authHeader = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
@@ -40,6 +45,28 @@ func AuthorizeJWT(jwtServce *services.JwtService) gin.HandlerFunc {
 				c.Abort()
 				return
 			}
+
+			// Kiểm tra trạng thái tài khoản sở hữu token
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			account, err := accountCollection.Find(ctx, repositories.ByEmail(tokenClaims.Email))
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"status":  http.StatusUnauthorized,
+					"message": "Không tìm thấy tài khoản của token",
+				})
+				c.Abort()
+				return
+			}
+			if err := account.CheckCanLogin(time.Now()); err != nil {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status":  http.StatusForbidden,
+					"message": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+			c.Set(CurrentAccountKey, account)
 			c.Next()
 		} else {
 			log.Println(err)
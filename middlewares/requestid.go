@@ -0,0 +1,21 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIdKey = "requestId"
+
+// RequestId gắn mã định danh cho mỗi request, dùng lại X-Request-Id nếu client đã gửi
+func RequestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader("X-Request-Id")
+		if requestId == "" || len(requestId) > 128 {
+			requestId = uuid.New().String()
+		}
+		c.Set(RequestIdKey, requestId)
+		c.Header("X-Request-Id", requestId)
+		c.Next()
+	}
+}
@@ -20,4 +20,12 @@ type Account struct {
 	UpdatedBy primitive.ObjectID `bson:"updated_by,omitempty"`
 	DeletedAt time.Time          `bson:"deleted_at,omitempty"`
 	DeletedBy primitive.ObjectID `bson:"deleted_by,omitempty"`
+	ManagedBy primitive.ObjectID `bson:"managed_by,omitempty"`
+
+	Status          string             `bson:"status,omitempty"`
+	StatusReason    string             `bson:"status_reason,omitempty"`
+	SuspendedUntil  time.Time          `bson:"suspended_until,omitempty"`
+	StatusChangedAt time.Time          `bson:"status_changed_at,omitempty"`
+	StatusChangedBy primitive.ObjectID `bson:"status_changed_by,omitempty"`
+	StatusHistory   []StatusTransition `bson:"status_history,omitempty"`
 }
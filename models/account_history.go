@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Loại thay đổi được ghi vào lịch sử tài khoản
+const (
+	HistoryActionCreate   = "create"
+	HistoryActionUpdate   = "update"
+	HistoryActionStatus   = "status"
+	HistoryActionReassign = "reassign"
+	HistoryActionAvatar   = "avatar"
+	HistoryActionPassword = "password"
+	HistoryActionEmail    = "email"
+	HistoryActionRevert   = "revert"
+)
+
+// RevertableFields là các trường được phép khôi phục từ một phiên bản cũ,
+// email và trạng thái phải đi qua luồng riêng của chúng.
+var RevertableFields = []string{"name", "phone", "dob", "image_url", "managed_by"}
+
+type FieldChange struct {
+	Field string      `bson:"field" json:"field"`
+	Old   interface{} `bson:"old" json:"old"`
+	New   interface{} `bson:"new" json:"new"`
+}
+
+type AccountHistory struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AccountId    primitive.ObjectID `bson:"account_id" json:"account_id"`
+	Version      int                `bson:"version" json:"version"`
+	Action       string             `bson:"action" json:"action"`
+	Changes      []FieldChange      `bson:"changes" json:"changes"`
+	Snapshot     bson.M             `bson:"snapshot" json:"snapshot"`
+	RevertedFrom int                `bson:"reverted_from,omitempty" json:"reverted_from,omitempty"`
+	ActorId      primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	RequestId    string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// Snapshot trả về trạng thái có thể theo dõi của tài khoản, không bao gồm mật khẩu
+func (a Account) Snapshot() bson.M {
+	return bson.M{
+		"name":            a.Name,
+		"email":           a.Email,
+		"phone":           a.Phone,
+		"dob":             snapshotTime(a.Dob),
+		"image_url":       a.ImageUrl,
+		"status":          a.Status,
+		"status_reason":   a.StatusReason,
+		"suspended_until": snapshotTime(a.SuspendedUntil),
+		"deleted_at":      snapshotTime(a.DeletedAt),
+		"managed_by":      snapshotId(a.ManagedBy),
+	}
+}
+
+func snapshotTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	// MongoDB chỉ lưu tới mili giây
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+func snapshotId(id primitive.ObjectID) interface{} {
+	if id.IsZero() {
+		return nil
+	}
+	return id
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái vòng đời của tài khoản
+const (
+	StatusPending   = "pending"
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+	StatusLocked    = "locked"
+	StatusDeleted   = "deleted"
+	// StatusPurging là tài khoản trong thùng rác đã được job purge nhận xử lý,
+	// không có hành động nào chuyển khỏi trạng thái này nên không thể khôi phục
+	StatusPurging = "purging"
+)
+
+// Các hành động chuyển trạng thái
+const (
+	ActionActivate = "activate"
+	ActionSuspend  = "suspend"
+	ActionLock     = "lock"
+	ActionUnlock   = "unlock"
+	ActionDelete   = "delete"
+	ActionRestore  = "restore"
+)
+
+var (
+	ErrAccountPending   = errors.New("Tài khoản chưa được kích hoạt")
+	ErrAccountSuspended = errors.New("Tài khoản đang bị tạm ngưng")
+	ErrAccountLocked    = errors.New("Tài khoản đã bị khóa")
+	ErrAccountDeleted   = errors.New("Tài khoản đã bị xóa")
+)
+
+// statusTransitions: hành động -> (trạng thái hiện tại -> trạng thái mới)
+var statusTransitions = map[string]map[string]string{
+	ActionActivate: {
+		StatusPending:   StatusActive,
+		StatusSuspended: StatusActive,
+	},
+	ActionSuspend: {
+		StatusActive: StatusSuspended,
+	},
+	ActionLock: {
+		StatusPending:   StatusLocked,
+		StatusActive:    StatusLocked,
+		StatusSuspended: StatusLocked,
+	},
+	ActionUnlock: {
+		StatusLocked: StatusActive,
+	},
+	ActionDelete: {
+		StatusPending:   StatusDeleted,
+		StatusActive:    StatusDeleted,
+		StatusSuspended: StatusDeleted,
+		StatusLocked:    StatusDeleted,
+	},
+	ActionRestore: {
+		// trạng thái đích được lấy lại từ lịch sử, xem NextStatus
+		StatusDeleted: "",
+	},
+}
+
+type StatusTransition struct {
+	Action    string             `bson:"action" json:"action"`
+	From      string             `bson:"from" json:"from"`
+	To        string             `bson:"to" json:"to"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Until     time.Time          `bson:"until,omitempty" json:"until,omitempty"`
+	ChangedBy primitive.ObjectID `bson:"changed_by" json:"changed_by"`
+	ChangedAt time.Time          `bson:"changed_at" json:"changed_at"`
+}
+
+// CurrentStatus trả về trạng thái hiệu lực của tài khoản.
+// Tài khoản cũ chưa có trường status được suy ra từ deleted_at,
+// tài khoản bị tạm ngưng có thời hạn sẽ tự hết hạn khi qua suspended_until.
+func (a Account) CurrentStatus(now time.Time) string {
+	status := a.Status
+	if status == "" {
+		if !a.DeletedAt.IsZero() {
+			return StatusDeleted
+		}
+		return StatusActive
+	}
+	if status == StatusSuspended && !a.SuspendedUntil.IsZero() && now.After(a.SuspendedUntil) {
+		return StatusActive
+	}
+	return status
+}
+
+// CheckCanLogin kiểm tra tài khoản có được phép đăng nhập/sử dụng token hay không
+func (a Account) CheckCanLogin(now time.Time) error {
+	switch a.CurrentStatus(now) {
+	case StatusActive:
+		return nil
+	case StatusPending:
+		return ErrAccountPending
+	case StatusSuspended:
+		if !a.SuspendedUntil.IsZero() {
+			return fmt.Errorf("%w đến %s", ErrAccountSuspended, a.SuspendedUntil.Format("2006-01-02 15:04"))
+		}
+		return ErrAccountSuspended
+	case StatusLocked:
+		return ErrAccountLocked
+	default:
+		return ErrAccountDeleted
+	}
+}
+
+// NextStatus trả về trạng thái mới khi áp dụng action lên tài khoản
+func NextStatus(account Account, action string, now time.Time) (string, error) {
+	targets, ok := statusTransitions[action]
+	if !ok {
+		return "", fmt.Errorf("Hành động %s không hợp lệ", action)
+	}
+	current := account.CurrentStatus(now)
+	next, ok := targets[current]
+	if !ok {
+		return "", fmt.Errorf("Không thể %s tài khoản đang ở trạng thái %s", action, current)
+	}
+	if action == ActionRestore {
+		next = StatusActive
+		for i := len(account.StatusHistory) - 1; i >= 0; i-- {
+			if account.StatusHistory[i].To == StatusDeleted {
+				if account.StatusHistory[i].From != "" {
+					next = account.StatusHistory[i].From
+				}
+				break
+			}
+		}
+	}
+	return next, nil
+}
+
+// StatusFilter trả về điều kiện truy vấn các tài khoản đang ở trạng thái status,
+// bao gồm cả tài khoản cũ chưa có trường status.
+func StatusFilter(status string) bson.M {
+	switch status {
+	case StatusActive:
+		return bson.M{"$or": []bson.M{
+			{"status": StatusActive},
+			{"status": bson.M{"$exists": false}, "deleted_at": nil},
+		}}
+	case StatusDeleted:
+		return bson.M{"$or": []bson.M{
+			{"status": StatusDeleted},
+			{"status": bson.M{"$exists": false}, "deleted_at": bson.M{"$ne": nil}},
+		}}
+	default:
+		return bson.M{"status": status}
+	}
+}
+
+// StoredStatus trả về trạng thái đã lưu, tài khoản cũ chưa có trường status được suy ra từ deleted_at.
+// Khác CurrentStatus, hạn tạm ngưng không được tính vì đây là giá trị dùng làm điều kiện khi ghi.
+func (a Account) StoredStatus() string {
+	if a.Status != "" {
+		return a.Status
+	}
+	if !a.DeletedAt.IsZero() {
+		return StatusDeleted
+	}
+	return StatusActive
+}
+
+// HasStoredStatus là phiên bản trong bộ nhớ của StatusFilter, dùng cho các backend không phải MongoDB
+func (a Account) HasStoredStatus(status string) bool {
+	switch status {
+	case StatusActive:
+		return a.Status == StatusActive || (a.Status == "" && a.DeletedAt.IsZero())
+	case StatusDeleted:
+		return a.Status == StatusDeleted || (a.Status == "" && !a.DeletedAt.IsZero())
+	default:
+		return a.Status == status
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditCheckpoint chốt hash của sự kiện tại Sequence, được ký bằng khóa của server
+// để sửa lại toàn bộ chuỗi hash trong DB vẫn bị phát hiện.
+type AuditCheckpoint struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Sequence  int64              `bson:"sequence" json:"sequence"`
+	Hash      string             `bson:"hash" json:"hash"`
+	KeyId     string             `bson:"key_id" json:"key_id"`
+	Signature string             `bson:"signature" json:"signature"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
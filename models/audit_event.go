@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Hành động được ghi vào audit log
+const (
+	AuditActionLogin              = "auth.login"
+	AuditActionNewDeviceAlert     = "auth.new_device_alert"
+	AuditActionLoginApprove       = "auth.login_approve"
+	AuditActionLoginDeny          = "auth.login_deny"
+	AuditActionAccountCreate      = "account.create"
+	AuditActionAccountUpdate      = "account.update"
+	AuditActionAccountStatus      = "account.status"
+	AuditActionAccountBulk        = "account.bulk"
+	AuditActionAccountImport      = "account.import"
+	AuditActionAccountExport      = "account.export"
+	AuditActionAccountPurge       = "account.purge"
+	AuditActionAccountRevert      = "account.revert"
+	AuditActionAvatarUpdate       = "account.avatar_update"
+	AuditActionAvatarDelete       = "account.avatar_delete"
+	AuditActionPasswordReset      = "account.password_reset"
+	AuditActionEmailChangeRequest = "account.email_change_request"
+	AuditActionEmailChangeConfirm = "account.email_change_confirm"
+	AuditActionEmailChangeUndo    = "account.email_change_undo"
+	AuditActionRetentionUpdate    = "retention.update"
+)
+
+// Kết quả của hành động
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+	AuditOutcomeDenied  = "denied"
+)
+
+// Loại đối tượng bị tác động
+const (
+	AuditTargetAccount   = "account"
+	AuditTargetSession   = "session"
+	AuditTargetRetention = "retention_policy"
+)
+
+type AuditActor struct {
+	Id    primitive.ObjectID `bson:"id,omitempty" json:"id,omitempty"`
+	Email string             `bson:"email,omitempty" json:"email,omitempty"`
+}
+
+type AuditTarget struct {
+	Type  string `bson:"type" json:"type"`
+	Id    string `bson:"id,omitempty" json:"id,omitempty"`
+	Label string `bson:"label,omitempty" json:"label,omitempty"`
+}
+
+// AuditEvent chỉ được thêm mới, không bao giờ sửa hay xóa (ngoài TTL của chính sách lưu trữ).
+// Mỗi sự kiện nối với sự kiện trước qua PrevHash, Hash là SHA-256 của nội dung sự kiện và PrevHash.
+type AuditEvent struct {
+	Id        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Sequence  int64              `bson:"sequence,omitempty" json:"sequence,omitempty"`
+	PrevHash  string             `bson:"prev_hash,omitempty" json:"prev_hash,omitempty"`
+	Hash      string             `bson:"hash,omitempty" json:"hash,omitempty"`
+	Action    string             `bson:"action" json:"action"`
+	Outcome   string             `bson:"outcome" json:"outcome"`
+	Actor     AuditActor         `bson:"actor" json:"actor"`
+	Target    AuditTarget        `bson:"target" json:"target"`
+	Ip        string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	RequestId string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	Metadata  bson.M             `bson:"metadata,omitempty" json:"metadata,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// AccountTarget tạo target cho một tài khoản, label là email để còn đọc được sau khi tài khoản bị xóa vĩnh viễn
+func AccountTarget(account Account) AuditTarget {
+	return AuditTarget{Type: AuditTargetAccount, Id: account.Id.Hex(), Label: account.Email}
+}
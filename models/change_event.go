@@ -0,0 +1,73 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Loại thay đổi trong change stream của MongoDB
+const (
+	ChangeInsert     = "insert"
+	ChangeUpdate     = "update"
+	ChangeReplace    = "replace"
+	ChangeDelete     = "delete"
+	ChangeInvalidate = "invalidate"
+)
+
+type ChangeNamespace struct {
+	Db   string `bson:"db" json:"db"`
+	Coll string `bson:"coll" json:"coll"`
+}
+
+type ChangeDocumentKey struct {
+	Id primitive.ObjectID `bson:"_id" json:"id"`
+}
+
+type ChangeUpdateDescription struct {
+	UpdatedFields bson.M   `bson:"updatedFields" json:"updated_fields"`
+	RemovedFields []string `bson:"removedFields" json:"removed_fields"`
+}
+
+// ChangeEvent là một sự kiện đọc từ change stream, Id là resume token của sự kiện
+type ChangeEvent struct {
+	Id                bson.Raw                 `bson:"_id"`
+	OperationType     string                   `bson:"operationType"`
+	Ns                ChangeNamespace          `bson:"ns"`
+	DocumentKey       ChangeDocumentKey        `bson:"documentKey"`
+	FullDocument      bson.Raw                 `bson:"fullDocument,omitempty"`
+	UpdateDescription *ChangeUpdateDescription `bson:"updateDescription,omitempty"`
+	// TxnNumber chỉ có khi thay đổi nằm trong transaction, tức là do chính ứng dụng ghi kèm outbox
+	TxnNumber   *int64              `bson:"txnNumber,omitempty"`
+	ClusterTime primitive.Timestamp `bson:"clusterTime"`
+}
+
+func (e ChangeEvent) InTransaction() bool {
+	return e.TxnNumber != nil
+}
+
+// FieldUpdated cho biết trường (hoặc trường con) có bị đổi trong lần update này không
+func (e ChangeEvent) FieldUpdated(field string) bool {
+	if e.UpdateDescription == nil {
+		return e.OperationType == ChangeReplace
+	}
+	for updated := range e.UpdateDescription.UpdatedFields {
+		if updated == field || len(updated) > len(field) && updated[:len(field)+1] == field+"." {
+			return true
+		}
+	}
+	for _, removed := range e.UpdateDescription.RemovedFields {
+		if removed == field {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangeNotice là thông báo gọn về một thay đổi, phát lên message bus cho cache và tìm kiếm
+type ChangeNotice struct {
+	Collection    string   `json:"collection"`
+	Operation     string   `json:"operation"`
+	DocumentId    string   `json:"document_id"`
+	UpdatedFields []string `json:"updated_fields,omitempty"`
+	InTransaction bool     `json:"in_transaction"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái của một yêu cầu đổi email
+const (
+	EmailChangePending   = "pending"
+	EmailChangeConfirmed = "confirmed"
+	EmailChangeCancelled = "cancelled"
+	EmailChangeReverted  = "reverted"
+)
+
+type EmailChange struct {
+	Id           primitive.ObjectID `bson:"_id,omitempty"`
+	UserId       primitive.ObjectID `bson:"user_id"`
+	OldEmail     string             `bson:"old_email"`
+	NewEmail     string             `bson:"new_email"`
+	ConfirmToken string             `bson:"confirm_token"`
+	UndoToken    string             `bson:"undo_token"`
+	Status       string             `bson:"status"`
+	RequestedBy  primitive.ObjectID `bson:"requested_by"`
+	CreatedAt    time.Time          `bson:"created_at"`
+	ConfirmedAt  time.Time          `bson:"confirmed_at,omitempty"`
+	RevertedAt   time.Time          `bson:"reverted_at,omitempty"`
+}
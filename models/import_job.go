@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái của job import tài khoản
+const (
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+type ImportRowError struct {
+	Row     int    `bson:"row" json:"row"`
+	Email   string `bson:"email,omitempty" json:"email,omitempty"`
+	Message string `bson:"message" json:"message"`
+}
+
+// ImportJob lưu tiến độ import, UpdatedAt được cập nhật mỗi lần lưu tiến độ nên job running
+// lâu không cập nhật là job đã dừng giữa chừng (vd: server restart).
+type ImportJob struct {
+	Id         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	FileName   string             `bson:"file_name" json:"file_name"`
+	DryRun     bool               `bson:"dry_run" json:"dry_run"`
+	Status     string             `bson:"status" json:"status"`
+	Total      int                `bson:"total" json:"total"`
+	Processed  int                `bson:"processed" json:"processed"`
+	Succeeded  int                `bson:"succeeded" json:"succeeded"`
+	Failed     int                `bson:"failed" json:"failed"`
+	Errors     []ImportRowError   `bson:"errors" json:"errors"`
+	Message    string             `bson:"message,omitempty" json:"message,omitempty"`
+	CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	FinishedAt time.Time          `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
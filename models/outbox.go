@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Trạng thái của sự kiện trong outbox
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+	OutboxFailed    = "failed"
+)
+
+// Loại đối tượng phát sinh sự kiện
+const (
+	AggregateAccount = "account"
+	AggregateSession = "session"
+)
+
+// OutboxEvent được ghi cùng transaction với thay đổi dữ liệu, relay đọc lại và phát tới các sink.
+// EventId được giữ nguyên qua mọi lần gửi lại để bên nhận loại bỏ bản trùng.
+type OutboxEvent struct {
+	Id             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventId        string             `bson:"event_id" json:"event_id"`
+	Type           string             `bson:"type" json:"type"`
+	AggregateType  string             `bson:"aggregate_type" json:"aggregate_type"`
+	AggregateId    primitive.ObjectID `bson:"aggregate_id" json:"aggregate_id"`
+	Data           string             `bson:"data" json:"data"`
+	Status         string             `bson:"status" json:"status"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	DeliveredSinks []string           `bson:"delivered_sinks,omitempty" json:"delivered_sinks,omitempty"`
+	LastError      string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	PublishedAt    time.Time          `bson:"published_at,omitempty" json:"published_at,omitempty"`
+}
+
+// AccountEventData là thông tin tài khoản gửi ra ngoài, không bao gồm mật khẩu
+type AccountEventData struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone,omitempty"`
+	Dob       time.Time `json:"dob,omitempty"`
+	Status    string    `json:"status"`
+	ImageUrl  string    `json:"image_url,omitempty"`
+	ManagedBy string    `json:"managed_by,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
+	Permanent bool      `json:"permanent,omitempty"`
+}
+
+type SessionEventData struct {
+	SessionId string    `json:"session_id"`
+	AccountId string    `json:"account_id"`
+	DeviceId  string    `json:"device_id"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func NewOutboxEvent(eventType string, aggregateType string, aggregateId primitive.ObjectID, data interface{}) (OutboxEvent, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	eventId, err := uuid.NewRandom()
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+	now := time.Now()
+	return OutboxEvent{
+		Id:            primitive.NewObjectID(),
+		EventId:       eventId.String(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateId:   aggregateId,
+		Data:          string(raw),
+		Status:        OutboxPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}, nil
+}
+
+func AccountEventPayload(account Account) AccountEventData {
+	data := AccountEventData{
+		Id:        account.Id.Hex(),
+		Name:      account.Name,
+		Email:     account.Email,
+		Phone:     account.Phone,
+		Dob:       account.Dob,
+		Status:    account.CurrentStatus(time.Now()),
+		ImageUrl:  account.ImageUrl,
+		CreatedAt: account.CreatedAt,
+		UpdatedAt: account.UpdatedAt,
+		DeletedAt: account.DeletedAt,
+	}
+	if !account.ManagedBy.IsZero() {
+		data.ManagedBy = account.ManagedBy.Hex()
+	}
+	return data
+}
+
+// NewAccountChangeEvent trả về account.deleted khi tài khoản vừa bị xóa mềm,
+// mọi thay đổi khác (kể cả khôi phục) là account.updated.
+func NewAccountChangeEvent(before Account, after Account) (OutboxEvent, error) {
+	now := time.Now()
+	eventType := WebhookEventAccountUpdated
+	if after.CurrentStatus(now) == StatusDeleted && before.CurrentStatus(now) != StatusDeleted {
+		eventType = WebhookEventAccountDeleted
+	}
+	return NewOutboxEvent(eventType, AggregateAccount, after.Id, AccountEventPayload(after))
+}
+
+// NewSessionEvent: session đang chờ duyệt thiết bị mới sinh login.approval_requested,
+// session đã tin cậy và có refresh token sinh session.created, các trường hợp khác không có sự kiện.
+func NewSessionEvent(session Session) (OutboxEvent, bool, error) {
+	eventType := ""
+	switch {
+	case session.ApprovedToken != "":
+		eventType = WebhookEventLoginApprovalRequested
+	case session.TrustedDevice && session.RefreshToken != "" && !session.IsRevoked:
+		eventType = WebhookEventSessionCreated
+	default:
+		return OutboxEvent{}, false, nil
+	}
+	event, err := NewOutboxEvent(eventType, AggregateSession, session.Id, SessionEventData{
+		SessionId: session.Id.Hex(),
+		AccountId: session.UserId.Hex(),
+		DeviceId:  session.DeviceId,
+		CreatedAt: session.CreatedAt,
+		ExpiresAt: session.ExpiresAt,
+	})
+	return event, err == nil, err
+}
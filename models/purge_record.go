@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Nguồn kích hoạt việc xóa vĩnh viễn
+const (
+	PurgeTriggerSchedule = "schedule"
+	PurgeTriggerManual   = "manual"
+)
+
+type PurgeRecord struct {
+	Id              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	AccountId       primitive.ObjectID `bson:"account_id" json:"account_id"`
+	Email           string             `bson:"email" json:"email"`
+	DeletedAt       time.Time          `bson:"deleted_at" json:"deleted_at"`
+	DeletedBy       primitive.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+	Trigger         string             `bson:"trigger" json:"trigger"`
+	PurgedBy        primitive.ObjectID `bson:"purged_by,omitempty" json:"purged_by,omitempty"`
+	PurgedAt        time.Time          `bson:"purged_at" json:"purged_at"`
+	SessionsDeleted int64              `bson:"sessions_deleted" json:"sessions_deleted"`
+	TokensDeleted   int64              `bson:"tokens_deleted" json:"tokens_deleted"`
+	HistoryDeleted  int64              `bson:"history_deleted" json:"history_deleted"`
+	AvatarRemoved   bool               `bson:"avatar_removed" json:"avatar_removed"`
+}
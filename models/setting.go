@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Khóa cấu hình được lưu trong collection settings
+const (
+	SettingAccountRetentionDays = "retention.accounts"
+)
+
+type Setting struct {
+	Key       string             `bson:"_id"`
+	Value     interface{}        `bson:"value"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+	UpdatedBy primitive.ObjectID `bson:"updated_by,omitempty"`
+}
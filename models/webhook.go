@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Sự kiện có thể đăng ký nhận qua webhook
+const (
+	WebhookEventAccountCreated         = "account.created"
+	WebhookEventAccountUpdated         = "account.updated"
+	WebhookEventAccountDeleted         = "account.deleted"
+	WebhookEventSessionCreated         = "session.created"
+	WebhookEventLoginApprovalRequested = "login.approval_requested"
+	// WebhookEventPing chỉ được gửi khi người dùng bấm thử webhook, không cần đăng ký
+	WebhookEventPing = "webhook.ping"
+)
+
+var WebhookEvents = []string{
+	WebhookEventAccountCreated,
+	WebhookEventAccountUpdated,
+	WebhookEventAccountDeleted,
+	WebhookEventSessionCreated,
+	WebhookEventLoginApprovalRequested,
+}
+
+// Trạng thái của một lần gửi webhook
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type WebhookSubscription struct {
+	Id          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Url         string             `bson:"url" json:"url"`
+	Events      []string           `bson:"events" json:"events"`
+	Secret      string             `bson:"secret" json:"-"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Active      bool               `bson:"active" json:"active"`
+	CreatedBy   primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+func (s WebhookSubscription) Subscribes(event string) bool {
+	for _, subscribed := range s.Events {
+		if subscribed == event || subscribed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookAttempt struct {
+	At         time.Time `bson:"at" json:"at"`
+	StatusCode int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error      string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs int64     `bson:"duration_ms" json:"duration_ms"`
+}
+
+// WebhookDelivery lưu payload đã ký cùng toàn bộ các lần gửi để tra cứu và gửi lại
+type WebhookDelivery struct {
+	Id             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SubscriptionId primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	EventId        string             `bson:"event_id" json:"event_id"`
+	Event          string             `bson:"event" json:"event"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Status         string             `bson:"status" json:"status"`
+	AttemptCount   int                `bson:"attempt_count" json:"attempt_count"`
+	Attempts       []WebhookAttempt   `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	NextAttemptAt  time.Time          `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	DeliveredAt    time.Time          `bson:"delivered_at,omitempty" json:"delivered_at,omitempty"`
+}
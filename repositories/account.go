@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"UserManagementVer/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound giữ nguyên lỗi của driver Mongo để các chỗ đang kiểm tra
+// errors.Is(err, mongo.ErrNoDocuments) vẫn đúng với mọi implementation.
+var ErrNotFound = mongo.ErrNoDocuments
+
+var ErrDuplicate = errors.New("Dữ liệu đã tồn tại")
+
+// ErrNoMatch trả về khi Update không tìm thấy tài khoản khớp query
+var ErrNoMatch = errors.New("Không có tài liệu được update")
+
+func IsDuplicate(err error) bool {
+	return errors.Is(err, ErrDuplicate) || mongo.IsDuplicateKeyError(err)
+}
+
+// Thứ tự sắp xếp của AccountQuery
+const (
+	SortDeletedAtAsc  = "deleted_at_asc"
+	SortDeletedAtDesc = "deleted_at_desc"
+)
+
+// AccountQuery là điều kiện lọc tài khoản, các trường rỗng được bỏ qua và các điều kiện được AND với nhau.
+// Ids/Emails/ImageUrls khác nil là một điều kiện, kể cả khi rỗng (khi đó không khớp tài khoản nào).
+type AccountQuery struct {
+	Ids       []primitive.ObjectID
+	ExcludeId primitive.ObjectID
+	// Email và Emails so khớp không phân biệt hoa thường, email cũng unique không phân biệt hoa thường
+	Email  string
+	Emails []string
+	// ImageUrls lọc theo image_url, dùng để kiểm tra file avatar còn được tham chiếu
+	ImageUrls []string
+	// Keyword tìm không phân biệt hoa thường trong tên hoặc email
+	Keyword string
+	// Text tìm toàn văn, tài khoản phải chứa đủ mọi từ của Text trong tên hoặc email
+	Text string
+	// Status lọc theo trạng thái đã lưu, xem models.StatusFilter
+	Status string
+	// NotDeleted chỉ lấy tài khoản chưa có deleted_at
+	NotDeleted bool
+	// DeletedAtOrBefore lấy tài khoản có deleted_at <= thời điểm này
+	DeletedAtOrBefore time.Time
+	// WithPassword đọc thẳng từ DB để lấy mật khẩu. Mọi cách đọc khác (kể cả GetAccountById)
+	// trả về Password rỗng, cache tài khoản cũng không lưu mật khẩu
+	WithPassword bool
+
+	Sort  string
+	Skip  int
+	Limit int
+}
+
+func ById(id primitive.ObjectID) AccountQuery {
+	return AccountQuery{Ids: []primitive.ObjectID{id}}
+}
+
+func ByEmail(email string) AccountQuery {
+	return AccountQuery{Email: email}
+}
+
+// AccountUpdate mô tả thay đổi trên một tài khoản. Trường nil được giữ nguyên, trường trỏ tới
+// giá trị rỗng (chuỗi rỗng, thời gian zero, id zero) bị xóa khỏi tài khoản.
+type AccountUpdate struct {
+	Name     *string
+	Email    *string
+	Password *string
+	Phone    *string
+	Dob      *time.Time
+	ImageUrl *string
+
+	ManagedBy *primitive.ObjectID
+	UpdatedAt *time.Time
+	UpdatedBy *primitive.ObjectID
+	DeletedAt *time.Time
+	DeletedBy *primitive.ObjectID
+
+	Status          *string
+	StatusReason    *string
+	SuspendedUntil  *time.Time
+	StatusChangedAt *time.Time
+	StatusChangedBy *primitive.ObjectID
+	// AppendStatusHistory được thêm vào cuối status_history
+	AppendStatusHistory *models.StatusTransition
+}
+
+// Apply áp dụng thay đổi lên bản sao tài khoản trong bộ nhớ
+func (u AccountUpdate) Apply(account models.Account) models.Account {
+	applyString(&account.Name, u.Name)
+	applyString(&account.Email, u.Email)
+	applyString(&account.Password, u.Password)
+	applyString(&account.Phone, u.Phone)
+	applyTime(&account.Dob, u.Dob)
+	applyString(&account.ImageUrl, u.ImageUrl)
+	applyId(&account.ManagedBy, u.ManagedBy)
+	applyTime(&account.UpdatedAt, u.UpdatedAt)
+	applyId(&account.UpdatedBy, u.UpdatedBy)
+	applyTime(&account.DeletedAt, u.DeletedAt)
+	applyId(&account.DeletedBy, u.DeletedBy)
+	applyString(&account.Status, u.Status)
+	applyString(&account.StatusReason, u.StatusReason)
+	applyTime(&account.SuspendedUntil, u.SuspendedUntil)
+	applyTime(&account.StatusChangedAt, u.StatusChangedAt)
+	applyId(&account.StatusChangedBy, u.StatusChangedBy)
+	if u.AppendStatusHistory != nil {
+		history := make([]models.StatusTransition, 0, len(account.StatusHistory)+1)
+		history = append(history, account.StatusHistory...)
+		account.StatusHistory = append(history, *u.AppendStatusHistory)
+	}
+	return account
+}
+
+func applyString(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func applyTime(field *time.Time, value *time.Time) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+func applyId(field *primitive.ObjectID, value *primitive.ObjectID) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+type AccountBulkUpdate struct {
+	Id primitive.ObjectID
+	// Status khác rỗng thì chỉ cập nhật khi trạng thái đã lưu vẫn là Status,
+	// ngược lại vị trí đó nhận ErrNoMatch
+	Status string
+	Update AccountUpdate
+}
+
+// AccountRepository là nơi lưu tài khoản, mọi thay đổi qua Create/Update/BulkUpdate/Purge
+// phải phát sự kiện thay đổi tương ứng nếu implementation có outbox.
+type AccountRepository interface {
+	Create(ctx context.Context, account models.Account) (primitive.ObjectID, error)
+	GetAccountById(ctx context.Context, id primitive.ObjectID) (models.Account, error)
+	// Find trả về tài khoản đầu tiên khớp query hoặc ErrNotFound
+	Find(ctx context.Context, query AccountQuery) (models.Account, error)
+	FindAll(ctx context.Context, query AccountQuery) ([]models.Account, error)
+	// Count bỏ qua Sort, Skip và Limit của query
+	Count(ctx context.Context, query AccountQuery) (int64, error)
+	// Update cập nhật một tài khoản khớp query, lỗi nếu không có tài khoản nào khớp
+	Update(ctx context.Context, query AccountQuery, update AccountUpdate) error
+	// BulkUpdate trả về lỗi của từng thao tác theo vị trí trong items
+	BulkUpdate(ctx context.Context, items []AccountBulkUpdate) (map[int]error, error)
+	// Purge xóa vĩnh viễn tài khoản nếu nó đã được PurgeService nhận xử lý (StatusPurging), trả về số tài khoản đã xóa
+	Purge(ctx context.Context, account models.Account) (int64, error)
+}
+
+// Ptr trả về con trỏ tới value, dùng khi tạo AccountUpdate
+func Ptr[T any](value T) *T {
+	return &value
+}
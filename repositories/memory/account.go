@@ -0,0 +1,228 @@
+package memory
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/utils"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccountRepository lưu tài khoản trong bộ nhớ, dùng cho test và chạy thử không cần MongoDB.
+// Repository không có outbox nên không phát sự kiện thay đổi.
+type AccountRepository struct {
+	mu       sync.RWMutex
+	accounts []models.Account
+}
+
+var _ repositories.AccountRepository = (*AccountRepository)(nil)
+
+func NewAccountRepository() *AccountRepository {
+	return &AccountRepository{}
+}
+
+func (r *AccountRepository) Create(ctx context.Context, account models.Account) (primitive.ObjectID, error) {
+	var err error
+	account.Password, err = utils.HashPassword(account.Password)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	account.CreatedAt = time.Now()
+	if account.Id.IsZero() {
+		account.Id = primitive.NewObjectID()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.indexOf(account.Id) >= 0 || r.emailTaken(account.Email, account.Id) {
+		return primitive.NilObjectID, repositories.ErrDuplicate
+	}
+	r.accounts = append(r.accounts, clone(account))
+	return account.Id, nil
+}
+
+func (r *AccountRepository) GetAccountById(ctx context.Context, id primitive.ObjectID) (models.Account, error) {
+	return r.Find(ctx, repositories.ById(id))
+}
+
+func (r *AccountRepository) Find(ctx context.Context, query repositories.AccountQuery) (models.Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, account := range r.accounts {
+		if matchAccount(account, query) {
+			return read(account, query), nil
+		}
+	}
+	return models.Account{}, repositories.ErrNotFound
+}
+
+func (r *AccountRepository) FindAll(ctx context.Context, query repositories.AccountQuery) ([]models.Account, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	accounts := []models.Account{}
+	for _, account := range r.accounts {
+		if matchAccount(account, query) {
+			accounts = append(accounts, read(account, query))
+		}
+	}
+	switch query.Sort {
+	case repositories.SortDeletedAtAsc:
+		sort.SliceStable(accounts, func(i, j int) bool { return accounts[i].DeletedAt.Before(accounts[j].DeletedAt) })
+	case repositories.SortDeletedAtDesc:
+		sort.SliceStable(accounts, func(i, j int) bool { return accounts[i].DeletedAt.After(accounts[j].DeletedAt) })
+	}
+	if query.Skip > 0 {
+		accounts = accounts[min(query.Skip, len(accounts)):]
+	}
+	if query.Limit > 0 && len(accounts) > query.Limit {
+		accounts = accounts[:query.Limit]
+	}
+	return accounts, nil
+}
+
+func (r *AccountRepository) Count(ctx context.Context, query repositories.AccountQuery) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var count int64
+	for _, account := range r.accounts {
+		if matchAccount(account, query) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *AccountRepository) Update(ctx context.Context, query repositories.AccountQuery, update repositories.AccountUpdate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, account := range r.accounts {
+		if matchAccount(account, query) {
+			if update.Email != nil && r.emailTaken(*update.Email, account.Id) {
+				return repositories.ErrDuplicate
+			}
+			r.accounts[i] = update.Apply(account)
+			return nil
+		}
+	}
+	return repositories.ErrNoMatch
+}
+
+func (r *AccountRepository) BulkUpdate(ctx context.Context, items []repositories.AccountBulkUpdate) (map[int]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	writeErrors := map[int]error{}
+	for _, item := range items {
+		if item.Update.Email != nil && r.emailTaken(*item.Update.Email, item.Id) {
+			// Giống transaction của MongoDB, một thao tác lỗi hủy cả lô
+			return writeErrors, repositories.ErrDuplicate
+		}
+	}
+	for i, item := range items {
+		// Giống UpdateOne của MongoDB, id không tồn tại không phải là lỗi
+		index := r.indexOf(item.Id)
+		if index < 0 {
+			continue
+		}
+		if item.Status != "" && !r.accounts[index].HasStoredStatus(item.Status) {
+			writeErrors[i] = repositories.ErrNoMatch
+			continue
+		}
+		r.accounts[index] = item.Update.Apply(r.accounts[index])
+	}
+	return writeErrors, nil
+}
+
+func (r *AccountRepository) Purge(ctx context.Context, account models.Account) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := r.indexOf(account.Id)
+	if index < 0 || !r.accounts[index].HasStoredStatus(models.StatusPurging) {
+		return 0, nil
+	}
+	r.accounts = slices.Delete(r.accounts, index, index+1)
+	return 1, nil
+}
+
+func (r *AccountRepository) indexOf(id primitive.ObjectID) int {
+	return slices.IndexFunc(r.accounts, func(account models.Account) bool {
+		return account.Id == id
+	})
+}
+
+// emailTaken cho biết email đã thuộc về tài khoản khác id, tương ứng unique index email_ci
+func (r *AccountRepository) emailTaken(email string, id primitive.ObjectID) bool {
+	return email != "" && slices.ContainsFunc(r.accounts, func(account models.Account) bool {
+		return strings.EqualFold(account.Email, email) && account.Id != id
+	})
+}
+
+func matchAccount(account models.Account, query repositories.AccountQuery) bool {
+	if query.Ids != nil && !slices.Contains(query.Ids, account.Id) {
+		return false
+	}
+	if !query.ExcludeId.IsZero() && account.Id == query.ExcludeId {
+		return false
+	}
+	if query.Email != "" && !strings.EqualFold(account.Email, query.Email) {
+		return false
+	}
+	if query.Emails != nil && !slices.ContainsFunc(query.Emails, func(email string) bool { return strings.EqualFold(email, account.Email) }) {
+		return false
+	}
+	if query.ImageUrls != nil && !slices.Contains(query.ImageUrls, account.ImageUrl) {
+		return false
+	}
+	if query.Keyword != "" {
+		keyword := strings.ToLower(query.Keyword)
+		if !strings.Contains(strings.ToLower(account.Name), keyword) && !strings.Contains(strings.ToLower(account.Email), keyword) {
+			return false
+		}
+	}
+	if words := textWords(query.Text); len(words) > 0 {
+		tokens := textWords(account.Name + " " + account.Email)
+		for _, word := range words {
+			if !slices.Contains(tokens, word) {
+				return false
+			}
+		}
+	}
+	if query.Status != "" && !account.HasStoredStatus(query.Status) {
+		return false
+	}
+	if query.NotDeleted && !account.DeletedAt.IsZero() {
+		return false
+	}
+	if !query.DeletedAtOrBefore.IsZero() && (account.DeletedAt.IsZero() || account.DeletedAt.After(query.DeletedAtOrBefore)) {
+		return false
+	}
+	return true
+}
+
+// textWords tách chuỗi thành các từ viết thường, dấu câu (kể cả @ và . trong email) là dấu phân cách
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// read trả về bản sao tài khoản cho query, mật khẩu chỉ được trả về khi query.WithPassword
+func read(account models.Account, query repositories.AccountQuery) models.Account {
+	account = clone(account)
+	if !query.WithPassword {
+		account.Password = ""
+	}
+	return account
+}
+
+// clone tách status_history để caller sửa bản sao không ảnh hưởng dữ liệu đã lưu
+func clone(account models.Account) models.Account {
+	account.StatusHistory = slices.Clone(account.StatusHistory)
+	return account
+}
@@ -0,0 +1,20 @@
+package memory_test
+
+import (
+	"UserManagementVer/repositories"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/repositories/repotest"
+	"testing"
+)
+
+func TestAccountRepository(t *testing.T) {
+	repotest.RunAccountRepository(t, func(t *testing.T) repositories.AccountRepository {
+		return memory.NewAccountRepository()
+	})
+}
+
+func TestSessionRepository(t *testing.T) {
+	repotest.RunSessionRepository(t, func(t *testing.T) repositories.SessionRepository {
+		return memory.NewSessionRepository()
+	})
+}
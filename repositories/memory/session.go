@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionRepository lưu session trong bộ nhớ, mỗi (user_id, device_id) chỉ có một session
+type SessionRepository struct {
+	mu       sync.RWMutex
+	sessions []models.Session
+}
+
+var _ repositories.SessionRepository = (*SessionRepository)(nil)
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{}
+}
+
+func (r *SessionRepository) FindOne(ctx context.Context, query repositories.SessionQuery) (models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, session := range r.sessions {
+		if matchSession(session, query) {
+			return session, nil
+		}
+	}
+	return models.Session{}, repositories.ErrNotFound
+}
+
+func (r *SessionRepository) Find(ctx context.Context, query repositories.SessionQuery) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := []models.Session{}
+	for _, session := range r.sessions {
+		if matchSession(session, query) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (r *SessionRepository) FindAndUpdate(ctx context.Context, session models.Session) (models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := slices.IndexFunc(r.sessions, func(existing models.Session) bool {
+		return existing.UserId == session.UserId && existing.DeviceId == session.DeviceId
+	})
+	if index < 0 {
+		session.Id = primitive.NewObjectID()
+		r.sessions = append(r.sessions, session)
+		return session, nil
+	}
+	session.Id = r.sessions[index].Id
+	r.sessions[index] = session
+	return session, nil
+}
+
+func (r *SessionRepository) DeleteSession(ctx context.Context, query repositories.SessionQuery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := slices.IndexFunc(r.sessions, func(session models.Session) bool {
+		return matchSession(session, query)
+	})
+	if index >= 0 {
+		r.sessions = slices.Delete(r.sessions, index, index+1)
+	}
+	return nil
+}
+
+func (r *SessionRepository) DeleteSessions(ctx context.Context, query repositories.SessionQuery) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before := len(r.sessions)
+	r.sessions = slices.DeleteFunc(r.sessions, func(session models.Session) bool {
+		return matchSession(session, query)
+	})
+	return int64(before - len(r.sessions)), nil
+}
+
+func matchSession(session models.Session, query repositories.SessionQuery) bool {
+	if !query.Id.IsZero() && session.Id != query.Id {
+		return false
+	}
+	if !query.UserId.IsZero() && session.UserId != query.UserId {
+		return false
+	}
+	if query.DeviceId != "" {
+		if session.DeviceId != query.DeviceId {
+			return false
+		}
+	} else if query.ExcludeDeviceId != "" && session.DeviceId == query.ExcludeDeviceId {
+		return false
+	}
+	if query.ApprovedToken != "" && session.ApprovedToken != query.ApprovedToken {
+		return false
+	}
+	if query.TrustedOnly && !session.TrustedDevice {
+		return false
+	}
+	return true
+}
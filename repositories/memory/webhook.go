@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookSubscriptionRepository lưu subscription trong bộ nhớ
+type WebhookSubscriptionRepository struct {
+	mu            sync.RWMutex
+	subscriptions []models.WebhookSubscription
+}
+
+var _ repositories.WebhookSubscriptionRepository = (*WebhookSubscriptionRepository)(nil)
+
+func NewWebhookSubscriptionRepository() *WebhookSubscriptionRepository {
+	return &WebhookSubscriptionRepository{}
+}
+
+func (r *WebhookSubscriptionRepository) Create(ctx context.Context, subscription models.WebhookSubscription) (primitive.ObjectID, error) {
+	if subscription.Id.IsZero() {
+		subscription.Id = primitive.NewObjectID()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	subscription.Events = slices.Clone(subscription.Events)
+	r.subscriptions = append(r.subscriptions, subscription)
+	return subscription.Id, nil
+}
+
+func (r *WebhookSubscriptionRepository) GetById(ctx context.Context, id primitive.ObjectID) (models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, subscription := range r.subscriptions {
+		if subscription.Id == id {
+			subscription.Events = slices.Clone(subscription.Events)
+			return subscription, nil
+		}
+	}
+	return models.WebhookSubscription{}, repositories.ErrNotFound
+}
+
+func (r *WebhookSubscriptionRepository) FindActive(ctx context.Context) ([]models.WebhookSubscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscriptions := []models.WebhookSubscription{}
+	for _, subscription := range r.subscriptions {
+		if subscription.Active {
+			subscription.Events = slices.Clone(subscription.Events)
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return subscriptions, nil
+}
+
+// WebhookDeliveryRepository lưu delivery trong bộ nhớ, mỗi (subscription_id, event_id) chỉ có một delivery
+type WebhookDeliveryRepository struct {
+	mu         sync.RWMutex
+	deliveries []models.WebhookDelivery
+}
+
+var _ repositories.WebhookDeliveryRepository = (*WebhookDeliveryRepository)(nil)
+
+func NewWebhookDeliveryRepository() *WebhookDeliveryRepository {
+	return &WebhookDeliveryRepository{}
+}
+
+func (r *WebhookDeliveryRepository) Create(ctx context.Context, delivery models.WebhookDelivery) (primitive.ObjectID, error) {
+	if delivery.Id.IsZero() {
+		delivery.Id = primitive.NewObjectID()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.deliveries {
+		if existing.Id == delivery.Id || (existing.SubscriptionId == delivery.SubscriptionId && existing.EventId == delivery.EventId) {
+			return primitive.NilObjectID, repositories.ErrDuplicate
+		}
+	}
+	delivery.Attempts = slices.Clone(delivery.Attempts)
+	r.deliveries = append(r.deliveries, delivery)
+	return delivery.Id, nil
+}
+
+func (r *WebhookDeliveryRepository) GetById(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	index := r.indexOf(id)
+	if index < 0 {
+		return models.WebhookDelivery{}, repositories.ErrNotFound
+	}
+	return r.get(index), nil
+}
+
+func (r *WebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := -1
+	for i, delivery := range r.deliveries {
+		if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		if due < 0 || delivery.NextAttemptAt.Before(r.deliveries[due].NextAttemptAt) {
+			due = i
+		}
+	}
+	if due < 0 {
+		return models.WebhookDelivery{}, repositories.ErrNotFound
+	}
+	r.deliveries[due].NextAttemptAt = now.Add(lease)
+	return r.get(due), nil
+}
+
+func (r *WebhookDeliveryRepository) SaveAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := r.indexOf(id)
+	if index < 0 {
+		return errors.New("Không có delivery được update")
+	}
+	delivery := &r.deliveries[index]
+	delivery.Status = status
+	if status == models.WebhookDeliverySucceeded {
+		delivery.DeliveredAt = attempt.At
+	}
+	delivery.AttemptCount++
+	delivery.Attempts = append(slices.Clone(delivery.Attempts), attempt)
+	delivery.NextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (r *WebhookDeliveryRepository) Reset(ctx context.Context, id primitive.ObjectID, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := r.indexOf(id)
+	if index < 0 {
+		return errors.New("Không có delivery được update")
+	}
+	delivery := &r.deliveries[index]
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.AttemptCount = 0
+	delivery.NextAttemptAt = now
+	return nil
+}
+
+func (r *WebhookDeliveryRepository) indexOf(id primitive.ObjectID) int {
+	return slices.IndexFunc(r.deliveries, func(delivery models.WebhookDelivery) bool {
+		return delivery.Id == id
+	})
+}
+
+func (r *WebhookDeliveryRepository) get(index int) models.WebhookDelivery {
+	delivery := r.deliveries[index]
+	delivery.Attempts = slices.Clone(delivery.Attempts)
+	return delivery
+}
@@ -0,0 +1,19 @@
+package repositories
+
+import (
+	"UserManagementVer/models"
+	"context"
+	"time"
+)
+
+// OutboxRepository là nơi relay đọc sự kiện outbox. Sự kiện được ghi cùng transaction với thay đổi
+// dữ liệu nên outbox phải nằm cùng backend với tài khoản và session.
+type OutboxRepository interface {
+	// ClaimDue giữ sự kiện pending đến hạn sớm nhất bằng cách đẩy next_attempt_at tới now + lease
+	// để relay khác không phát trùng cùng lúc, trả về ErrNotFound khi không còn sự kiện đến hạn
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (models.OutboxEvent, error)
+	// SaveAttempt ghi kết quả lần phát: status, attempts, delivered_sinks, last_error, next_attempt_at, published_at
+	SaveAttempt(ctx context.Context, event models.OutboxEvent) error
+	// DeletePublished xóa sự kiện đã phát trước thời điểm before
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,446 @@
+// Package repotest chứa bộ test dùng chung cho mọi implementation của repositories,
+// mỗi backend gọi Run... trong file test của mình để đảm bảo hành vi giống nhau.
+package repotest
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RunAccountRepository chạy bộ test trên repository rỗng do newRepo tạo cho từng test con
+func RunAccountRepository(t *testing.T, newRepo func(t *testing.T) repositories.AccountRepository) {
+	tests := map[string]func(t *testing.T, repo repositories.AccountRepository){
+		"CreateAndFind":        testCreateAndFind,
+		"DuplicateEmail":       testDuplicateEmail,
+		"EmailCaseInsensitive": testEmailCaseInsensitive,
+		"Update":               testUpdate,
+		"UpdateUnset":          testUpdateUnset,
+		"UpdateNoMatch":        testUpdateNoMatch,
+		"UpdateDuplicateEmail": testUpdateDuplicateEmail,
+		"StatusHistory":        testStatusHistory,
+		"Keyword":              testKeyword,
+		"Text":                 testText,
+		"StatusFilter":         testStatusFilter,
+		"Deleted":              testDeleted,
+		"SortSkipLimit":        testSortSkipLimit,
+		"IdsAndEmails":         testIdsAndEmails,
+		"BulkUpdate":           testBulkUpdate,
+		"Purge":                testPurge,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func testCreateAndFind(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	dob := time.Date(1995, 3, 14, 0, 0, 0, 0, time.UTC)
+	id := mustCreate(t, repo, models.Account{
+		Name:     "Nguyễn Văn A",
+		Email:    "a@example.com",
+		Password: "secret123",
+		Phone:    "0900000001",
+		Dob:      dob,
+		Status:   models.StatusActive,
+	})
+
+	account, err := repo.GetAccountById(ctx, id)
+	if err != nil {
+		t.Fatalf("GetAccountById: %v", err)
+	}
+	if account.Id != id || account.Name != "Nguyễn Văn A" || account.Email != "a@example.com" || account.Phone != "0900000001" {
+		t.Fatalf("tài khoản đọc lại không khớp: %+v", account)
+	}
+	if account.Password != "" {
+		t.Fatal("GetAccountById không được trả về mật khẩu")
+	}
+	withPassword, err := repo.Find(ctx, repositories.AccountQuery{Ids: []primitive.ObjectID{id}, WithPassword: true})
+	if err != nil || withPassword.Password == "secret123" || withPassword.Password == "" {
+		t.Fatalf("mật khẩu phải được hash, nhận %q %v", withPassword.Password, err)
+	}
+	expectTime(t, "dob", account.Dob, dob)
+	if account.CreatedAt.IsZero() {
+		t.Fatal("created_at phải được gán khi tạo")
+	}
+
+	byEmail, err := repo.Find(ctx, repositories.ByEmail("a@example.com"))
+	if err != nil || byEmail.Id != id {
+		t.Fatalf("Find theo email: %v %v", byEmail.Id, err)
+	}
+	all, err := repo.FindAll(ctx, repositories.AccountQuery{})
+	if err != nil || len(all) != 1 || byEmail.Password != "" || all[0].Password != "" {
+		t.Fatal("Find/FindAll không có WithPassword không được trả về mật khẩu")
+	}
+	if _, err := repo.GetAccountById(ctx, primitive.NewObjectID()); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("id không tồn tại phải trả ErrNotFound, nhận %v", err)
+	}
+	if _, err := repo.Find(ctx, repositories.ByEmail("missing@example.com")); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("email không tồn tại phải trả ErrNotFound, nhận %v", err)
+	}
+}
+
+func testDuplicateEmail(t *testing.T, repo repositories.AccountRepository) {
+	mustCreate(t, repo, models.Account{Name: "A", Email: "dup@example.com", Password: "secret123"})
+	_, err := repo.Create(context.Background(), models.Account{Name: "B", Email: "dup@example.com", Password: "secret123"})
+	if !repositories.IsDuplicate(err) {
+		t.Fatalf("email trùng phải trả lỗi duplicate, nhận %v", err)
+	}
+}
+
+func testEmailCaseInsensitive(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	id := mustCreate(t, repo, models.Account{Name: "A", Email: "Chi@Example.com", Password: "secret123"})
+
+	if account, err := repo.Find(ctx, repositories.ByEmail("chi@example.com")); err != nil || account.Id != id {
+		t.Fatalf("Find theo email phải không phân biệt hoa thường, nhận %+v %v", account, err)
+	}
+	expectEmails(t, repo, repositories.AccountQuery{Emails: []string{"CHI@EXAMPLE.COM"}}, "Chi@Example.com")
+	if _, err := repo.Create(ctx, models.Account{Name: "B", Email: "chi@example.com", Password: "secret123"}); !repositories.IsDuplicate(err) {
+		t.Fatalf("email chỉ khác hoa thường phải trả lỗi duplicate, nhận %v", err)
+	}
+}
+
+func testUpdate(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	id := mustCreate(t, repo, models.Account{Name: "Cũ", Email: "update@example.com", Password: "secret123", Phone: "0900000002"})
+	updatedBy := primitive.NewObjectID()
+	updatedAt := time.Now()
+
+	err := repo.Update(ctx, repositories.ById(id), repositories.AccountUpdate{
+		Name:      repositories.Ptr("Mới"),
+		UpdatedAt: &updatedAt,
+		UpdatedBy: &updatedBy,
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	account := mustGet(t, repo, id)
+	if account.Name != "Mới" || account.UpdatedBy != updatedBy {
+		t.Fatalf("Update không được áp dụng: %+v", account)
+	}
+	if account.Phone != "0900000002" || account.Email != "update@example.com" {
+		t.Fatalf("trường nil phải được giữ nguyên: %+v", account)
+	}
+	expectTime(t, "updated_at", account.UpdatedAt, updatedAt)
+}
+
+func testUpdateUnset(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	managedBy := primitive.NewObjectID()
+	id := mustCreate(t, repo, models.Account{
+		Name:      "Unset",
+		Email:     "unset@example.com",
+		Password:  "secret123",
+		Phone:     "0900000003",
+		Dob:       time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		ManagedBy: managedBy,
+	})
+	err := repo.Update(ctx, repositories.ById(id), repositories.AccountUpdate{
+		Phone:     repositories.Ptr(""),
+		Dob:       &time.Time{},
+		ManagedBy: &primitive.ObjectID{},
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	account := mustGet(t, repo, id)
+	if account.Phone != "" || !account.Dob.IsZero() || !account.ManagedBy.IsZero() {
+		t.Fatalf("giá trị rỗng phải xóa trường khỏi tài khoản: %+v", account)
+	}
+	if account.Name != "Unset" {
+		t.Fatalf("trường không đổi bị mất: %+v", account)
+	}
+}
+
+func testUpdateNoMatch(t *testing.T, repo repositories.AccountRepository) {
+	err := repo.Update(context.Background(), repositories.ById(primitive.NewObjectID()), repositories.AccountUpdate{Name: repositories.Ptr("X")})
+	if !errors.Is(err, repositories.ErrNoMatch) {
+		t.Fatalf("Update không khớp tài khoản nào phải trả ErrNoMatch, nhận %v", err)
+	}
+}
+
+func testUpdateDuplicateEmail(t *testing.T, repo repositories.AccountRepository) {
+	mustCreate(t, repo, models.Account{Name: "A", Email: "taken@example.com", Password: "secret123"})
+	id := mustCreate(t, repo, models.Account{Name: "B", Email: "free@example.com", Password: "secret123"})
+	err := repo.Update(context.Background(), repositories.ById(id), repositories.AccountUpdate{Email: repositories.Ptr("taken@example.com")})
+	if !repositories.IsDuplicate(err) {
+		t.Fatalf("đổi sang email đã dùng phải trả lỗi duplicate, nhận %v", err)
+	}
+	if account := mustGet(t, repo, id); account.Email != "free@example.com" {
+		t.Fatalf("email không được đổi khi lỗi, nhận %q", account.Email)
+	}
+}
+
+func testStatusHistory(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	id := mustCreate(t, repo, models.Account{Name: "H", Email: "history@example.com", Password: "secret123", Status: models.StatusActive})
+	actor := primitive.NewObjectID()
+	for _, status := range []string{models.StatusSuspended, models.StatusActive} {
+		current := mustGet(t, repo, id)
+		changedAt := time.Now()
+		err := repo.Update(ctx, repositories.ById(id), repositories.AccountUpdate{
+			Status:          repositories.Ptr(status),
+			StatusChangedAt: &changedAt,
+			StatusChangedBy: &actor,
+			AppendStatusHistory: &models.StatusTransition{
+				From:      current.Status,
+				To:        status,
+				ChangedAt: changedAt,
+				ChangedBy: actor,
+			},
+		})
+		if err != nil {
+			t.Fatalf("Update status %s: %v", status, err)
+		}
+	}
+	account := mustGet(t, repo, id)
+	if account.Status != models.StatusActive || len(account.StatusHistory) != 2 {
+		t.Fatalf("status_history phải có 2 bản ghi, nhận %+v", account.StatusHistory)
+	}
+	first, second := account.StatusHistory[0], account.StatusHistory[1]
+	if first.From != models.StatusActive || first.To != models.StatusSuspended || second.To != models.StatusActive || first.ChangedBy != actor {
+		t.Fatalf("status_history sai thứ tự hoặc nội dung: %+v", account.StatusHistory)
+	}
+}
+
+func testKeyword(t *testing.T, repo repositories.AccountRepository) {
+	mustCreate(t, repo, models.Account{Name: "Trần Thị Bình", Email: "binh@example.com", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "Lê Văn Cường", Email: "cuong@corp.vn", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "100%_special", Email: "special@example.com", Password: "secret123"})
+
+	expectEmails(t, repo, repositories.AccountQuery{Keyword: "BÌNH"}, "binh@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Keyword: "corp"}, "cuong@corp.vn")
+	expectEmails(t, repo, repositories.AccountQuery{Keyword: "example"}, "binh@example.com", "special@example.com")
+	// Ký tự đặc biệt của LIKE/regex phải được so khớp như ký tự thường
+	expectEmails(t, repo, repositories.AccountQuery{Keyword: "%_"}, "special@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Keyword: ".*"})
+}
+
+func testText(t *testing.T, repo repositories.AccountRepository) {
+	mustCreate(t, repo, models.Account{Name: "Nguyễn Văn An", Email: "an.nguyen@example.com", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "Nguyễn Thị Hoa", Email: "hoa@corp.vn", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "Phạm An", Email: "pham@corp.vn", Password: "secret123"})
+
+	expectEmails(t, repo, repositories.AccountQuery{Text: "nguyễn"}, "an.nguyen@example.com", "hoa@corp.vn")
+	// Mọi từ đều phải xuất hiện
+	expectEmails(t, repo, repositories.AccountQuery{Text: "Nguyễn An"}, "an.nguyen@example.com")
+	// Từ trong email được tách theo dấu câu
+	expectEmails(t, repo, repositories.AccountQuery{Text: "corp"}, "hoa@corp.vn", "pham@corp.vn")
+	expectEmails(t, repo, repositories.AccountQuery{Text: "nguyen example"}, "an.nguyen@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Text: "không có"})
+}
+
+func testStatusFilter(t *testing.T, repo repositories.AccountRepository) {
+	deletedAt := time.Now().Add(-time.Hour)
+	mustCreate(t, repo, models.Account{Name: "A", Email: "active@example.com", Password: "secret123", Status: models.StatusActive})
+	mustCreate(t, repo, models.Account{Name: "B", Email: "suspended@example.com", Password: "secret123", Status: models.StatusSuspended})
+	mustCreate(t, repo, models.Account{Name: "C", Email: "deleted@example.com", Password: "secret123", Status: models.StatusDeleted, DeletedAt: deletedAt})
+	// Tài khoản cũ chưa có status, trạng thái suy ra từ deleted_at
+	mustCreate(t, repo, models.Account{Name: "D", Email: "legacy@example.com", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "E", Email: "legacy-deleted@example.com", Password: "secret123", DeletedAt: deletedAt})
+
+	expectEmails(t, repo, repositories.AccountQuery{Status: models.StatusActive}, "active@example.com", "legacy@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Status: models.StatusDeleted}, "deleted@example.com", "legacy-deleted@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Status: models.StatusSuspended}, "suspended@example.com")
+	expectCount(t, repo, repositories.AccountQuery{Status: models.StatusLocked}, 0)
+}
+
+func testDeleted(t *testing.T, repo repositories.AccountRepository) {
+	now := time.Now()
+	mustCreate(t, repo, models.Account{Name: "A", Email: "alive@example.com", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "B", Email: "old@example.com", Password: "secret123", Status: models.StatusDeleted, DeletedAt: now.Add(-48 * time.Hour)})
+	mustCreate(t, repo, models.Account{Name: "C", Email: "recent@example.com", Password: "secret123", Status: models.StatusDeleted, DeletedAt: now.Add(-time.Hour)})
+
+	expectEmails(t, repo, repositories.AccountQuery{NotDeleted: true}, "alive@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{DeletedAtOrBefore: now.Add(-24 * time.Hour)}, "old@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{DeletedAtOrBefore: now}, "old@example.com", "recent@example.com")
+}
+
+func testSortSkipLimit(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	now := time.Now()
+	emails := []string{"d1@example.com", "d2@example.com", "d3@example.com"}
+	// Tạo theo thứ tự ngược để thứ tự trả về không trùng với thứ tự chèn
+	for i := len(emails) - 1; i >= 0; i-- {
+		mustCreate(t, repo, models.Account{Name: "Sort", Email: emails[i], Password: "secret123", Status: models.StatusDeleted, DeletedAt: now.Add(time.Duration(i-10) * time.Hour)})
+	}
+
+	query := repositories.AccountQuery{Status: models.StatusDeleted, Sort: repositories.SortDeletedAtAsc}
+	expectOrder(t, repo, query, emails...)
+	query.Sort = repositories.SortDeletedAtDesc
+	expectOrder(t, repo, query, emails[2], emails[1], emails[0])
+	query.Skip, query.Limit = 1, 1
+	expectOrder(t, repo, query, emails[1])
+
+	// Count bỏ qua Skip/Limit
+	count, err := repo.Count(ctx, query)
+	if err != nil || count != 3 {
+		t.Fatalf("Count phải bỏ qua Skip/Limit, nhận %d %v", count, err)
+	}
+}
+
+func testIdsAndEmails(t *testing.T, repo repositories.AccountRepository) {
+	first := mustCreate(t, repo, models.Account{Name: "A", Email: "ids1@example.com", Password: "secret123"})
+	second := mustCreate(t, repo, models.Account{Name: "B", Email: "ids2@example.com", Password: "secret123"})
+	mustCreate(t, repo, models.Account{Name: "C", Email: "ids3@example.com", Password: "secret123"})
+
+	expectEmails(t, repo, repositories.AccountQuery{Ids: []primitive.ObjectID{first, second}}, "ids1@example.com", "ids2@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Ids: []primitive.ObjectID{first, second}, ExcludeId: first}, "ids2@example.com")
+	expectEmails(t, repo, repositories.AccountQuery{Emails: []string{"ids3@example.com", "missing@example.com"}}, "ids3@example.com")
+	// Ids/Emails rỗng nhưng khác nil không khớp tài khoản nào
+	expectCount(t, repo, repositories.AccountQuery{Ids: []primitive.ObjectID{}}, 0)
+	expectCount(t, repo, repositories.AccountQuery{Emails: []string{}}, 0)
+	expectCount(t, repo, repositories.AccountQuery{}, 3)
+
+	if err := repo.Update(context.Background(), repositories.ById(second), repositories.AccountUpdate{ImageUrl: repositories.Ptr("avatars/b/1")}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	expectEmails(t, repo, repositories.AccountQuery{ImageUrls: []string{"avatars/b/1", "avatars/x/2"}}, "ids2@example.com")
+	expectCount(t, repo, repositories.AccountQuery{ImageUrls: []string{}}, 0)
+}
+
+func testBulkUpdate(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	first := mustCreate(t, repo, models.Account{Name: "A", Email: "bulk1@example.com", Password: "secret123", Status: models.StatusActive})
+	second := mustCreate(t, repo, models.Account{Name: "B", Email: "bulk2@example.com", Password: "secret123", Status: models.StatusActive})
+
+	writeErrors, err := repo.BulkUpdate(ctx, []repositories.AccountBulkUpdate{
+		{Id: first, Update: repositories.AccountUpdate{Status: repositories.Ptr(models.StatusLocked)}},
+		{Id: primitive.NewObjectID(), Update: repositories.AccountUpdate{Status: repositories.Ptr(models.StatusLocked)}},
+		{Id: second, Update: repositories.AccountUpdate{Name: repositories.Ptr("B2")}},
+	})
+	if err != nil || len(writeErrors) != 0 {
+		t.Fatalf("BulkUpdate: %v %v", writeErrors, err)
+	}
+	if account := mustGet(t, repo, first); account.Status != models.StatusLocked {
+		t.Fatalf("BulkUpdate không đổi status: %+v", account)
+	}
+	if account := mustGet(t, repo, second); account.Name != "B2" || account.Status != models.StatusActive {
+		t.Fatalf("BulkUpdate không đổi tên: %+v", account)
+	}
+
+	// Điều kiện status không còn đúng thì vị trí đó lỗi, các vị trí khác vẫn được cập nhật
+	writeErrors, err = repo.BulkUpdate(ctx, []repositories.AccountBulkUpdate{
+		{Id: first, Status: models.StatusActive, Update: repositories.AccountUpdate{Status: repositories.Ptr(models.StatusSuspended)}},
+		{Id: second, Status: models.StatusActive, Update: repositories.AccountUpdate{Status: repositories.Ptr(models.StatusSuspended)}},
+	})
+	if err != nil || len(writeErrors) != 1 || !errors.Is(writeErrors[0], repositories.ErrNoMatch) {
+		t.Fatalf("BulkUpdate có điều kiện status: %v %v", writeErrors, err)
+	}
+	if account := mustGet(t, repo, first); account.Status != models.StatusLocked {
+		t.Fatalf("BulkUpdate không được ghi đè khi status đã đổi: %+v", account)
+	}
+	if account := mustGet(t, repo, second); account.Status != models.StatusSuspended {
+		t.Fatalf("BulkUpdate không đổi status: %+v", account)
+	}
+}
+
+func testPurge(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	alive := mustCreate(t, repo, models.Account{Name: "A", Email: "keep@example.com", Password: "secret123", Status: models.StatusActive})
+	trashed := mustCreate(t, repo, models.Account{Name: "B", Email: "trash@example.com", Password: "secret123", Status: models.StatusDeleted, DeletedAt: time.Now()})
+
+	deleted, err := repo.Purge(ctx, models.Account{Id: alive})
+	if err != nil || deleted != 0 {
+		t.Fatalf("Purge tài khoản chưa xóa phải bỏ qua, nhận %d %v", deleted, err)
+	}
+	deleted, err = repo.Purge(ctx, models.Account{Id: trashed})
+	if err != nil || deleted != 0 {
+		t.Fatalf("Purge tài khoản chưa được nhận xử lý phải bỏ qua, nhận %d %v", deleted, err)
+	}
+	if err := repo.Update(ctx, repositories.AccountQuery{Ids: []primitive.ObjectID{trashed}, Status: models.StatusDeleted}, repositories.AccountUpdate{Status: repositories.Ptr(models.StatusPurging)}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	deleted, err = repo.Purge(ctx, models.Account{Id: trashed})
+	if err != nil || deleted != 1 {
+		t.Fatalf("Purge tài khoản trong thùng rác: %d %v", deleted, err)
+	}
+	if _, err := repo.GetAccountById(ctx, trashed); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("tài khoản đã purge vẫn còn: %v", err)
+	}
+	mustGet(t, repo, alive)
+}
+
+func mustCreate(t *testing.T, repo repositories.AccountRepository, account models.Account) primitive.ObjectID {
+	t.Helper()
+	id, err := repo.Create(context.Background(), account)
+	if err != nil {
+		t.Fatalf("Create %s: %v", account.Email, err)
+	}
+	return id
+}
+
+func mustGet(t *testing.T, repo repositories.AccountRepository, id primitive.ObjectID) models.Account {
+	t.Helper()
+	account, err := repo.GetAccountById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetAccountById %s: %v", id.Hex(), err)
+	}
+	return account
+}
+
+// expectEmails kiểm tra tập email khớp query, không phụ thuộc thứ tự
+func expectEmails(t *testing.T, repo repositories.AccountRepository, query repositories.AccountQuery, emails ...string) {
+	t.Helper()
+	accounts, err := repo.FindAll(context.Background(), query)
+	if err != nil {
+		t.Fatalf("FindAll %+v: %v", query, err)
+	}
+	got := map[string]bool{}
+	for _, account := range accounts {
+		got[account.Email] = true
+	}
+	if len(got) != len(emails) || len(accounts) != len(emails) {
+		t.Fatalf("query %+v: muốn %v, nhận %v", query, emails, got)
+	}
+	for _, email := range emails {
+		if !got[email] {
+			t.Fatalf("query %+v: muốn %v, nhận %v", query, emails, got)
+		}
+	}
+	expectCount(t, repo, query, int64(len(emails)))
+}
+
+func expectOrder(t *testing.T, repo repositories.AccountRepository, query repositories.AccountQuery, emails ...string) {
+	t.Helper()
+	accounts, err := repo.FindAll(context.Background(), query)
+	if err != nil {
+		t.Fatalf("FindAll %+v: %v", query, err)
+	}
+	got := make([]string, 0, len(accounts))
+	for _, account := range accounts {
+		got = append(got, account.Email)
+	}
+	if len(got) != len(emails) {
+		t.Fatalf("query %+v: muốn %v, nhận %v", query, emails, got)
+	}
+	for i := range emails {
+		if got[i] != emails[i] {
+			t.Fatalf("query %+v: muốn %v, nhận %v", query, emails, got)
+		}
+	}
+}
+
+func expectCount(t *testing.T, repo repositories.AccountRepository, query repositories.AccountQuery, want int64) {
+	t.Helper()
+	count, err := repo.Count(context.Background(), query)
+	if err != nil || count != want {
+		t.Fatalf("Count %+v: muốn %d, nhận %d %v", query, want, count, err)
+	}
+}
+
+// expectTime so sánh thời gian với sai số mili giây vì MongoDB chỉ lưu tới mili giây
+func expectTime(t *testing.T, field string, got time.Time, want time.Time) {
+	t.Helper()
+	if diff := got.Sub(want); diff > time.Millisecond || diff < -time.Millisecond {
+		t.Fatalf("%s: muốn %v, nhận %v", field, want, got)
+	}
+}
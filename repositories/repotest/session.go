@@ -0,0 +1,159 @@
+package repotest
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RunSessionRepository chạy bộ test trên repository rỗng do newRepo tạo cho từng test con
+func RunSessionRepository(t *testing.T, newRepo func(t *testing.T) repositories.SessionRepository) {
+	tests := map[string]func(t *testing.T, repo repositories.SessionRepository){
+		"Upsert":         testSessionUpsert,
+		"FindOrder":      testSessionFindOrder,
+		"Filters":        testSessionFilters,
+		"DeleteSession":  testDeleteSession,
+		"DeleteSessions": testDeleteSessions,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newRepo(t))
+		})
+	}
+}
+
+func testSessionUpsert(t *testing.T, repo repositories.SessionRepository) {
+	ctx := context.Background()
+	userId := primitive.NewObjectID()
+	createdAt := time.Now().Add(-time.Minute)
+	first := mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "laptop", RefreshToken: "token-1", CreatedAt: createdAt})
+	if first.Id.IsZero() {
+		t.Fatal("FindAndUpdate phải trả về id của session")
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	second := mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "laptop", RefreshToken: "token-2", TrustedDevice: true, CreatedAt: createdAt, ExpiresAt: expiresAt})
+	if second.Id != first.Id {
+		t.Fatalf("cùng user và thiết bị phải giữ nguyên session, nhận %s và %s", first.Id.Hex(), second.Id.Hex())
+	}
+
+	session, err := repo.FindOne(ctx, repositories.SessionQuery{UserId: userId, DeviceId: "laptop"})
+	if err != nil {
+		t.Fatalf("FindOne: %v", err)
+	}
+	if session.RefreshToken != "token-2" || !session.TrustedDevice {
+		t.Fatalf("session không được cập nhật: %+v", session)
+	}
+	expectTime(t, "expires_at", session.ExpiresAt, expiresAt)
+	expectTime(t, "created_at", session.CreatedAt, createdAt)
+
+	mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "phone", RefreshToken: "token-3", CreatedAt: time.Now()})
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId}, 2)
+
+	if _, err := repo.FindOne(ctx, repositories.SessionQuery{UserId: userId, DeviceId: "tablet"}); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("session không tồn tại phải trả ErrNotFound, nhận %v", err)
+	}
+}
+
+func testSessionFindOrder(t *testing.T, repo repositories.SessionRepository) {
+	ctx := context.Background()
+	userId := primitive.NewObjectID()
+	now := time.Now()
+	devices := []string{"d1", "d2", "d3"}
+	// Tạo theo thứ tự ngược để kết quả không trùng thứ tự chèn
+	for i := len(devices) - 1; i >= 0; i-- {
+		mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: devices[i], CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	sessions, err := repo.Find(ctx, repositories.SessionQuery{UserId: userId})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(sessions) != len(devices) {
+		t.Fatalf("muốn %d session, nhận %d", len(devices), len(sessions))
+	}
+	for i, session := range sessions {
+		if session.DeviceId != devices[i] {
+			t.Fatalf("Find phải sắp xếp theo created_at tăng dần, vị trí %d là %s", i, session.DeviceId)
+		}
+	}
+}
+
+func testSessionFilters(t *testing.T, repo repositories.SessionRepository) {
+	userId := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+	now := time.Now()
+	mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "current", TrustedDevice: true, CreatedAt: now})
+	mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "trusted", TrustedDevice: true, CreatedAt: now})
+	mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "pending", ApprovedToken: "approve-me", CreatedAt: now})
+	mustUpsert(t, repo, models.Session{UserId: other, DeviceId: "current", CreatedAt: now})
+
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId}, 3)
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId, ExcludeDeviceId: "current"}, 2)
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId, TrustedOnly: true}, 2)
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId, TrustedOnly: true, ExcludeDeviceId: "current"}, 1)
+	// DeviceId được ưu tiên hơn ExcludeDeviceId
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId, DeviceId: "current", ExcludeDeviceId: "current"}, 1)
+	expectSessions(t, repo, repositories.SessionQuery{ApprovedToken: "approve-me"}, 1)
+	expectSessions(t, repo, repositories.SessionQuery{DeviceId: "current"}, 2)
+}
+
+func testDeleteSession(t *testing.T, repo repositories.SessionRepository) {
+	ctx := context.Background()
+	userId := primitive.NewObjectID()
+	session := mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "laptop", CreatedAt: time.Now()})
+	mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: "phone", CreatedAt: time.Now()})
+
+	if err := repo.DeleteSession(ctx, repositories.SessionQuery{Id: session.Id}); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if _, err := repo.FindOne(ctx, repositories.SessionQuery{Id: session.Id}); !errors.Is(err, repositories.ErrNotFound) {
+		t.Fatalf("session đã xóa vẫn còn: %v", err)
+	}
+	// DeleteSession chỉ xóa một session dù query khớp nhiều
+	if err := repo.DeleteSession(ctx, repositories.SessionQuery{UserId: userId}); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId}, 0)
+	if err := repo.DeleteSession(ctx, repositories.SessionQuery{UserId: userId}); err != nil {
+		t.Fatalf("DeleteSession không khớp session nào không được lỗi: %v", err)
+	}
+}
+
+func testDeleteSessions(t *testing.T, repo repositories.SessionRepository) {
+	ctx := context.Background()
+	userId := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+	for _, device := range []string{"current", "d1", "d2"} {
+		mustUpsert(t, repo, models.Session{UserId: userId, DeviceId: device, CreatedAt: time.Now()})
+	}
+	mustUpsert(t, repo, models.Session{UserId: other, DeviceId: "d1", CreatedAt: time.Now()})
+
+	deleted, err := repo.DeleteSessions(ctx, repositories.SessionQuery{UserId: userId, ExcludeDeviceId: "current"})
+	if err != nil || deleted != 2 {
+		t.Fatalf("DeleteSessions phải xóa 2 session, nhận %d %v", deleted, err)
+	}
+	expectSessions(t, repo, repositories.SessionQuery{UserId: userId}, 1)
+	expectSessions(t, repo, repositories.SessionQuery{UserId: other}, 1)
+}
+
+func mustUpsert(t *testing.T, repo repositories.SessionRepository, session models.Session) models.Session {
+	t.Helper()
+	saved, err := repo.FindAndUpdate(context.Background(), session)
+	if err != nil {
+		t.Fatalf("FindAndUpdate %s: %v", session.DeviceId, err)
+	}
+	return saved
+}
+
+func expectSessions(t *testing.T, repo repositories.SessionRepository, query repositories.SessionQuery, want int) {
+	t.Helper()
+	sessions, err := repo.Find(context.Background(), query)
+	if err != nil || len(sessions) != want {
+		t.Fatalf("Find %+v: muốn %d session, nhận %d %v", query, want, len(sessions), err)
+	}
+}
@@ -0,0 +1,28 @@
+package repositories
+
+import (
+	"UserManagementVer/models"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SessionQuery là điều kiện lọc session, các trường rỗng được bỏ qua
+type SessionQuery struct {
+	Id              primitive.ObjectID
+	UserId          primitive.ObjectID
+	DeviceId        string
+	ExcludeDeviceId string
+	ApprovedToken   string
+	TrustedOnly     bool
+}
+
+type SessionRepository interface {
+	FindOne(ctx context.Context, query SessionQuery) (models.Session, error)
+	// Find trả về các session khớp query, sắp xếp theo created_at tăng dần
+	Find(ctx context.Context, query SessionQuery) ([]models.Session, error)
+	// FindAndUpdate tạo hoặc cập nhật session theo (user_id, device_id) và trả về session sau khi ghi
+	FindAndUpdate(ctx context.Context, session models.Session) (models.Session, error)
+	DeleteSession(ctx context.Context, query SessionQuery) error
+	DeleteSessions(ctx context.Context, query SessionQuery) (int64, error)
+}
@@ -0,0 +1,32 @@
+package repositories
+
+import (
+	"UserManagementVer/models"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebhookSubscriptionRepository là nơi WebhookService đọc các subscription,
+// việc quản lý subscription vẫn do controller thực hiện trực tiếp trên collection.
+type WebhookSubscriptionRepository interface {
+	GetById(ctx context.Context, id primitive.ObjectID) (models.WebhookSubscription, error)
+	// FindActive trả về các subscription đang bật
+	FindActive(ctx context.Context) ([]models.WebhookSubscription, error)
+}
+
+// WebhookDeliveryRepository là nơi worker webhook lưu delivery và kết quả từng lần gửi.
+// Mỗi (subscription_id, event_id) chỉ có một delivery, tạo trùng trả về lỗi thỏa IsDuplicate.
+type WebhookDeliveryRepository interface {
+	Create(ctx context.Context, delivery models.WebhookDelivery) (primitive.ObjectID, error)
+	GetById(ctx context.Context, id primitive.ObjectID) (models.WebhookDelivery, error)
+	// ClaimDue giữ delivery pending đến hạn sớm nhất bằng cách đẩy next_attempt_at tới now + lease
+	// để worker khác không gửi trùng, trả về ErrNotFound khi không còn delivery đến hạn
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (models.WebhookDelivery, error)
+	// SaveAttempt thêm attempt vào lịch sử, tăng attempt_count và đặt status. nextAttemptAt zero thì
+	// bỏ next_attempt_at, status succeeded thì delivered_at là thời điểm của attempt
+	SaveAttempt(ctx context.Context, id primitive.ObjectID, attempt models.WebhookAttempt, status string, nextAttemptAt time.Time) error
+	// Reset đưa delivery về pending với attempt_count 0 và next_attempt_at là now, lịch sử được giữ lại
+	Reset(ctx context.Context, id primitive.ObjectID, now time.Time) error
+}
@@ -2,8 +2,6 @@ package routers
 
 import (
 	"UserManagementVer/controllers"
-	"UserManagementVer/middlewares"
-	"UserManagementVer/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,19 +14,33 @@ func NewAccountRouter(accountController *controllers.AccountController) *Account
 	return &AccountRouter{accountController: accountController}
 }
 
-func (accountRouter *AccountRouter) RegisterRoutes(router *gin.RouterGroup, jwtService *services.JwtService) {
+func (accountRouter *AccountRouter) RegisterRoutes(router *gin.RouterGroup, authorize gin.HandlerFunc) {
 	accountRou := router.Group("/accounts")
 	{
-		accountRou.GET("/:id/detail", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.FindAccountById)
-		accountRou.POST("/add", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.CreateAccount)
-		accountRou.PATCH("/:id", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.UpdateAccount)
-		accountRou.PATCH("/:id/restore", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.RestoreAccount)
-		accountRou.PATCH("/:id/soft-delete", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.SoftDelete)
-		accountRou.GET("/search", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.SearchAccount)
-		accountRou.POST("/:id/update-avatar", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.UploadImage)
-		accountRou.GET("/:id/avatar", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.GetAvatar)
-		accountRou.PATCH("/time-to-live", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.UpdateTimeToLiveHardDelete)
-		accountRou.GET("/export/excel", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.DownloadAccountsExcel)
-		accountRou.POST("/:id/forgot-password", middlewares.AuthorizeJWT(jwtService), accountRouter.accountController.RestorePassword)
+		accountRou.GET("/:id/detail", authorize, accountRouter.accountController.FindAccountById)
+		accountRou.POST("/add", authorize, accountRouter.accountController.CreateAccount)
+		accountRou.PATCH("/:id", authorize, accountRouter.accountController.UpdateAccount)
+		accountRou.PATCH("/:id/status", authorize, accountRouter.accountController.ChangeStatus)
+		accountRou.POST("/bulk", authorize, accountRouter.accountController.BulkAccounts)
+		accountRou.GET("/trash", authorize, accountRouter.accountController.ListTrash)
+		accountRou.DELETE("/trash", authorize, accountRouter.accountController.HardDelete)
+		accountRou.GET("/search", authorize, accountRouter.accountController.SearchAccount)
+		accountRou.GET("/cache/stats", authorize, accountRouter.accountController.CacheStats)
+		accountRou.POST("/:id/update-avatar", authorize, accountRouter.accountController.UploadImage)
+		accountRou.GET("/:id/avatar", authorize, accountRouter.accountController.GetAvatar)
+		accountRou.DELETE("/:id/avatar", authorize, accountRouter.accountController.DeleteAvatar)
+		accountRou.GET("/:id/avatar-url", authorize, accountRouter.accountController.GetAvatarUrl)
+		// Upload avatar tiếp tục được theo giao thức tus, OPTIONS để client đọc cấu hình server
+		accountRou.OPTIONS("/:id/avatar/uploads", accountRouter.accountController.TusOptions)
+		accountRou.POST("/:id/avatar/uploads", authorize, accountRouter.accountController.CreateTusUpload)
+		accountRou.HEAD("/:id/avatar/uploads/:uploadId", authorize, accountRouter.accountController.HeadTusUpload)
+		accountRou.PATCH("/:id/avatar/uploads/:uploadId", authorize, accountRouter.accountController.PatchTusUpload)
+		accountRou.DELETE("/:id/avatar/uploads/:uploadId", authorize, accountRouter.accountController.DeleteTusUpload)
+		accountRou.GET("/:id/history", authorize, accountRouter.accountController.GetHistory)
+		accountRou.POST("/:id/history/:version/revert", authorize, accountRouter.accountController.RevertHistory)
+		accountRou.GET("/export/excel", authorize, accountRouter.accountController.DownloadAccountsExcel)
+		accountRou.POST("/:id/forgot-password", authorize, accountRouter.accountController.RestorePassword)
 	}
+	// URL avatar đã ký được xác thực bằng chữ ký trong query thay vì JWT
+	router.GET("/avatars/:id", accountRouter.accountController.GetPublicAvatar)
 }
@@ -0,0 +1,24 @@
+package routers
+
+import (
+	"UserManagementVer/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountImportRouter struct {
+	accountImportController *controllers.AccountImportController
+}
+
+func NewAccountImportRouter(accountImportController *controllers.AccountImportController) *AccountImportRouter {
+	return &AccountImportRouter{accountImportController: accountImportController}
+}
+
+func (accountImportRouter *AccountImportRouter) RegisterRoutes(router *gin.RouterGroup, authorize gin.HandlerFunc) {
+	importRou := router.Group("/accounts/import")
+	{
+		importRou.POST("", authorize, accountImportRouter.accountImportController.ImportAccounts)
+		importRou.GET("/:jobId", authorize, accountImportRouter.accountImportController.GetImportJob)
+		importRou.GET("/:jobId/report", authorize, accountImportRouter.accountImportController.DownloadImportReport)
+	}
+}
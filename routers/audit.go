@@ -0,0 +1,23 @@
+package routers
+
+import (
+	"UserManagementVer/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditRouter struct {
+	auditController *controllers.AuditController
+}
+
+func NewAuditRouter(auditController *controllers.AuditController) *AuditRouter {
+	return &AuditRouter{auditController: auditController}
+}
+
+func (auditRouter *AuditRouter) RegisterRoutes(router *gin.RouterGroup, authorize gin.HandlerFunc) {
+	auditRou := router.Group("/audit-events")
+	{
+		auditRou.GET("", authorize, auditRouter.auditController.ListEvents)
+		auditRou.GET("/verify", authorize, auditRouter.auditController.VerifyChain)
+	}
+}
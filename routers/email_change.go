@@ -0,0 +1,24 @@
+package routers
+
+import (
+	"UserManagementVer/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailChangeRouter struct {
+	emailChangeController *controllers.EmailChangeController
+}
+
+func NewEmailChangeRouter(emailChangeController *controllers.EmailChangeController) *EmailChangeRouter {
+	return &EmailChangeRouter{emailChangeController: emailChangeController}
+}
+
+func (emailChangeRouter *EmailChangeRouter) RegisterRoutes(router *gin.RouterGroup, authorize gin.HandlerFunc) {
+	router.POST("/accounts/:id/change-email", authorize, emailChangeRouter.emailChangeController.RequestEmailChange)
+	emailRou := router.Group("/auth/email-change")
+	{
+		emailRou.GET("/confirm", emailChangeRouter.emailChangeController.ConfirmEmailChange)
+		emailRou.GET("/undo", emailChangeRouter.emailChangeController.UndoEmailChange)
+	}
+}
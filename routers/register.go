@@ -4,21 +4,174 @@ import (
 	"UserManagementVer/collections"
 	"UserManagementVer/configs"
 	"UserManagementVer/controllers"
+	"UserManagementVer/imaging"
+	"UserManagementVer/middlewares"
+	"UserManagementVer/models"
+	"UserManagementVer/scanner"
 	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"context"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func RegisterRouters(db *mongo.Database, v *gin.RouterGroup) {
-	accountCollection := collections.NewAccountCollection(db.Collection("accounts"))
-	sessionCollection := collections.NewSessionCollection(db.Collection("sessions"))
+	outboxCollection := collections.NewOutboxCollection(db.Collection("outbox_events"))
+	var accountCache *collections.AccountCache
+	if configs.AppConfig.Cache.Enabled {
+		accountCache = collections.NewAccountCache(configs.NewRedisClient(), time.Duration(configs.AppConfig.Cache.TtlSeconds)*time.Second, time.Duration(configs.AppConfig.Cache.NegativeTtlSeconds)*time.Second)
+	}
+	accountCollection := collections.NewAccountCollection(db.Collection("accounts"), outboxCollection, accountCache)
+	sessionCollection := collections.NewSessionCollection(db.Collection("sessions"), outboxCollection)
+	accountIndexes := collections.NewIndexManager(db.Collection("accounts"))
+	sessionIndexes := collections.NewIndexManager(db.Collection("sessions"))
+	emailChangeCollection := collections.NewEmailChangeCollection(db.Collection("email_changes"))
+	importJobCollection := collections.NewImportJobCollection(db.Collection("import_jobs"))
+	settingCollection := collections.NewSettingCollection(db.Collection("settings"))
+	purgeRecordCollection := collections.NewPurgeRecordCollection(db.Collection("purge_records"))
+	auditEventCollection := collections.NewAuditEventCollection(db.Collection("audit_events"))
+	accountHistoryCollection := collections.NewAccountHistoryCollection(db.Collection("account_histories"))
+	webhookSubscriptionCollection := collections.NewWebhookSubscriptionCollection(db.Collection("webhook_subscriptions"))
+	webhookDeliveryCollection := collections.NewWebhookDeliveryCollection(db.Collection("webhook_deliveries"))
 	emailService := services.NewEmailService(configs.AppConfig.Email.Host, configs.AppConfig.Email.User, configs.AppConfig.Email.Pass, configs.AppConfig.Email.Port)
 	jwtService := services.NewJwtService(configs.AppConfig.Jwt.SecretKey, configs.AppConfig.Jwt.Issuer)
-	accountController := controllers.NewAccountController(accountCollection, jwtService)
-	authController := controllers.NewAuthController(sessionCollection, accountCollection, emailService, jwtService)
+	checkpointInterval := time.Duration(configs.AppConfig.Audit.CheckpointIntervalMinutes) * time.Minute
+	auditService := services.NewAuditService(auditEventCollection, collections.NewAuditCheckpointCollection(db.Collection("audit_checkpoints")), services.LoadAuditSigner(configs.AppConfig.Audit.SigningKey), checkpointInterval)
+	webhookService := services.NewWebhookService(webhookSubscriptionCollection, webhookDeliveryCollection, time.Duration(configs.AppConfig.Webhook.TimeoutSeconds)*time.Second, configs.AppConfig.Webhook.MaxAttempts)
+	bus := services.NewLocalBus(0)
+	outboxRelay := services.NewOutboxRelay(outboxCollection, outboxSinks(configs.AppConfig.Outbox, webhookService, bus), configs.AppConfig.Outbox.MaxAttempts)
+	avatarStorage, err := storage.New(configs.AppConfig.Storage)
+	if err != nil {
+		log.Fatal("Không thể khởi tạo storage: ", err)
+	}
+	uploadScanner, err := newUploadScanner(configs.AppConfig.Scanner)
+	if err != nil {
+		log.Fatal("Không thể khởi tạo quét mã độc: ", err)
+	}
+	avatarConfig := configs.AppConfig.Avatar
+	avatarService := services.NewAvatarService(avatarStorage, accountCollection, uploadScanner, avatarConfig.MaxUploadBytes, imaging.Options{
+		MinDimension: avatarConfig.MinDimension,
+		MaxDimension: avatarConfig.MaxDimension,
+		MaxPixels:    avatarConfig.MaxPixels,
+		Sizes:        avatarConfig.Sizes,
+		Quality:      avatarConfig.JpegQuality,
+	})
+	// Khóa ký URL avatar tách khỏi khóa JWT để lộ một URL đã ký không ảnh hưởng tới token đăng nhập
+	var avatarUrlSigner *services.AvatarUrlSigner
+	switch avatarConfig.UrlSigningKey {
+	case "":
+		log.Println("Chưa cấu hình avatar.url_signing_key, tắt URL công khai của avatar")
+	case configs.AppConfig.Jwt.SecretKey:
+		log.Fatal("avatar.url_signing_key phải khác jwt.secret_key")
+	default:
+		avatarUrlSigner = services.NewAvatarUrlSigner(avatarConfig.UrlSigningKey, time.Duration(avatarConfig.UrlTtlSeconds)*time.Second)
+	}
+	// Phần upload tus được lưu tạm cùng storage avatar để mọi replica tiếp tục được cùng một phiên
+	tusService := services.NewTusService(avatarStorage, avatarConfig.MaxUploadBytes, time.Duration(configs.AppConfig.Tus.ExpirationHours)*time.Hour)
+	purgeService := services.NewPurgeService(accountCollection, accountIndexes, sessionCollection, emailChangeCollection, accountHistoryCollection, settingCollection, purgeRecordCollection, auditService, avatarService)
+	historyService := services.NewAccountHistoryService(accountHistoryCollection)
+	retentionService := services.NewRetentionService(purgeService, settingCollection, accountIndexes, sessionIndexes, collections.NewIndexManager(db.Collection("audit_events")))
+	accountController := controllers.NewAccountController(accountCollection, jwtService, purgeService, historyService, auditService, avatarService, avatarUrlSigner, tusService)
+	authController := controllers.NewAuthController(sessionCollection, accountCollection, emailService, jwtService, auditService)
+	emailChangeController := controllers.NewEmailChangeController(accountCollection, sessionCollection, emailChangeCollection, emailService, jwtService, historyService, auditService)
+	accountImportController := controllers.NewAccountImportController(accountCollection, importJobCollection, jwtService, historyService, auditService)
+	retentionController := controllers.NewRetentionController(retentionService, accountCollection, jwtService, auditService)
+	auditController := controllers.NewAuditController(auditService)
+	webhookController := controllers.NewWebhookController(webhookSubscriptionCollection, webhookDeliveryCollection, webhookService)
 	authRouter := NewAuthRouter(authController)
 	accountRouter := NewAccountRouter(accountController)
-	accountRouter.RegisterRoutes(v, jwtService)
+	emailChangeRouter := NewEmailChangeRouter(emailChangeController)
+	accountImportRouter := NewAccountImportRouter(accountImportController)
+	retentionRouter := NewRetentionRouter(retentionController)
+	auditRouter := NewAuditRouter(auditController)
+	webhookRouter := NewWebhookRouter(webhookController)
+	v.Use(middlewares.RequestId())
+	authorize := middlewares.AuthorizeJWT(jwtService, accountCollection)
+	accountRouter.RegisterRoutes(v, authorize)
+	emailChangeRouter.RegisterRoutes(v, authorize)
+	accountImportRouter.RegisterRoutes(v, authorize)
+	retentionRouter.RegisterRoutes(v, authorize)
+	auditRouter.RegisterRoutes(v, authorize)
+	webhookRouter.RegisterRoutes(v, authorize)
 	authRouter.Register(v)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := accountHistoryCollection.EnsureIndexes(ctx); err != nil {
+		log.Println("Không thể tạo index cho lịch sử tài khoản:", err)
+	}
+	if err := auditEventCollection.EnsureIndexes(ctx); err != nil {
+		log.Println("Không thể tạo index cho audit log:", err)
+	}
+	if err := webhookDeliveryCollection.EnsureIndexes(ctx); err != nil {
+		log.Println("Không thể tạo index cho webhook:", err)
+	}
+	if err := outboxCollection.EnsureIndexes(ctx); err != nil {
+		log.Println("Không thể tạo index cho outbox:", err)
+	}
+	if err := accountCollection.EnsureIndexes(ctx); err != nil {
+		log.Println("Không thể tạo index cho tài khoản:", err)
+	}
+	if err := sessionCollection.EnsureIndexes(ctx); err != nil {
+		log.Println("Không thể tạo index cho session:", err)
+	}
+
+	go auditService.StartWriter(context.Background())
+	go auditService.StartCheckpoints(context.Background())
+	go webhookService.Start(context.Background(), time.Duration(configs.AppConfig.Webhook.WorkerIntervalSeconds)*time.Second)
+	go outboxRelay.Start(context.Background(), time.Duration(configs.AppConfig.Outbox.PollIntervalMs)*time.Millisecond)
+	if configs.AppConfig.ChangeStream.Enabled {
+		tokenCollection := collections.NewChangeStreamTokenCollection(db.Collection("change_stream_tokens"))
+		busChangeHandler := services.NewBusChangeHandler(bus, configs.AppConfig.Outbox.BusSubjectPrefix)
+		if accountCache != nil {
+			services.SubscribeAccountCache(bus, services.ChangeSubject(configs.AppConfig.Outbox.BusSubjectPrefix, "accounts", ">"), accountCache)
+		}
+		go services.NewChangeStreamWatcher("accounts", accountCollection, tokenCollection, services.NewOutboxChangeHandler(outboxCollection, models.AggregateAccount), busChangeHandler).Start(context.Background())
+		go services.NewChangeStreamWatcher("sessions", sessionCollection, tokenCollection, services.NewOutboxChangeHandler(outboxCollection, models.AggregateSession), busChangeHandler).Start(context.Background())
+	}
+	go purgeService.Start(context.Background(), time.Duration(configs.AppConfig.Purge.IntervalMinutes)*time.Minute, configs.AppConfig.Purge.BatchSize)
+	go services.NewAvatarSweeper(avatarService, accountCollection).Start(context.Background(), time.Duration(avatarConfig.SweepIntervalMinutes)*time.Minute, time.Duration(avatarConfig.SweepGraceHours)*time.Hour)
+	go tusService.Start(context.Background(), time.Duration(configs.AppConfig.Tus.CleanupIntervalMinutes)*time.Minute)
+}
+
+// outboxSinks tạo các sink theo cấu hình, mặc định chỉ phát qua webhook
+func outboxSinks(config configs.Outbox, webhookService *services.WebhookService, bus services.MessageBus) []services.OutboxSink {
+	names := config.Sinks
+	if len(names) == 0 {
+		names = []string{"webhook"}
+	}
+	sinks := []services.OutboxSink{}
+	for _, name := range names {
+		switch name {
+		case "webhook":
+			sinks = append(sinks, services.NewWebhookSink(webhookService))
+		case "bus":
+			sinks = append(sinks, services.NewBusSink(bus, config.BusSubjectPrefix))
+		case "log":
+			sinks = append(sinks, services.LogSink{})
+		default:
+			log.Println("Outbox sink không hợp lệ:", name)
+		}
+	}
+	return sinks
+}
+
+// newUploadScanner tạo bước quét mã độc theo cấu hình, trả về nil khi không bật quét
+func newUploadScanner(config configs.Scanner) (*services.UploadScanner, error) {
+	malwareScanner, err := scanner.New(config)
+	if err != nil || malwareScanner == nil {
+		return nil, err
+	}
+	// Không cấu hình nơi cách ly thì dùng thư mục quarantine, không để lẫn vào thư mục uploads mặc định
+	if config.Quarantine.Driver == "" && config.Quarantine.LocalDir == "" {
+		config.Quarantine.LocalDir = "quarantine"
+	}
+	quarantine, err := storage.New(config.Quarantine)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewUploadScanner(malwareScanner, quarantine, config.FailOpen), nil
 }
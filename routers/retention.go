@@ -0,0 +1,26 @@
+package routers
+
+import (
+	"UserManagementVer/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionRouter struct {
+	retentionController *controllers.RetentionController
+}
+
+func NewRetentionRouter(retentionController *controllers.RetentionController) *RetentionRouter {
+	return &RetentionRouter{retentionController: retentionController}
+}
+
+func (retentionRouter *RetentionRouter) RegisterRoutes(router *gin.RouterGroup, authorize gin.HandlerFunc) {
+	retentionRou := router.Group("/retention-policies")
+	{
+		retentionRou.GET("", authorize, retentionRouter.retentionController.ListPolicies)
+		retentionRou.GET("/:target", authorize, retentionRouter.retentionController.GetPolicy)
+		retentionRou.PUT("/:target", authorize, retentionRouter.retentionController.UpdatePolicy)
+	}
+	// API cũ, giữ cho client chưa chuyển sang /retention-policies/accounts
+	router.PATCH("/accounts/time-to-live", authorize, retentionRouter.retentionController.UpdateTimeToLiveHardDelete)
+}
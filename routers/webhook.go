@@ -0,0 +1,29 @@
+package routers
+
+import (
+	"UserManagementVer/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookRouter struct {
+	webhookController *controllers.WebhookController
+}
+
+func NewWebhookRouter(webhookController *controllers.WebhookController) *WebhookRouter {
+	return &WebhookRouter{webhookController: webhookController}
+}
+
+func (webhookRouter *WebhookRouter) RegisterRoutes(router *gin.RouterGroup, authorize gin.HandlerFunc) {
+	webhookRou := router.Group("/webhooks")
+	{
+		webhookRou.POST("", authorize, webhookRouter.webhookController.CreateWebhook)
+		webhookRou.GET("", authorize, webhookRouter.webhookController.ListWebhooks)
+		webhookRou.GET("/:id", authorize, webhookRouter.webhookController.GetWebhook)
+		webhookRou.PATCH("/:id", authorize, webhookRouter.webhookController.UpdateWebhook)
+		webhookRou.DELETE("/:id", authorize, webhookRouter.webhookController.DeleteWebhook)
+		webhookRou.POST("/:id/ping", authorize, webhookRouter.webhookController.PingWebhook)
+		webhookRou.GET("/:id/deliveries", authorize, webhookRouter.webhookController.ListDeliveries)
+		webhookRou.POST("/deliveries/:deliveryId/redeliver", authorize, webhookRouter.webhookController.Redeliver)
+	}
+}
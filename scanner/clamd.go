@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// Kích thước mỗi chunk gửi lên clamd, clamd tự giới hạn tổng dung lượng bằng StreamMaxLength
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner gửi file tới clamd (ClamAV daemon) bằng lệnh INSTREAM qua TCP hoặc unix socket
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+func NewClamdScanner(network string, address string, timeout time.Duration) *ClamdScanner {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &ClamdScanner{network: network, address: address, timeout: timeout}
+}
+
+// Scan gửi "zINSTREAM", các chunk dạng <độ dài 4 byte big-endian><dữ liệu>, kết thúc bằng chunk rỗng,
+// rồi đọc một dòng trả lời: "stream: OK", "stream: <tên mẫu> FOUND" hoặc "... ERROR"
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("Không thể kết nối clamd: %w", err)
+	}
+	defer conn.Close()
+	deadline := time.Now().Add(s.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return Result{}, err
+	}
+
+	if err := s.stream(conn, r); err != nil {
+		return Result{}, err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(errors.Is(err, io.EOF) && reply != "") {
+		return Result{}, fmt.Errorf("Không đọc được kết quả từ clamd: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+func (s *ClamdScanner) stream(conn net.Conn, r io.Reader) error {
+	writer := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := writer.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	// Lỗi ghi của bufio.Writer được giữ lại và trả về ở Flush
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			writer.Write(size)
+			writer.Write(buf[:n])
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	writer.Write(size)
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("Không thể gửi file tới clamd: %w", err)
+	}
+	return nil
+}
+
+func parseClamdReply(reply string) (Result, error) {
+	switch {
+	case reply == "stream: OK":
+		return Result{}, nil
+	case strings.HasPrefix(reply, "stream: ") && strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return Result{Infected: true, Signature: signature}, nil
+	}
+	return Result{}, fmt.Errorf("clamd trả lỗi: %s", strings.TrimSpace(reply))
+}
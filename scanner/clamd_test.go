@@ -0,0 +1,116 @@
+package scanner_test
+
+import (
+	"UserManagementVer/scanner"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd nhận lệnh INSTREAM như clamd thật và trả lời theo nội dung nhận được
+func fakeClamd(t *testing.T, reply func(data []byte) string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				command, err := reader.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				data := []byte{}
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(reader, size); err != nil {
+						return
+					}
+					length := binary.BigEndian.Uint32(size)
+					if length == 0 {
+						break
+					}
+					chunk := make([]byte, length)
+					if _, err := io.ReadFull(reader, chunk); err != nil {
+						return
+					}
+					data = append(data, chunk...)
+				}
+				if answer := reply(data); answer != "" {
+					conn.Write([]byte(answer + "\x00"))
+				} else {
+					time.Sleep(time.Second)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 200<<10)
+	address := fakeClamd(t, func(data []byte) string {
+		switch {
+		case bytes.Contains(data, []byte("EICAR")):
+			return "stream: Eicar-Test-Signature FOUND"
+		case bytes.Equal(data, []byte("too-large")):
+			return "INSTREAM size limit exceeded. ERROR"
+		case bytes.Equal(data, []byte("hang")):
+			return ""
+		case len(data) == len(large) || bytes.Equal(data, []byte("clean")):
+			return "stream: OK"
+		}
+		return "stream: unexpected FOUND"
+	})
+	clamd := scanner.NewClamdScanner("tcp", address, 200*time.Millisecond)
+	ctx := context.Background()
+
+	result, err := clamd.Scan(ctx, strings.NewReader("clean"))
+	if err != nil || result.Infected {
+		t.Fatalf("file sạch: %+v %v", result, err)
+	}
+	// File lớn hơn một chunk phải được ghép lại đúng độ dài
+	if result, err := clamd.Scan(ctx, bytes.NewReader(large)); err != nil || result.Infected {
+		t.Fatalf("file nhiều chunk: %+v %v", result, err)
+	}
+	result, err = clamd.Scan(ctx, strings.NewReader(eicar))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("file EICAR: %+v %v", result, err)
+	}
+	if _, err := clamd.Scan(ctx, strings.NewReader("too-large")); err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Fatalf("clamd trả ERROR phải thành lỗi, nhận %v", err)
+	}
+	if _, err := clamd.Scan(ctx, strings.NewReader("hang")); err == nil {
+		t.Fatal("clamd không trả lời phải lỗi khi hết thời gian chờ")
+	}
+}
+
+func TestClamdScannerUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	if _, err := scanner.NewClamdScanner("tcp", address, time.Second).Scan(context.Background(), strings.NewReader("clean")); err == nil {
+		t.Fatal("không kết nối được clamd phải trả lỗi")
+	}
+}
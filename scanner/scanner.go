@@ -0,0 +1,45 @@
+// Package scanner quét mã độc trong file upload trước khi file được lưu vào storage.
+package scanner
+
+import (
+	"UserManagementVer/configs"
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Các driver quét mã độc, driver rỗng là tắt quét
+const (
+	DriverClamd = "clamd"
+)
+
+type Result struct {
+	Infected bool
+	// Signature là tên mẫu mã độc do scanner báo (vd: Eicar-Test-Signature)
+	Signature string
+}
+
+type Scanner interface {
+	// Scan đọc hết r và trả về kết quả, lỗi khi không quét được (mất kết nối, file quá lớn...)
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// New khởi tạo scanner theo cấu hình, trả về nil khi không bật quét
+func New(config configs.Scanner) (Scanner, error) {
+	switch config.Driver {
+	case "":
+		return nil, nil
+	case DriverClamd:
+		if config.Address == "" {
+			return nil, fmt.Errorf("Chưa cấu hình địa chỉ clamd")
+		}
+		network := config.Network
+		if network == "" {
+			network = "tcp"
+		}
+		return NewClamdScanner(network, config.Address, time.Duration(config.TimeoutSeconds)*time.Second), nil
+	default:
+		return nil, fmt.Errorf("Driver quét mã độc %s không được hỗ trợ", config.Driver)
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/models"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AccountHistoryService ghi lại từng phiên bản của tài khoản sau mỗi lần thay đổi
+type AccountHistoryService struct {
+	historyCollection *collections.AccountHistoryCollection
+}
+
+func NewAccountHistoryService(historyCollection *collections.AccountHistoryCollection) *AccountHistoryService {
+	return &AccountHistoryService{historyCollection: historyCollection}
+}
+
+// Record so sánh trạng thái trước/sau và lưu phiên bản mới nếu có thay đổi.
+// before = nil khi tài khoản vừa được tạo; extra dùng cho thay đổi không nằm trong snapshot (vd: mật khẩu).
+func (h *AccountHistoryService) Record(ctx context.Context, action string, before *models.Account, after models.Account, actorId primitive.ObjectID, requestId string, extra ...models.FieldChange) (models.AccountHistory, error) {
+	oldSnapshot := bson.M{}
+	if before != nil {
+		oldSnapshot = before.Snapshot()
+	}
+	newSnapshot := after.Snapshot()
+
+	changes := diffSnapshot(oldSnapshot, newSnapshot)
+	changes = append(changes, extra...)
+	if len(changes) == 0 {
+		return models.AccountHistory{}, nil
+	}
+
+	history := models.AccountHistory{
+		AccountId: after.Id,
+		Action:    action,
+		Changes:   changes,
+		Snapshot:  newSnapshot,
+		ActorId:   actorId,
+		RequestId: requestId,
+	}
+	return h.save(ctx, history)
+}
+
+// RecordRevert lưu phiên bản được tạo ra khi khôi phục về version cũ
+func (h *AccountHistoryService) RecordRevert(ctx context.Context, before models.Account, after models.Account, revertedFrom int, actorId primitive.ObjectID, requestId string) (models.AccountHistory, error) {
+	history := models.AccountHistory{
+		AccountId:    after.Id,
+		Action:       models.HistoryActionRevert,
+		Changes:      diffSnapshot(before.Snapshot(), after.Snapshot()),
+		Snapshot:     after.Snapshot(),
+		RevertedFrom: revertedFrom,
+		ActorId:      actorId,
+		RequestId:    requestId,
+	}
+	return h.save(ctx, history)
+}
+
+func (h *AccountHistoryService) List(ctx context.Context, accountId primitive.ObjectID, page int, limit int) ([]models.AccountHistory, int64, error) {
+	filter := bson.M{"account_id": accountId}
+	total, err := h.historyCollection.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "version", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	histories, err := h.historyCollection.Find(ctx, filter, opts)
+	return histories, total, err
+}
+
+// At trả về phiên bản có hiệu lực tại thời điểm at
+func (h *AccountHistoryService) At(ctx context.Context, accountId primitive.ObjectID, at time.Time) (models.AccountHistory, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	return h.historyCollection.FindOne(ctx, bson.M{
+		"account_id": accountId,
+		"created_at": bson.M{"$lte": at},
+	}, opts)
+}
+
+func (h *AccountHistoryService) GetVersion(ctx context.Context, accountId primitive.ObjectID, version int) (models.AccountHistory, error) {
+	return h.historyCollection.FindOne(ctx, bson.M{
+		"account_id": accountId,
+		"version":    version,
+	})
+}
+
+func (h *AccountHistoryService) save(ctx context.Context, history models.AccountHistory) (models.AccountHistory, error) {
+	// Service nil khi chạy không lưu lịch sử (vd: test HTTP trên repository trong bộ nhớ)
+	if h == nil {
+		return history, nil
+	}
+	// Thử lại khi bị trùng version do ghi đồng thời
+	for attempt := 0; attempt < 3; attempt++ {
+		last, err := h.historyCollection.FindOne(ctx, bson.M{"account_id": history.AccountId}, options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}))
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			return history, err
+		}
+		history.Id = primitive.NewObjectID()
+		history.Version = last.Version + 1
+		history.CreatedAt = time.Now()
+		err = h.historyCollection.Create(ctx, history)
+		if !mongo.IsDuplicateKeyError(err) {
+			return history, err
+		}
+	}
+	return history, errors.New("Không thể ghi lịch sử tài khoản do xung đột version")
+}
+
+func diffSnapshot(oldSnapshot bson.M, newSnapshot bson.M) []models.FieldChange {
+	fields := make([]string, 0, len(newSnapshot))
+	for field := range newSnapshot {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	changes := []models.FieldChange{}
+	for _, field := range fields {
+		oldValue := oldSnapshot[field]
+		newValue := newSnapshot[field]
+		if isEmptyValue(oldValue) && isEmptyValue(newValue) {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, models.FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	return changes
+}
+
+func isEmptyValue(value interface{}) bool {
+	return value == nil || value == ""
+}
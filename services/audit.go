@@ -0,0 +1,223 @@
+package services
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/models"
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// auditQueueSize là số sự kiện chờ ghi tối đa, hàng đợi đầy thì Log ghi trực tiếp
+const auditQueueSize = 1024
+
+// AuditService ghi lại các hành động liên quan đến bảo mật vào audit_events
+type AuditService struct {
+	auditCollection      *collections.AuditEventCollection
+	checkpointCollection *collections.AuditCheckpointCollection
+	signer               *AuditSigner
+	// checkpointInterval là chu kỳ tạo checkpoint, Verify dùng để phát hiện checkpoint bị xóa
+	checkpointInterval time.Duration
+	// queue chứa sự kiện chờ StartWriter nối vào chuỗi, mỗi server chỉ có một goroutine ghi
+	// nên không phải khóa; giữa các server với nhau thì dựa vào unique index của sequence
+	queue chan models.AuditEvent
+}
+
+type AuditFilter struct {
+	Action     string
+	Outcome    string
+	ActorId    primitive.ObjectID
+	TargetType string
+	TargetId   string
+	Ip         string
+	RequestId  string
+	From       time.Time
+	To         time.Time
+}
+
+// signer = nil thì không tạo checkpoint, chuỗi hash vẫn được ghi bình thường
+func NewAuditService(auditCollection *collections.AuditEventCollection, checkpointCollection *collections.AuditCheckpointCollection, signer *AuditSigner, checkpointInterval time.Duration) *AuditService {
+	if checkpointInterval <= 0 {
+		checkpointInterval = time.Hour
+	}
+	return &AuditService{
+		auditCollection:      auditCollection,
+		checkpointCollection: checkpointCollection,
+		signer:               signer,
+		checkpointInterval:   checkpointInterval,
+		queue:                make(chan models.AuditEvent, auditQueueSize),
+	}
+}
+
+// Record nối sự kiện vào cuối chuỗi hash ngay trong request hiện tại
+func (a *AuditService) Record(ctx context.Context, event models.AuditEvent) error {
+	event, err := prepareAuditEvent(event)
+	if err != nil {
+		return err
+	}
+	last, err := a.lastEvent(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = a.appendEvent(ctx, last, event)
+	return err
+}
+
+// prepareAuditEvent chuẩn hóa sự kiện về dạng sẽ đọc lại từ MongoDB để hash lúc ghi và lúc kiểm tra giống nhau
+func prepareAuditEvent(event models.AuditEvent) (models.AuditEvent, error) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC().Truncate(time.Millisecond)
+	metadata, err := normalizeMetadata(event.Metadata)
+	if err != nil {
+		return event, err
+	}
+	event.Metadata = metadata
+	return event, nil
+}
+
+func (a *AuditService) lastEvent(ctx context.Context) (models.AuditEvent, error) {
+	last, err := a.auditCollection.Last(ctx)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.AuditEvent{}, nil
+	}
+	return last, err
+}
+
+// appendEvent nối event vào sau last. Server khác đã nối vào vị trí đó (trùng sequence)
+// thì đọc lại sự kiện cuối và thử lại, trả về sự kiện đã ghi để làm last cho lần sau.
+func (a *AuditService) appendEvent(ctx context.Context, last models.AuditEvent, event models.AuditEvent) (models.AuditEvent, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		event.Id = primitive.NewObjectID()
+		event.Sequence = last.Sequence + 1
+		event.PrevHash = last.Hash
+		hash, err := HashAuditEvent(event)
+		if err != nil {
+			return models.AuditEvent{}, err
+		}
+		event.Hash = hash
+		err = a.auditCollection.Create(ctx, event)
+		if err == nil {
+			return event, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return models.AuditEvent{}, err
+		}
+		if last, err = a.lastEvent(ctx); err != nil {
+			return models.AuditEvent{}, err
+		}
+	}
+	return models.AuditEvent{}, errors.New("Không thể ghi audit log do xung đột sequence")
+}
+
+// Log đưa sự kiện vào hàng đợi để không làm chậm thao tác chính (vd: đăng nhập),
+// lỗi khi ghi chỉ được log. Hàng đợi đầy thì ghi trực tiếp để không mất sự kiện.
+func (a *AuditService) Log(event models.AuditEvent) {
+	// Service nil khi chạy không có audit log (vd: test HTTP trên repository trong bộ nhớ)
+	if a == nil {
+		return
+	}
+	event, err := prepareAuditEvent(event)
+	if err != nil {
+		log.Println("Không thể ghi audit log", event.Action, err)
+		return
+	}
+	select {
+	case a.queue <- event:
+		return
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := a.Record(ctx, event); err != nil {
+		log.Println("Không thể ghi audit log", event.Action, err)
+	}
+}
+
+// StartWriter nối các sự kiện trong hàng đợi vào chuỗi cho tới khi ctx bị hủy.
+// Sự kiện cuối được giữ trong bộ nhớ nên mỗi sự kiện chỉ tốn một lần ghi khi không có xung đột.
+func (a *AuditService) StartWriter(ctx context.Context) {
+	var last *models.AuditEvent
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-a.queue:
+			writeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if last == nil {
+				loaded, err := a.lastEvent(writeCtx)
+				if err != nil {
+					log.Println("Không thể ghi audit log", event.Action, err)
+					cancel()
+					continue
+				}
+				last = &loaded
+			}
+			written, err := a.appendEvent(writeCtx, *last, event)
+			cancel()
+			if err != nil {
+				log.Println("Không thể ghi audit log", event.Action, err)
+				last = nil
+				continue
+			}
+			last = &written
+		}
+	}
+}
+
+func (a *AuditService) List(ctx context.Context, filter AuditFilter, page int, limit int) ([]models.AuditEvent, int64, error) {
+	query := filter.toQuery()
+	total, err := a.auditCollection.Count(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	events, err := a.auditCollection.Find(ctx, query, opts)
+	return events, total, err
+}
+
+func (filter AuditFilter) toQuery() bson.M {
+	query := bson.M{}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.Outcome != "" {
+		query["outcome"] = filter.Outcome
+	}
+	if !filter.ActorId.IsZero() {
+		query["actor.id"] = filter.ActorId
+	}
+	if filter.TargetType != "" {
+		query["target.type"] = filter.TargetType
+	}
+	if filter.TargetId != "" {
+		query["target.id"] = filter.TargetId
+	}
+	if filter.Ip != "" {
+		query["ip"] = filter.Ip
+	}
+	if filter.RequestId != "" {
+		query["request_id"] = filter.RequestId
+	}
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lte"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	return query
+}
@@ -0,0 +1,397 @@
+package services
+
+import (
+	"UserManagementVer/models"
+	"cmp"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditSigner ký checkpoint bằng Ed25519, auditor chỉ cần public key để kiểm tra
+type AuditSigner struct {
+	privateKey ed25519.PrivateKey
+	KeyId      string
+}
+
+type AuditBrokenLink struct {
+	Sequence int64              `json:"sequence"`
+	EventId  primitive.ObjectID `json:"event_id,omitempty"`
+	Reason   string             `json:"reason"`
+	// LastTrustedSequence là sự kiện cuối cùng còn được checkpoint đã ký xác nhận
+	LastTrustedSequence int64 `json:"last_trusted_sequence"`
+}
+
+type AuditVerifyResult struct {
+	Valid                 bool             `json:"valid"`
+	Checked               int64            `json:"checked"`
+	FirstSequence         int64            `json:"first_sequence"`
+	LastSequence          int64            `json:"last_sequence"`
+	LastHash              string           `json:"last_hash,omitempty"`
+	Truncated             bool             `json:"truncated"`
+	Unchained             int64            `json:"unchained"`
+	CheckpointsChecked    int              `json:"checkpoints_checked"`
+	CheckpointsUnverified int              `json:"checkpoints_unverified"`
+	BrokenLink            *AuditBrokenLink `json:"broken_link,omitempty"`
+	VerifiedAt            time.Time        `json:"verified_at"`
+}
+
+// auditHashPayload cố định thứ tự các trường được hash, metadata được encoding/json sắp xếp theo key
+type auditHashPayload struct {
+	Id        string             `json:"id"`
+	Sequence  int64              `json:"sequence"`
+	PrevHash  string             `json:"prev_hash"`
+	Action    string             `json:"action"`
+	Outcome   string             `json:"outcome"`
+	Actor     models.AuditActor  `json:"actor"`
+	Target    models.AuditTarget `json:"target"`
+	Ip        string             `json:"ip"`
+	UserAgent string             `json:"user_agent"`
+	RequestId string             `json:"request_id"`
+	Reason    string             `json:"reason"`
+	Metadata  bson.M             `json:"metadata"`
+	CreatedAt string             `json:"created_at"`
+}
+
+// NewAuditSigner tạo signer từ seed Ed25519 32 byte được mã hóa base64
+func NewAuditSigner(seed string) (*AuditSigner, error) {
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil {
+		return nil, fmt.Errorf("Khóa ký audit không phải base64: %w", err)
+	}
+	if len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("Khóa ký audit phải dài %d byte", ed25519.SeedSize)
+	}
+	privateKey := ed25519.NewKeyFromSeed(raw)
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	keyHash := sha256.Sum256(publicKey)
+	return &AuditSigner{privateKey: privateKey, KeyId: hex.EncodeToString(keyHash[:8])}, nil
+}
+
+// LoadAuditSigner đọc khóa từ cấu hình, khóa trống hoặc sai định dạng thì trả về nil
+// và audit log chỉ còn chuỗi hash, không có checkpoint được ký.
+func LoadAuditSigner(seed string) *AuditSigner {
+	if seed == "" {
+		return nil
+	}
+	signer, err := NewAuditSigner(seed)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	return signer
+}
+
+func (s *AuditSigner) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
+
+func (s *AuditSigner) Sign(checkpoint models.AuditCheckpoint) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, checkpointMessage(checkpoint)))
+}
+
+func (s *AuditSigner) Verify(checkpoint models.AuditCheckpoint) bool {
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(s.privateKey.Public().(ed25519.PublicKey), checkpointMessage(checkpoint), signature)
+}
+
+func checkpointMessage(checkpoint models.AuditCheckpoint) []byte {
+	return []byte(fmt.Sprintf("%d:%s:%s", checkpoint.Sequence, checkpoint.Hash, checkpoint.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// HashAuditEvent tính SHA-256 của sự kiện, bao gồm PrevHash để nối với sự kiện trước
+func HashAuditEvent(event models.AuditEvent) (string, error) {
+	payload, err := json.Marshal(auditHashPayload{
+		Id:        event.Id.Hex(),
+		Sequence:  event.Sequence,
+		PrevHash:  event.PrevHash,
+		Action:    event.Action,
+		Outcome:   event.Outcome,
+		Actor:     event.Actor,
+		Target:    event.Target,
+		Ip:        event.Ip,
+		UserAgent: event.UserAgent,
+		RequestId: event.RequestId,
+		Reason:    event.Reason,
+		Metadata:  event.Metadata,
+		CreatedAt: event.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// normalizeMetadata encode rồi decode lại metadata qua BSON để kiểu dữ liệu
+// giống hệt khi đọc từ MongoDB (int -> int32, []ObjectID -> primitive.A, time -> DateTime...)
+func normalizeMetadata(metadata bson.M) (bson.M, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+	raw, err := bson.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	normalized := bson.M{}
+	if err := bson.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// AuditTTLIndexName là TTL index trên created_at của chính sách lưu trữ audit_events
+const AuditTTLIndexName = "created_at_ttl"
+
+// Checkpoint ký hash của sự kiện cuối cùng nếu có sự kiện mới kể từ checkpoint trước.
+// Checkpoint mới được ghi cả ra log dạng JSON để còn một bản nằm ngoài DB,
+// auditor truyền lại cho audit-verify qua -checkpoints.
+func (a *AuditService) Checkpoint(ctx context.Context) (models.AuditCheckpoint, error) {
+	if a.signer == nil {
+		return models.AuditCheckpoint{}, errors.New("Chưa cấu hình khóa ký audit")
+	}
+	last, err := a.auditCollection.Last(ctx)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return models.AuditCheckpoint{}, nil
+	}
+	if err != nil {
+		return models.AuditCheckpoint{}, err
+	}
+	previous, err := a.checkpointCollection.Last(ctx)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return models.AuditCheckpoint{}, err
+	}
+	if previous.Sequence >= last.Sequence {
+		return previous, nil
+	}
+
+	checkpoint := models.AuditCheckpoint{
+		Id:        primitive.NewObjectID(),
+		Sequence:  last.Sequence,
+		Hash:      last.Hash,
+		KeyId:     a.signer.KeyId,
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	checkpoint.Signature = a.signer.Sign(checkpoint)
+	if err := a.checkpointCollection.Create(ctx, checkpoint); err != nil {
+		return checkpoint, err
+	}
+	if exported, err := json.Marshal(checkpoint); err == nil {
+		log.Println("audit checkpoint", string(exported))
+	}
+	return checkpoint, nil
+}
+
+// StartCheckpoints ký checkpoint theo checkpointInterval cho tới khi ctx bị hủy
+func (a *AuditService) StartCheckpoints(ctx context.Context) {
+	if a.signer == nil {
+		log.Println("Chưa cấu hình khóa ký audit, bỏ qua việc tạo checkpoint")
+		return
+	}
+	ticker := time.NewTicker(a.checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			if _, err := a.Checkpoint(runCtx); err != nil {
+				log.Println("Không thể tạo checkpoint audit", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// AuditVerifyOptions bổ sung dữ liệu nằm ngoài DB cho Verify
+type AuditVerifyOptions struct {
+	// Checkpoints là checkpoint đã được xuất ra ngoài DB (log của Checkpoint hoặc file của auditor),
+	// dùng cùng checkpoint trong DB để người sửa được DB cũng không xóa được dấu vết.
+	// Checkpoint trùng sequence với DB thì bản bên ngoài được dùng.
+	Checkpoints []models.AuditCheckpoint
+}
+
+// Verify duyệt toàn bộ chuỗi hash theo sequence và dừng ở mắt xích hỏng đầu tiên.
+//
+// Phần đầu chuỗi chỉ được phép thiếu khi audit_events có TTL index của chính sách lưu trữ
+// và các sự kiện bị thiếu đã quá hạn lưu trữ (suy ra từ thời điểm của checkpoint trước đó),
+// khi đó sự kiện còn lại đầu tiên được coi là điểm bắt đầu và Truncated = true.
+//
+// Checkpoint được tạo theo checkpointInterval mỗi khi có sự kiện mới, vì vậy sự kiện nào
+// quá 2*checkpointInterval mà chưa có checkpoint bao phủ nghĩa là checkpoint đã bị xóa.
+// Server dừng ngay sau khi ghi sự kiện cũng tạo ra khoảng trống này, auditor cần đối chiếu
+// với lịch sử vận hành trước khi kết luận.
+func (a *AuditService) Verify(ctx context.Context, opts AuditVerifyOptions) (AuditVerifyResult, error) {
+	result := AuditVerifyResult{Valid: true, VerifiedAt: time.Now()}
+	maxDelay := 2 * a.checkpointInterval
+
+	unchained, err := a.auditCollection.Count(ctx, bson.M{"sequence": bson.M{"$exists": false}})
+	if err != nil {
+		return result, err
+	}
+	result.Unchained = unchained
+
+	var retention time.Duration
+	index, err := a.auditCollection.Indexes().FindIndex(ctx, AuditTTLIndexName)
+	if err != nil {
+		return result, err
+	}
+	if index != nil && index.ExpireAfterSeconds != nil {
+		retention = time.Duration(*index.ExpireAfterSeconds) * time.Second
+	}
+
+	stored, err := a.checkpointCollection.FindAll(ctx, bson.M{})
+	if err != nil {
+		return result, err
+	}
+	checkpointAt := map[int64]models.AuditCheckpoint{}
+	for _, checkpoint := range append(stored, opts.Checkpoints...) {
+		checkpointAt[checkpoint.Sequence] = checkpoint
+	}
+	checkpoints := slices.SortedFunc(maps.Values(checkpointAt), func(a, b models.AuditCheckpoint) int {
+		return cmp.Compare(a.Sequence, b.Sequence)
+	})
+
+	cursor, err := a.auditCollection.Cursor(ctx, bson.M{"sequence": bson.M{"$gt": 0}}, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	defer cursor.Close(ctx)
+
+	var lastTrusted int64
+	var prev *models.AuditEvent
+	// uncovered là sự kiện đầu tiên chưa có checkpoint bao phủ
+	var uncovered *models.AuditEvent
+	broken := func(event models.AuditEvent, reason string) {
+		result.Valid = false
+		result.BrokenLink = &AuditBrokenLink{Sequence: event.Sequence, EventId: event.Id, Reason: reason, LastTrustedSequence: lastTrusted}
+	}
+	for cursor.Next(ctx) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			return result, err
+		}
+		if prev == nil {
+			result.FirstSequence = event.Sequence
+			if event.Sequence != 1 {
+				result.Truncated = true
+				if reason := truncationError(event, checkpoints, retention, maxDelay, result.VerifiedAt); reason != "" {
+					broken(event, reason)
+					return result, nil
+				}
+			} else if event.PrevHash != "" {
+				broken(event, "Sự kiện đầu tiên của chuỗi không được có prev_hash")
+				return result, nil
+			}
+		} else {
+			if event.Sequence != prev.Sequence+1 {
+				broken(event, fmt.Sprintf("Thiếu sự kiện từ sequence %d đến %d", prev.Sequence+1, event.Sequence-1))
+				return result, nil
+			}
+			if event.PrevHash != prev.Hash {
+				broken(event, "prev_hash không khớp với hash của sự kiện trước")
+				return result, nil
+			}
+		}
+		hash, err := HashAuditEvent(event)
+		if err != nil {
+			return result, err
+		}
+		if hash != event.Hash {
+			broken(event, "Nội dung sự kiện không khớp với hash đã lưu")
+			return result, nil
+		}
+		if uncovered == nil {
+			first := event
+			uncovered = &first
+		}
+		if checkpoint, ok := checkpointAt[event.Sequence]; ok {
+			switch {
+			case a.signer == nil || checkpoint.KeyId != a.signer.KeyId:
+				result.CheckpointsUnverified++
+			case !a.signer.Verify(checkpoint):
+				broken(event, "Chữ ký của checkpoint không hợp lệ")
+				return result, nil
+			case checkpoint.Hash != event.Hash:
+				broken(event, fmt.Sprintf("Hash khác với checkpoint đã ký, chuỗi đã bị ghi lại sau sequence %d", lastTrusted))
+				return result, nil
+			default:
+				result.CheckpointsChecked++
+				lastTrusted = event.Sequence
+			}
+			if checkpoint.CreatedAt.Sub(uncovered.CreatedAt) > maxDelay {
+				broken(*uncovered, fmt.Sprintf("Sự kiện không có checkpoint bao phủ cho tới %s, checkpoint ở giữa đã bị xóa", checkpoint.CreatedAt.Format(time.RFC3339)))
+				return result, nil
+			}
+			uncovered = nil
+		}
+		result.Checked++
+		prev = &event
+	}
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	if prev != nil {
+		result.LastSequence = prev.Sequence
+		result.LastHash = prev.Hash
+	}
+	if len(checkpoints) == 0 {
+		return result, nil
+	}
+	// Checkpoint trỏ tới sự kiện sau sự kiện cuối cùng nghĩa là phần cuối chuỗi đã bị xóa
+	latest := checkpoints[len(checkpoints)-1]
+	if latest.Sequence > result.LastSequence {
+		result.Valid = false
+		result.BrokenLink = &AuditBrokenLink{
+			Sequence:            result.LastSequence + 1,
+			Reason:              fmt.Sprintf("Checkpoint đã ký tới sequence %d nhưng chuỗi chỉ còn tới %d", latest.Sequence, result.LastSequence),
+			LastTrustedSequence: lastTrusted,
+		}
+		return result, nil
+	}
+	// Các checkpoint cuối cùng bị xóa để ghi lại phần đuôi của chuỗi
+	if uncovered != nil && result.VerifiedAt.Sub(uncovered.CreatedAt) > maxDelay {
+		broken(*uncovered, "Sự kiện đã quá hạn tạo checkpoint nhưng chưa có checkpoint bao phủ")
+	}
+	return result, nil
+}
+
+// truncationError kiểm tra phần đầu bị thiếu của chuỗi (trước first) có phải do TTL của chính sách
+// lưu trữ xóa hay không, trả về lý do nếu không phải. Checkpoint cuối cùng trước first được tạo
+// không quá maxDelay sau sự kiện mà nó bao phủ, nếu sự kiện đó chưa quá hạn lưu trữ thì đã bị xóa trái phép.
+func truncationError(first models.AuditEvent, checkpoints []models.AuditCheckpoint, retention time.Duration, maxDelay time.Duration, now time.Time) string {
+	if retention <= 0 {
+		return fmt.Sprintf("Thiếu các sự kiện trước sequence %d nhưng audit_events không có chính sách lưu trữ", first.Sequence)
+	}
+	cutoff := now.Add(-retention)
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		checkpoint := checkpoints[i]
+		if checkpoint.Sequence >= first.Sequence {
+			continue
+		}
+		if checkpoint.CreatedAt.Add(-maxDelay).After(cutoff) {
+			return fmt.Sprintf("Sự kiện tới sequence %d được checkpoint lúc %s chưa hết hạn lưu trữ nhưng đã bị xóa", checkpoint.Sequence, checkpoint.CreatedAt.Format(time.RFC3339))
+		}
+		break
+	}
+	return ""
+}
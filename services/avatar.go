@@ -0,0 +1,424 @@
+package services
+
+import (
+	"UserManagementVer/imaging"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidAvatarSize   = errors.New("Kích thước ảnh không hợp lệ")
+	ErrInvalidAvatarFormat = errors.New("Định dạng ảnh không hợp lệ")
+)
+
+// AvatarService xử lý ảnh upload thành các biến thể cố định và quản lý file trên storage.
+// image_url của tài khoản lưu key gốc, biến thể nằm tại <key gốc>/<size>.jpg;
+// avatar cũ (trước khi có xử lý ảnh) là một file duy nhất và được trả nguyên như cũ.
+//
+// Key gốc được tính từ hash nội dung file upload nên ảnh giống nhau chỉ lưu một lần. Số tham chiếu
+// của một avatar là số tài khoản có image_url trỏ tới nó (image_url có index), file chỉ bị xóa
+// khi không còn tài khoản nào khác tham chiếu.
+//
+// Giữa lúc Upload trả key và lúc image_url được cập nhật, avatar chưa được đếm là có tham chiếu. Mỗi lần
+// Upload ghi một file lease (<key gốc>/lease-<hạn>-<id>) có hạn tới hết deadline của ctx upload để
+// Release và job dọn avatar không xóa avatar trong khoảng đó. Người gọi phải gọi Confirm sau khi cập nhật image_url (lưu lại file nếu avatar vẫn bị xóa
+// trước khi lease được ghi) hoặc Discard nếu không dùng kết quả upload.
+type AvatarService struct {
+	storage           storage.Storage
+	accountCollection repositories.AccountRepository
+	scanner           *UploadScanner
+	maxBytes          int64
+	options           imaging.Options
+}
+
+// UploadResult là kết quả Upload, Deduplicated = true khi nội dung đã có sẵn và không lưu thêm file nào
+type UploadResult struct {
+	Key          string
+	Deduplicated bool
+	// data là nội dung đã upload, Confirm dùng để lưu lại avatar bị xóa
+	data  []byte
+	lease string
+}
+
+// avatarLeaseTTL là thời gian lease còn hiệu lực sau deadline của ctx upload (sau lúc ghi nếu ctx
+// không có deadline), đủ để người gọi cập nhật image_url và bù lệch giờ giữa các replica
+const avatarLeaseTTL = time.Minute
+
+// uploadScanner = nil thì không quét mã độc
+func NewAvatarService(avatarStorage storage.Storage, accountCollection repositories.AccountRepository, uploadScanner *UploadScanner, maxBytes int64, options imaging.Options) *AvatarService {
+	return &AvatarService{
+		storage:           avatarStorage,
+		accountCollection: accountCollection,
+		scanner:           uploadScanner,
+		maxBytes:          maxBytes,
+		options:           options,
+	}
+}
+
+// Upload đọc tối đa maxBytes từ r, xử lý ảnh và lưu mọi biến thể, trả về key gốc để lưu vào image_url.
+// File được quét mã độc trước khi lưu; nếu avatar cùng nội dung đã có đủ biến thể thì dùng lại
+// mà không xử lý ảnh. Lỗi do ảnh không đạt yêu cầu bọc imaging.ErrInvalidImage, lỗi quét mã độc
+// xem UploadScanner.Check.
+func (a *AvatarService) Upload(ctx context.Context, r io.Reader) (UploadResult, error) {
+	data, err := io.ReadAll(io.LimitReader(r, a.maxBytes+1))
+	if err != nil {
+		return UploadResult{}, err
+	}
+	if int64(len(data)) > a.maxBytes {
+		return UploadResult{}, fmt.Errorf("%w: file không được quá %d MB", imaging.ErrInvalidImage, a.maxBytes>>20)
+	}
+	if err := a.scanner.Check(ctx, data); err != nil {
+		return UploadResult{}, err
+	}
+	digest := sha256.Sum256(data)
+	key := storage.AvatarKey(hex.EncodeToString(digest[:]))
+	// Ghi lease trước khi kiểm tra biến thể để tài khoản khác không xóa avatar đang được dùng lại
+	lease := newLeaseKey(ctx, key, time.Now())
+	if err := a.storage.Put(ctx, lease, bytes.NewReader(nil), 0, "application/octet-stream"); err != nil {
+		return UploadResult{}, err
+	}
+
+	existing, err := a.existingVariants(ctx, key)
+	if err == nil && len(a.options.Sizes) > 0 && len(existing) == len(a.options.Sizes) {
+		return UploadResult{Key: key, Deduplicated: true, data: data, lease: lease}, nil
+	}
+	if err == nil {
+		err = a.store(ctx, key, data, existing)
+	}
+	if err != nil {
+		a.deleteObject(ctx, lease)
+		return UploadResult{}, err
+	}
+	return UploadResult{Key: key, data: data, lease: lease}, nil
+}
+
+// Confirm được gọi sau khi image_url đã trỏ tới result.Key: nếu Release hoặc job dọn avatar đã xóa
+// avatar trước khi lease được ghi thì xử lý lại ảnh đã upload và lưu đủ biến thể.
+func (a *AvatarService) Confirm(ctx context.Context, result UploadResult) error {
+	existing, err := a.existingVariants(ctx, result.Key)
+	if err != nil {
+		return err
+	}
+	if len(existing) != len(a.options.Sizes) {
+		log.Println("Avatar bị xóa trong lúc upload, lưu lại", result.Key)
+		if err := a.store(ctx, result.Key, result.data, existing); err != nil {
+			return err
+		}
+	}
+	// image_url đã được đếm là tham chiếu nên không cần giữ lease
+	a.deleteObject(ctx, result.lease)
+	return nil
+}
+
+// Discard bỏ kết quả Upload khi không cập nhật được image_url của accountId,
+// file chỉ bị xóa nếu không còn tài khoản hay upload nào khác dùng avatar.
+func (a *AvatarService) Discard(ctx context.Context, accountId primitive.ObjectID, result UploadResult) (bool, error) {
+	a.deleteObject(ctx, result.lease)
+	return a.Release(ctx, accountId, result.Key)
+}
+
+// store xử lý ảnh và ghi đè đủ các biến thể dưới key, existing là biến thể đã có từ trước
+func (a *AvatarService) store(ctx context.Context, key string, data []byte, existing map[int]bool) error {
+	// Thiếu biến thể (vd: vừa thêm kích thước mới) thì xử lý lại và ghi đè đủ các biến thể
+	variants, err := imaging.Process(data, a.options)
+	if err != nil {
+		return err
+	}
+	for i, variant := range variants {
+		err := a.storage.Put(ctx, variantKey(key, variant.Size), bytes.NewReader(variant.Data), int64(len(variant.Data)), imaging.ContentType)
+		if err != nil {
+			// Xóa các biến thể vừa lưu để không để lại avatar dở dang, biến thể có từ trước có thể đang được dùng
+			for _, saved := range variants[:i] {
+				if !existing[saved.Size] {
+					a.deleteObject(ctx, variantKey(key, saved.Size))
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Open trả về avatar theo size (0 là biến thể lớn nhất). Nếu storage hỗ trợ URL tạm thời thì
+// chỉ trả về URL để client tải trực tiếp, ngược lại trả về nội dung và người gọi phải Close Body.
+func (a *AvatarService) Open(ctx context.Context, imageUrl string, size int) (storage.Object, string, error) {
+	key, err := a.objectKey(imageUrl, size)
+	if err != nil {
+		return storage.Object{}, "", err
+	}
+	url, err := a.storage.URL(ctx, key)
+	if err != nil || url != "" {
+		return storage.Object{}, url, err
+	}
+	object, err := a.storage.Get(ctx, key)
+	return object, "", err
+}
+
+// ETag trả về ETag của biến thể size. Key của avatar không bao giờ bị ghi đè bằng nội dung khác
+// (key mới đặt theo hash nội dung) nên ETag được suy ra từ key mà không cần đọc file.
+func (a *AvatarService) ETag(imageUrl string, size int) (string, error) {
+	key, err := a.objectKey(imageUrl, size)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(key))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// Exists cho biết image_url còn đủ file trên storage hay không. Avatar cũ có thể đã bị xóa khi tài khoản
+// đổi ảnh nên phải kiểm tra trước khi gán lại image_url cũ (vd: khôi phục phiên bản).
+func (a *AvatarService) Exists(ctx context.Context, imageUrl string) (bool, error) {
+	key := storage.KeyFromImageUrl(imageUrl)
+	if storage.ValidateKey(key) != nil {
+		return false, nil
+	}
+	if isLegacyAvatar(key) {
+		object, err := a.storage.Get(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		object.Body.Close()
+		return true, nil
+	}
+	existing, err := a.existingVariants(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return len(a.options.Sizes) > 0 && len(existing) == len(a.options.Sizes), nil
+}
+
+// Placeholder tạo ảnh đại diện mặc định từ chữ viết tắt của name, màu nền cố định theo accountId.
+// size theo quy tắc như Open, format rỗng là PNG; trả về nội dung và content type.
+func (a *AvatarService) Placeholder(accountId primitive.ObjectID, name string, size int, format string) ([]byte, string, error) {
+	if err := a.CheckVariant(size, format); err != nil {
+		return nil, "", err
+	}
+	if size == 0 {
+		size = slices.Max(a.options.Sizes)
+	}
+	if format == "" {
+		format = imaging.PlaceholderPNG
+	}
+	return imaging.Placeholder(imaging.Initials(name), imaging.PlaceholderColor(accountId[:]), size, format)
+}
+
+// CheckVariant kiểm tra size và format người dùng yêu cầu, format chỉ áp dụng cho ảnh mặc định
+func (a *AvatarService) CheckVariant(size int, format string) error {
+	if size != 0 && !slices.Contains(a.options.Sizes, size) || size == 0 && len(a.options.Sizes) == 0 {
+		return ErrInvalidAvatarSize
+	}
+	if format != "" && format != imaging.PlaceholderPNG && format != imaging.PlaceholderSVG {
+		return ErrInvalidAvatarFormat
+	}
+	return nil
+}
+
+// AvatarVersion định danh nội dung avatar hiện tại của tài khoản để làm URL có phiên bản.
+// Key gốc theo hash nội dung nên image_url đổi khi ảnh đổi; ảnh mặc định đổi theo tên.
+func AvatarVersion(account models.Account) string {
+	source := "image:" + account.ImageUrl
+	if account.ImageUrl == "" {
+		source = "placeholder:" + account.Name
+	}
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Release bỏ tham chiếu của accountId tới avatar và xóa mọi biến thể nếu không còn tài khoản nào khác
+// (kể cả trong thùng rác) dùng nó. Avatar vừa được Upload trả về (lease còn hạn) không bị xóa.
+// Trả về true nếu có file bị xóa.
+func (a *AvatarService) Release(ctx context.Context, accountId primitive.ObjectID, imageUrl string) (bool, error) {
+	keys := a.objectKeys(imageUrl)
+	if len(keys) == 0 {
+		return false, nil
+	}
+	references, err := a.accountCollection.Count(ctx, repositories.AccountQuery{
+		ImageUrls: imageUrlsOf(storage.KeyFromImageUrl(imageUrl)),
+		ExcludeId: accountId,
+	})
+	if err != nil || references > 0 {
+		return false, err
+	}
+	if key := storage.KeyFromImageUrl(imageUrl); !isLegacyAvatar(key) {
+		leased, expired, err := a.leases(ctx, key)
+		if err != nil || leased {
+			return false, err
+		}
+		// Lease hết hạn (upload bị bỏ dở) được xóa sau cùng cùng với các biến thể
+		keys = append(keys, expired...)
+	}
+	removed := false
+	for _, key := range keys {
+		err := a.storage.Delete(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// Sizes trả về kích thước các biến thể được tạo khi upload
+func (a *AvatarService) Sizes() []int {
+	return slices.Clone(a.options.Sizes)
+}
+
+func (a *AvatarService) objectKey(imageUrl string, size int) (string, error) {
+	key := storage.KeyFromImageUrl(imageUrl)
+	if storage.ValidateKey(key) != nil {
+		return "", storage.ErrNotFound
+	}
+	if size != 0 && !slices.Contains(a.options.Sizes, size) {
+		return "", ErrInvalidAvatarSize
+	}
+	if isLegacyAvatar(key) {
+		return key, nil
+	}
+	if size == 0 {
+		if len(a.options.Sizes) == 0 {
+			return "", storage.ErrNotFound
+		}
+		size = slices.Max(a.options.Sizes)
+	}
+	return variantKey(key, size), nil
+}
+
+func (a *AvatarService) objectKeys(imageUrl string) []string {
+	key := storage.KeyFromImageUrl(imageUrl)
+	if storage.ValidateKey(key) != nil {
+		return nil
+	}
+	if isLegacyAvatar(key) {
+		return []string{key}
+	}
+	keys := make([]string, 0, len(a.options.Sizes))
+	for _, size := range a.options.Sizes {
+		keys = append(keys, variantKey(key, size))
+	}
+	return keys
+}
+
+// existingVariants trả về các kích thước trong options.Sizes đã có file dưới key gốc
+func (a *AvatarService) existingVariants(ctx context.Context, key string) (map[int]bool, error) {
+	existing := map[int]bool{}
+	err := a.storage.List(ctx, key+"/", func(object storage.ObjectInfo) error {
+		for _, size := range a.options.Sizes {
+			if object.Key == variantKey(key, size) {
+				existing[size] = true
+			}
+		}
+		return nil
+	})
+	return existing, err
+}
+
+// leases cho biết avatar có lease còn hạn không, kèm các lease đã hết hạn
+func (a *AvatarService) leases(ctx context.Context, key string) (bool, []string, error) {
+	leased := false
+	expired := []string{}
+	now := time.Now()
+	err := a.storage.List(ctx, key+"/", func(object storage.ObjectInfo) error {
+		expires, ok := leaseExpiry(key, object)
+		if !ok {
+			return nil
+		}
+		if now.Before(expires) {
+			leased = true
+		} else {
+			expired = append(expired, object.Key)
+		}
+		return nil
+	})
+	return leased, expired, err
+}
+
+func (a *AvatarService) deleteObject(ctx context.Context, key string) {
+	if err := a.storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		log.Println("Không thể xóa avatar", key, err)
+	}
+}
+
+// isLegacyAvatar nhận biết avatar cũ lưu nguyên file gốc: key trỏ thẳng tới file có đuôi ảnh
+func isLegacyAvatar(key string) bool {
+	return path.Ext(key) != ""
+}
+
+// avatarOf trả về key gốc của avatar chứa file key, false nếu file không thuộc avatar nào
+func avatarOf(key string) (string, bool) {
+	parts := strings.Split(key, "/")
+	switch {
+	case len(parts) == 1 && isLegacyAvatar(key):
+		// File cũ nằm ngay trong thư mục uploads
+		return key, true
+	case len(parts) == 3 && parts[0] == "avatars" && isLegacyAvatar(key):
+		// Avatar lưu nguyên file gốc trước khi có xử lý ảnh
+		return key, true
+	case len(parts) == 4 && parts[0] == "avatars":
+		return path.Dir(key), true
+	}
+	return "", false
+}
+
+// imageUrlsOf trả về các giá trị image_url có thể trỏ tới avatar, file cũ được lưu kèm thư mục uploads
+func imageUrlsOf(avatar string) []string {
+	if strings.Contains(avatar, "/") {
+		return []string{avatar}
+	}
+	return []string{avatar, storage.LegacyUploadDir + "/" + avatar, storage.LegacyUploadDir + "\\" + avatar}
+}
+
+func leaseKey(key string, id string) string {
+	return key + "/lease-" + id
+}
+
+// newLeaseKey trả về key lease của avatar key, hạn của lease (unix) nằm trong tên file
+// để không phụ thuộc thời gian xử lý ảnh hay ModTime của storage
+func newLeaseKey(ctx context.Context, key string, now time.Time) string {
+	expires := now
+	if deadline, ok := ctx.Deadline(); ok && deadline.After(now) {
+		expires = deadline
+	}
+	expires = expires.Add(avatarLeaseTTL)
+	return leaseKey(key, strconv.FormatInt(expires.Unix(), 10)+"-"+primitive.NewObjectID().Hex())
+}
+
+// leaseExpiry trả về hạn của object nếu đó là lease của avatar key. Lease tạo trước khi có hạn
+// trong tên (lease-<id>) hết hạn sau avatarLeaseTTL kể từ lúc ghi.
+func leaseExpiry(key string, object storage.ObjectInfo) (time.Time, bool) {
+	name, ok := strings.CutPrefix(object.Key, leaseKey(key, ""))
+	if !ok {
+		return time.Time{}, false
+	}
+	if unix, _, found := strings.Cut(name, "-"); found {
+		if seconds, err := strconv.ParseInt(unix, 10, 64); err == nil {
+			return time.Unix(seconds, 0), true
+		}
+	}
+	return object.ModTime.Add(avatarLeaseTTL), true
+}
+
+func variantKey(key string, size int) string {
+	return key + "/" + strconv.Itoa(size) + imaging.Extension
+}
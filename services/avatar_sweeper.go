@@ -0,0 +1,131 @@
+package services
+
+import (
+	"UserManagementVer/repositories"
+	"UserManagementVer/storage"
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// sweepBatchSize là số avatar được kiểm tra tham chiếu trong một truy vấn
+const sweepBatchSize = 200
+
+// AvatarSweeper định kỳ xóa file avatar không còn tài khoản nào tham chiếu,
+// vd: file còn sót khi upload lỗi giữa chừng hoặc từ các phiên bản cũ không xóa avatar bị thay.
+type AvatarSweeper struct {
+	avatarService     *AvatarService
+	accountCollection repositories.AccountRepository
+}
+
+func NewAvatarSweeper(avatarService *AvatarService, accountCollection repositories.AccountRepository) *AvatarSweeper {
+	return &AvatarSweeper{
+		avatarService:     avatarService,
+		accountCollection: accountCollection,
+	}
+}
+
+// Sweep xóa các avatar không được image_url nào tham chiếu, có mọi file cũ hơn gracePeriod và không còn
+// lease, grace period tránh xóa avatar vừa upload mà tài khoản chưa kịp cập nhật. Trả về số file đã xóa.
+func (s *AvatarSweeper) Sweep(ctx context.Context, gracePeriod time.Duration) (int, error) {
+	now := time.Now()
+	cutoff := now.Add(-gracePeriod)
+	// Một avatar gồm nhiều biến thể nên gom file theo key gốc
+	files := map[string][]string{}
+	fresh := map[string]bool{}
+	err := s.avatarService.storage.List(ctx, "", func(object storage.ObjectInfo) error {
+		avatar, ok := avatarOf(object.Key)
+		if !ok {
+			return nil
+		}
+		if object.ModTime.After(cutoff) {
+			fresh[avatar] = true
+		}
+		// Upload chậm hơn grace period vẫn giữ avatar tới khi lease hết hạn
+		if expires, ok := leaseExpiry(avatar, object); ok && now.Before(expires) {
+			fresh[avatar] = true
+		}
+		files[avatar] = append(files[avatar], object.Key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	candidates := make([]string, 0, len(files))
+	for avatar := range files {
+		if !fresh[avatar] {
+			candidates = append(candidates, avatar)
+		}
+	}
+	sort.Strings(candidates)
+
+	removed := 0
+	for start := 0; start < len(candidates); start += sweepBatchSize {
+		batch := candidates[start:min(start+sweepBatchSize, len(candidates))]
+		referenced, err := s.referenced(ctx, batch)
+		if err != nil {
+			return removed, err
+		}
+		for _, avatar := range batch {
+			if referenced[avatar] {
+				continue
+			}
+			for _, key := range files[avatar] {
+				err := s.avatarService.storage.Delete(ctx, key)
+				if err != nil && !errors.Is(err, storage.ErrNotFound) {
+					return removed, err
+				}
+				removed++
+			}
+		}
+	}
+	return removed, nil
+}
+
+// referenced trả về các avatar trong batch đang được tài khoản (kể cả trong thùng rác) tham chiếu
+func (s *AvatarSweeper) referenced(ctx context.Context, batch []string) (map[string]bool, error) {
+	imageUrls := []string{}
+	for _, avatar := range batch {
+		imageUrls = append(imageUrls, imageUrlsOf(avatar)...)
+	}
+	accounts, err := s.accountCollection.FindAll(ctx, repositories.AccountQuery{ImageUrls: imageUrls})
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	for _, account := range accounts {
+		referenced[storage.KeyFromImageUrl(account.ImageUrl)] = true
+	}
+	return referenced, nil
+}
+
+// Start chạy job dọn avatar định kỳ cho tới khi ctx bị hủy
+func (s *AvatarSweeper) Start(ctx context.Context, interval time.Duration, gracePeriod time.Duration) {
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+	if gracePeriod <= 0 {
+		gracePeriod = 24 * time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, interval)
+		removed, err := s.Sweep(runCtx, gracePeriod)
+		cancel()
+		if err != nil {
+			log.Println("Job dọn avatar lỗi", err)
+		} else if removed > 0 {
+			log.Printf("Đã xóa %d file avatar không còn được dùng\n", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package services_test
+
+import (
+	"UserManagementVer/imaging"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAvatarSweeper(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	accounts := memory.NewAccountRepository()
+	sweeper := services.NewAvatarSweeper(services.NewAvatarService(local, accounts, nil, 1<<20, imaging.Options{Sizes: []int{16, 64}}), accounts)
+
+	old := time.Now().Add(-48 * time.Hour)
+	put := func(key string, modTime time.Time) {
+		if err := local.Put(ctx, key, strings.NewReader("x"), 1, "image/jpeg"); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(key)), modTime, modTime); err != nil {
+			t.Fatalf("Chtimes %s: %v", key, err)
+		}
+	}
+	// Avatar đang dùng, avatar mồ côi đã cũ, avatar mồ côi vừa upload và file cũ trong uploads
+	put("avatars/a/used/16.jpg", old)
+	put("avatars/a/used/64.jpg", old)
+	put("avatars/a/orphan/16.jpg", old)
+	put("avatars/a/orphan/64.jpg", old)
+	put("avatars/b/fresh/16.jpg", old)
+	put("avatars/b/fresh/64.jpg", time.Now())
+	put("legacy_used.jpg", old)
+	put("legacy_orphan.jpg", old)
+	put("imports/report.csv", old)
+
+	for _, account := range []models.Account{
+		{Name: "A", Email: "a@example.com", Password: "secret123", ImageUrl: "avatars/a/used"},
+		{Name: "B", Email: "b@example.com", Password: "secret123", ImageUrl: `uploads\legacy_used.jpg`},
+	} {
+		if _, err := accounts.Create(ctx, account); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	removed, err := sweeper.Sweep(ctx, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("muốn xóa 3 file, đã xóa %d", removed)
+	}
+
+	remaining := []string{}
+	local.List(ctx, "", func(object storage.ObjectInfo) error {
+		remaining = append(remaining, object.Key)
+		return nil
+	})
+	sort.Strings(remaining)
+	want := []string{"avatars/a/used/16.jpg", "avatars/a/used/64.jpg", "avatars/b/fresh/16.jpg", "avatars/b/fresh/64.jpg", "imports/report.csv", "legacy_used.jpg"}
+	if strings.Join(remaining, ",") != strings.Join(want, ",") {
+		t.Fatalf("còn lại %v, muốn %v", remaining, want)
+	}
+}
@@ -0,0 +1,142 @@
+package services_test
+
+import (
+	"UserManagementVer/imaging"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tài khoản A bỏ avatar đúng lúc tài khoản B upload lại cùng ảnh: Upload đã trả key (trùng nội dung)
+// nhưng image_url của B chưa được cập nhật nên avatar chưa được đếm là có tham chiếu.
+func TestAvatarReleaseDuringUpload(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	accounts := memory.NewAccountRepository()
+	avatarService := services.NewAvatarService(local, accounts, nil, 1<<20, imaging.Options{Sizes: []int{16, 64}})
+	data := avatarPng(t)
+
+	first, err := avatarService.Upload(ctx, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	a, _ := accounts.Create(ctx, models.Account{Name: "A", Email: "a@example.com", Password: "secret123", ImageUrl: first.Key})
+	if err := avatarService.Confirm(ctx, first); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	b, _ := accounts.Create(ctx, models.Account{Name: "B", Email: "b@example.com", Password: "secret123"})
+
+	t.Run("lease giữ avatar", func(t *testing.T) {
+		second, err := avatarService.Upload(ctx, bytes.NewReader(data))
+		if err != nil || !second.Deduplicated {
+			t.Fatalf("Upload phải dùng lại avatar đã có, nhận %+v %v", second, err)
+		}
+		// A bỏ avatar trước khi B cập nhật image_url
+		accounts.Update(ctx, repositories.ById(a), repositories.AccountUpdate{ImageUrl: repositories.Ptr("")})
+		if removed, err := avatarService.Release(ctx, a, first.Key); err != nil || removed {
+			t.Fatalf("Release không được xóa avatar đang có upload dùng lại, nhận %v %v", removed, err)
+		}
+		accounts.Update(ctx, repositories.ById(b), repositories.AccountUpdate{ImageUrl: &second.Key})
+		if err := avatarService.Confirm(ctx, second); err != nil {
+			t.Fatalf("Confirm: %v", err)
+		}
+		expectAvatar(t, avatarService, local, second.Key)
+	})
+
+	t.Run("Confirm lưu lại avatar đã bị xóa", func(t *testing.T) {
+		accounts.Update(ctx, repositories.ById(b), repositories.AccountUpdate{ImageUrl: repositories.Ptr("")})
+		second, err := avatarService.Upload(ctx, bytes.NewReader(data))
+		if err != nil || !second.Deduplicated {
+			t.Fatalf("Upload phải dùng lại avatar đã có, nhận %+v %v", second, err)
+		}
+		// Release của tài khoản khác đã qua bước kiểm tra lease trước khi lease được ghi và xóa file sau đó
+		for _, key := range avatarKeys(t, local) {
+			if !strings.Contains(key, "/lease-") {
+				local.Delete(ctx, key)
+			}
+		}
+		accounts.Update(ctx, repositories.ById(b), repositories.AccountUpdate{ImageUrl: &second.Key})
+		if err := avatarService.Confirm(ctx, second); err != nil {
+			t.Fatalf("Confirm: %v", err)
+		}
+		expectAvatar(t, avatarService, local, second.Key)
+	})
+
+	t.Run("lease còn hạn tới hết timeout của upload", func(t *testing.T) {
+		accounts.Update(ctx, repositories.ById(b), repositories.AccountUpdate{ImageUrl: repositories.Ptr("")})
+		uploadCtx, cancel := context.WithTimeout(ctx, time.Hour)
+		defer cancel()
+		third, err := avatarService.Upload(uploadCtx, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Upload: %v", err)
+		}
+		// Xử lý ảnh chậm: lease đã được ghi từ lâu nhưng upload vẫn chưa hết timeout
+		old := time.Now().Add(-2 * time.Hour)
+		for _, key := range avatarKeys(t, local) {
+			if strings.Contains(key, "/lease-") {
+				os.Chtimes(filepath.Join(dir, filepath.FromSlash(key)), old, old)
+			}
+		}
+		if removed, err := avatarService.Release(ctx, a, third.Key); err != nil || removed {
+			t.Fatalf("Release không được xóa avatar khi upload chưa hết timeout, nhận %v %v", removed, err)
+		}
+		if removed, err := services.NewAvatarSweeper(avatarService, accounts).Sweep(ctx, 0); err != nil || removed != 0 {
+			t.Fatalf("Sweep không được xóa avatar khi upload chưa hết timeout, nhận %v %v", removed, err)
+		}
+		accounts.Update(ctx, repositories.ById(b), repositories.AccountUpdate{ImageUrl: &third.Key})
+		if err := avatarService.Confirm(uploadCtx, third); err != nil {
+			t.Fatalf("Confirm: %v", err)
+		}
+		expectAvatar(t, avatarService, local, third.Key)
+	})
+}
+
+// expectAvatar kiểm tra avatar còn đủ biến thể và không còn lease sau Confirm
+func expectAvatar(t *testing.T, avatarService *services.AvatarService, local storage.Storage, key string) {
+	t.Helper()
+	if exists, err := avatarService.Exists(context.Background(), key); err != nil || !exists {
+		t.Fatalf("avatar %s phải còn đủ biến thể, nhận %v %v", key, exists, err)
+	}
+	for _, file := range avatarKeys(t, local) {
+		if strings.Contains(file, "/lease-") {
+			t.Fatalf("Confirm phải xóa lease, còn %s", file)
+		}
+	}
+}
+
+func avatarKeys(t *testing.T, local storage.Storage) []string {
+	t.Helper()
+	keys := []string{}
+	err := local.List(context.Background(), "avatars/", func(object storage.ObjectInfo) error {
+		keys = append(keys, object.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return keys
+}
+
+func avatarPng(t *testing.T) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 100, 100))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
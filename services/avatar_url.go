@@ -0,0 +1,93 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidAvatarUrl = errors.New("Liên kết ảnh không hợp lệ hoặc đã hết hạn")
+
+// AvatarUrlSigner ký URL công khai của avatar để thẻ <img> tải được mà không cần header Authorization
+type AvatarUrlSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// AvatarUrlParams là các tham số đã được ký trong URL công khai
+type AvatarUrlParams struct {
+	Version string
+	Size    int
+	Format  string
+	Expires time.Time
+}
+
+func NewAvatarUrlSigner(secret string, ttl time.Duration) *AvatarUrlSigner {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &AvatarUrlSigner{secret: []byte(secret), ttl: ttl}
+}
+
+// Sign trả về query string của URL công khai. Thời điểm hết hạn được làm tròn lên theo ttl
+// nên trong cùng một khoảng ttl URL không đổi và trình duyệt dùng lại được ảnh đã cache.
+// Khi còn ít hơn nửa ttl tới mốc kế tiếp thì dùng mốc sau đó, vì vậy URL luôn còn hạn
+// từ ttl/2 tới 3*ttl/2.
+func (s *AvatarUrlSigner) Sign(accountId primitive.ObjectID, params AvatarUrlParams, now time.Time) (url.Values, time.Time) {
+	params.Expires = now.Truncate(s.ttl).Add(s.ttl)
+	if params.Expires.Sub(now) < s.ttl/2 {
+		params.Expires = params.Expires.Add(s.ttl)
+	}
+	query := url.Values{}
+	query.Set("v", params.Version)
+	if params.Size != 0 {
+		query.Set("size", strconv.Itoa(params.Size))
+	}
+	if params.Format != "" {
+		query.Set("format", params.Format)
+	}
+	query.Set("expires", strconv.FormatInt(params.Expires.Unix(), 10))
+	query.Set("sig", s.signature(accountId, params))
+	return query, params.Expires
+}
+
+// Verify kiểm tra chữ ký và hạn của URL, trả về các tham số đã ký
+func (s *AvatarUrlSigner) Verify(accountId primitive.ObjectID, query url.Values, now time.Time) (AvatarUrlParams, error) {
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return AvatarUrlParams{}, ErrInvalidAvatarUrl
+	}
+	params := AvatarUrlParams{
+		Version: query.Get("v"),
+		Format:  query.Get("format"),
+		Expires: time.Unix(expires, 0),
+	}
+	if value := query.Get("size"); value != "" {
+		if params.Size, err = strconv.Atoi(value); err != nil {
+			return AvatarUrlParams{}, ErrInvalidAvatarUrl
+		}
+	}
+	if !hmac.Equal([]byte(query.Get("sig")), []byte(s.signature(accountId, params))) || now.After(params.Expires) {
+		return AvatarUrlParams{}, ErrInvalidAvatarUrl
+	}
+	return params, nil
+}
+
+func (s *AvatarUrlSigner) signature(accountId primitive.ObjectID, params AvatarUrlParams) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join([]string{
+		accountId.Hex(),
+		params.Version,
+		strconv.Itoa(params.Size),
+		params.Format,
+		strconv.FormatInt(params.Expires.Unix(), 10),
+	}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,48 @@
+package services_test
+
+import (
+	"UserManagementVer/services"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAvatarUrlSigner(t *testing.T) {
+	signer := services.NewAvatarUrlSigner("secret", time.Hour)
+	accountId := primitive.NewObjectID()
+	params := services.AvatarUrlParams{Version: "v1", Size: 64, Format: "svg"}
+	now := time.Date(2026, 1, 1, 10, 5, 0, 0, time.UTC)
+
+	query, expires := signer.Sign(accountId, params, now)
+	if !expires.Equal(time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Fatalf("hạn phải được làm tròn theo ttl, nhận %v", expires)
+	}
+	// Trong cùng khoảng ttl URL không đổi để trình duyệt dùng lại cache
+	if again, _ := signer.Sign(accountId, params, now.Add(20*time.Minute)); again.Encode() != query.Encode() {
+		t.Fatalf("URL phải giữ nguyên trong một khoảng ttl: %s và %s", query.Encode(), again.Encode())
+	}
+	// Còn chưa tới nửa ttl thì dùng mốc sau để URL không hết hạn ngay sau khi ký
+	if _, late := signer.Sign(accountId, params, now.Add(40*time.Minute)); !late.Equal(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("URL ký sát mốc phải hết hạn ở mốc sau, nhận %v", late)
+	}
+
+	verified, err := signer.Verify(accountId, query, now)
+	if err != nil || verified.Version != "v1" || verified.Size != 64 || verified.Format != "svg" {
+		t.Fatalf("Verify: %+v %v", verified, err)
+	}
+	if _, err := signer.Verify(accountId, query, expires.Add(time.Second)); !errors.Is(err, services.ErrInvalidAvatarUrl) {
+		t.Fatalf("URL hết hạn phải bị từ chối, nhận %v", err)
+	}
+	if _, err := signer.Verify(primitive.NewObjectID(), query, now); !errors.Is(err, services.ErrInvalidAvatarUrl) {
+		t.Fatalf("URL của tài khoản khác phải bị từ chối, nhận %v", err)
+	}
+	if _, err := services.NewAvatarUrlSigner("other", time.Hour).Verify(accountId, query, now); !errors.Is(err, services.ErrInvalidAvatarUrl) {
+		t.Fatalf("khóa khác phải bị từ chối, nhận %v", err)
+	}
+	query.Set("format", "png")
+	if _, err := signer.Verify(accountId, query, now); !errors.Is(err, services.ErrInvalidAvatarUrl) {
+		t.Fatalf("sửa tham số phải bị từ chối, nhận %v", err)
+	}
+}
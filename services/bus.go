@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessageBus là giao diện tối thiểu của một message bus kiểu NATS:
+// subject phân cấp bằng dấu chấm, msgId dùng để bên nhận loại bỏ tin nhắn trùng.
+type MessageBus interface {
+	Publish(ctx context.Context, subject string, msgId string, data []byte) error
+}
+
+type BusMessage struct {
+	Subject string
+	MsgId   string
+	Data    []byte
+}
+
+type busSubscription struct {
+	pattern []string
+	handler func(BusMessage)
+}
+
+// LocalBus là message bus trong tiến trình, hỗ trợ wildcard "*" (một token) và ">" (các token còn lại).
+// Tin nhắn cùng msgId trong dedupWindow chỉ được chuyển tới subscriber một lần.
+type LocalBus struct {
+	mu            sync.RWMutex
+	subscriptions []busSubscription
+	seen          map[string]time.Time
+	dedupWindow   time.Duration
+}
+
+func NewLocalBus(dedupWindow time.Duration) *LocalBus {
+	if dedupWindow <= 0 {
+		dedupWindow = 2 * time.Minute
+	}
+	return &LocalBus{seen: map[string]time.Time{}, dedupWindow: dedupWindow}
+}
+
+func (b *LocalBus) Subscribe(pattern string, handler func(BusMessage)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions = append(b.subscriptions, busSubscription{pattern: strings.Split(pattern, "."), handler: handler})
+}
+
+func (b *LocalBus) Publish(ctx context.Context, subject string, msgId string, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if msgId != "" && b.duplicate(msgId) {
+		return nil
+	}
+	message := BusMessage{Subject: subject, MsgId: msgId, Data: data}
+	tokens := strings.Split(subject, ".")
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, subscription := range b.subscriptions {
+		if matchSubject(subscription.pattern, tokens) {
+			b.deliver(subscription.handler, message)
+		}
+	}
+	return nil
+}
+
+// deliver không để lỗi của một subscriber làm dừng relay
+func (b *LocalBus) deliver(handler func(BusMessage), message BusMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Subscriber xử lý tin nhắn lỗi", message.Subject, r)
+		}
+	}()
+	handler(message)
+}
+
+func (b *LocalBus) duplicate(msgId string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	for id, at := range b.seen {
+		if now.Sub(at) > b.dedupWindow {
+			delete(b.seen, id)
+		}
+	}
+	if _, ok := b.seen[msgId]; ok {
+		return true
+	}
+	b.seen[msgId] = now
+	return false
+}
+
+func matchSubject(pattern []string, tokens []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return len(tokens) > i
+		}
+		if i >= len(tokens) || (token != "*" && token != tokens[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(tokens)
+}
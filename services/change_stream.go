@@ -0,0 +1,302 @@
+package services
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Mã lỗi MongoDB liên quan tới change stream
+const (
+	errCodeChangeStreamUnsupported = 40573
+	errCodeInvalidResumeToken      = 260
+	errCodeChangeStreamFatal       = 280
+	errCodeChangeStreamHistoryLost = 286
+)
+
+const changeStreamMaxBackoff = time.Minute
+
+// ChangeSource là collection có thể mở change stream, token rỗng thì bắt đầu từ hiện tại
+type ChangeSource interface {
+	Watch(ctx context.Context, resumeToken bson.Raw) (*mongo.ChangeStream, error)
+}
+
+// ChangeHandler xử lý một thay đổi đọc từ change stream. Sau khi khởi động lại, các thay đổi
+// chưa kịp lưu token sẽ được gửi lại nên handler phải chịu được việc xử lý trùng.
+type ChangeHandler interface {
+	HandleChange(ctx context.Context, event models.ChangeEvent) error
+}
+
+// ChangeStreamWatcher theo dõi thay đổi của một collection, kể cả thay đổi từ script hay service khác,
+// và lưu resume token sau mỗi sự kiện để chạy tiếp đúng vị trí khi khởi động lại.
+type ChangeStreamWatcher struct {
+	name            string
+	source          ChangeSource
+	tokenCollection *collections.ChangeStreamTokenCollection
+	handlers        []ChangeHandler
+}
+
+func NewChangeStreamWatcher(name string, source ChangeSource, tokenCollection *collections.ChangeStreamTokenCollection, handlers ...ChangeHandler) *ChangeStreamWatcher {
+	return &ChangeStreamWatcher{
+		name:            name,
+		source:          source,
+		tokenCollection: tokenCollection,
+		handlers:        handlers,
+	}
+}
+
+// Start chạy watcher cho tới khi ctx bị hủy, stream lỗi thì mở lại theo back-off.
+// MongoDB standalone không có change stream nên watcher chỉ ghi log rồi dừng.
+func (w *ChangeStreamWatcher) Start(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		startedAt := time.Now()
+		err := w.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		switch {
+		case isChangeStreamUnsupported(err):
+			log.Println("MongoDB không chạy replica set, tắt change stream", w.name)
+			return
+		case isResumeTokenLost(err):
+			// Token quá cũ so với oplog, bỏ token và theo dõi tiếp từ hiện tại
+			log.Println("Resume token không còn hợp lệ, change stream chạy lại từ hiện tại", w.name, err)
+			deleteCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			if err := w.tokenCollection.Delete(deleteCtx, w.name); err != nil {
+				log.Println("Không thể xóa resume token", w.name, err)
+			}
+			cancel()
+			continue
+		case err != nil:
+			log.Println("Change stream lỗi", w.name, err)
+		}
+
+		if time.Since(startedAt) > changeStreamMaxBackoff {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < changeStreamMaxBackoff {
+			backoff *= 2
+		}
+	}
+}
+
+func (w *ChangeStreamWatcher) run(ctx context.Context) error {
+	loadCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	token, err := w.tokenCollection.Get(loadCtx, w.name)
+	cancel()
+	if err != nil {
+		return err
+	}
+	stream, err := w.source.Watch(ctx, token)
+	if err != nil {
+		return err
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		var event models.ChangeEvent
+		if err := stream.Decode(&event); err != nil {
+			log.Println("Không thể đọc sự kiện change stream", w.name, err)
+		} else {
+			w.handle(ctx, event)
+		}
+		saveCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := w.tokenCollection.Save(saveCtx, w.name, stream.ResumeToken()); err != nil {
+			log.Println("Không thể lưu resume token", w.name, err)
+		}
+		cancel()
+	}
+	return stream.Err()
+}
+
+// handle chạy lần lượt các handler, lỗi của một handler không chặn các handler khác
+func (w *ChangeStreamWatcher) handle(ctx context.Context, event models.ChangeEvent) {
+	for _, handler := range w.handlers {
+		handlerCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		if err := handler.HandleChange(handlerCtx, event); err != nil {
+			log.Println("Xử lý change stream lỗi", w.name, event.OperationType, event.DocumentKey.Id.Hex(), err)
+		}
+		cancel()
+	}
+}
+
+func isChangeStreamUnsupported(err error) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	return serverErr.HasErrorCode(errCodeChangeStreamUnsupported) ||
+		serverErr.HasErrorMessage("only supported on replica sets")
+}
+
+func isResumeTokenLost(err error) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	return serverErr.HasErrorCode(errCodeInvalidResumeToken) ||
+		serverErr.HasErrorCode(errCodeChangeStreamFatal) ||
+		serverErr.HasErrorCode(errCodeChangeStreamHistoryLost)
+}
+
+// changeEventId sinh id cố định từ resume token, sự kiện bị đọc lại sau khi khởi động lại
+// vẫn có cùng id nên outbox và bus loại được bản trùng.
+func changeEventId(event models.ChangeEvent) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, event.Id).String()
+}
+
+// OutboxChangeHandler ghi vào outbox các thay đổi không do ứng dụng thực hiện, để webhook
+// vẫn được gửi khi dữ liệu bị sửa trực tiếp trong MongoDB. Thay đổi của ứng dụng luôn nằm
+// trong transaction cùng sự kiện outbox nên được bỏ qua.
+type OutboxChangeHandler struct {
+	outboxCollection *collections.OutboxCollection
+	aggregateType    string
+}
+
+func NewOutboxChangeHandler(outboxCollection *collections.OutboxCollection, aggregateType string) *OutboxChangeHandler {
+	return &OutboxChangeHandler{outboxCollection: outboxCollection, aggregateType: aggregateType}
+}
+
+func (h *OutboxChangeHandler) HandleChange(ctx context.Context, change models.ChangeEvent) error {
+	if change.InTransaction() {
+		return nil
+	}
+	var (
+		event models.OutboxEvent
+		ok    bool
+		err   error
+	)
+	switch h.aggregateType {
+	case models.AggregateAccount:
+		event, ok, err = accountChangeEvent(change)
+	case models.AggregateSession:
+		event, ok, err = sessionChangeEvent(change)
+	}
+	if err != nil || !ok {
+		return err
+	}
+	event.EventId = changeEventId(change)
+	err = h.outboxCollection.Add(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+func accountChangeEvent(change models.ChangeEvent) (models.OutboxEvent, bool, error) {
+	if change.OperationType == models.ChangeDelete {
+		event, err := models.NewOutboxEvent(models.WebhookEventAccountDeleted, models.AggregateAccount, change.DocumentKey.Id, models.AccountEventData{
+			Id:        change.DocumentKey.Id.Hex(),
+			Status:    models.StatusDeleted,
+			Permanent: true,
+		})
+		return event, err == nil, err
+	}
+	// Tài khoản đã bị xóa trước khi đọc lại fullDocument, sự kiện delete sẽ tới sau
+	if len(change.FullDocument) == 0 {
+		return models.OutboxEvent{}, false, nil
+	}
+	var account models.Account
+	if err := bson.Unmarshal(change.FullDocument, &account); err != nil {
+		return models.OutboxEvent{}, false, err
+	}
+	eventType := models.WebhookEventAccountUpdated
+	switch {
+	case change.OperationType == models.ChangeInsert:
+		eventType = models.WebhookEventAccountCreated
+	case account.CurrentStatus(time.Now()) == models.StatusDeleted && (change.FieldUpdated("status") || change.FieldUpdated("deleted_at")):
+		eventType = models.WebhookEventAccountDeleted
+	}
+	event, err := models.NewOutboxEvent(eventType, models.AggregateAccount, account.Id, models.AccountEventPayload(account))
+	return event, err == nil, err
+}
+
+// sessionChangeEvent chỉ phát sự kiện khi session mới được tạo hoặc vừa được tin cậy
+func sessionChangeEvent(change models.ChangeEvent) (models.OutboxEvent, bool, error) {
+	if len(change.FullDocument) == 0 || change.OperationType == models.ChangeDelete {
+		return models.OutboxEvent{}, false, nil
+	}
+	if change.OperationType == models.ChangeUpdate && !change.FieldUpdated("refresh_token") && !change.FieldUpdated("approved_token") {
+		return models.OutboxEvent{}, false, nil
+	}
+	var session models.Session
+	if err := bson.Unmarshal(change.FullDocument, &session); err != nil {
+		return models.OutboxEvent{}, false, err
+	}
+	return models.NewSessionEvent(session)
+}
+
+// BusChangeHandler phát mọi thay đổi lên message bus với subject <prefix>.changes.<collection>.<operation>,
+// cache và chỉ mục tìm kiếm đăng ký subject tương ứng để làm mới dữ liệu.
+type BusChangeHandler struct {
+	bus    MessageBus
+	prefix string
+}
+
+func NewBusChangeHandler(bus MessageBus, prefix string) *BusChangeHandler {
+	return &BusChangeHandler{bus: bus, prefix: prefix}
+}
+
+func (h *BusChangeHandler) HandleChange(ctx context.Context, change models.ChangeEvent) error {
+	notice := models.ChangeNotice{
+		Collection:    change.Ns.Coll,
+		Operation:     change.OperationType,
+		DocumentId:    change.DocumentKey.Id.Hex(),
+		InTransaction: change.InTransaction(),
+	}
+	if change.UpdateDescription != nil {
+		for field := range change.UpdateDescription.UpdatedFields {
+			notice.UpdatedFields = append(notice.UpdatedFields, field)
+		}
+		notice.UpdatedFields = append(notice.UpdatedFields, change.UpdateDescription.RemovedFields...)
+	}
+	data, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	return h.bus.Publish(ctx, ChangeSubject(h.prefix, change.Ns.Coll, change.OperationType), changeEventId(change), data)
+}
+
+// ChangeSubject trả về subject của thông báo thay đổi, operation là ">" để đăng ký mọi thao tác
+func ChangeSubject(prefix string, collection string, operation string) string {
+	subject := strings.Join([]string{"changes", collection, operation}, ".")
+	if prefix != "" {
+		subject = prefix + "." + subject
+	}
+	return subject
+}
+
+// SubscribeAccountCache xóa cache tài khoản khi change stream báo có thay đổi, kể cả thay đổi
+// do ứng dụng ghi, để loại bỏ bản cũ có thể được ghi lại vào cache ngay sau khi xóa.
+func SubscribeAccountCache(bus *LocalBus, subject string, cache *collections.AccountCache) {
+	bus.Subscribe(subject, func(message BusMessage) {
+		var notice models.ChangeNotice
+		if err := json.Unmarshal(message.Data, &notice); err != nil {
+			log.Println("Thông báo thay đổi không hợp lệ", message.Subject, err)
+			return
+		}
+		accountId, err := primitive.ObjectIDFromHex(notice.DocumentId)
+		if err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		cache.Invalidate(ctx, models.Account{Id: accountId})
+	})
+}
@@ -22,16 +22,34 @@ func NewEmailService(host, user, pass string, port int) *EmailService {
 }
 
 func (e *EmailService) SendNewDeviceAlert(to string, deviceId string, loginTime string) error {
+	// nội dung email
+	subject := "Cảnh báo đăng nhập từ thiết bị mới"
+	body := fmt.Sprintf("Tài khoản của bạn vừa đăng nhập từ thiết bị lạ (DeviceID: %s) vào lúc %s.\n\nNếu không phải bạn, vui lòng đổi mật khẩu ngay.", deviceId, loginTime)
+
+	return e.send(to, subject, body)
+}
+
+func (e *EmailService) SendEmailChangeConfirmation(to string, confirmLink string) error {
+	subject := "Xác nhận địa chỉ email mới"
+	body := fmt.Sprintf("Có yêu cầu đổi email tài khoản sang địa chỉ này.\n\nBấm vào link sau để xác nhận: %s\n\nNếu không phải bạn, vui lòng bỏ qua email này.", confirmLink)
+
+	return e.send(to, subject, body)
+}
+
+func (e *EmailService) SendEmailChangeNotice(to string, newEmail string, undoLink string) error {
+	subject := "Thông báo đổi email tài khoản"
+	body := fmt.Sprintf("Có yêu cầu đổi email tài khoản của bạn sang %s.\n\nNếu không phải bạn, bấm vào link sau để hủy yêu cầu hoặc khôi phục email cũ: %s", newEmail, undoLink)
+
+	return e.send(to, subject, body)
+}
+
+func (e *EmailService) send(to string, subject string, body string) error {
 	from := e.User
 	password := e.Pass
 
 	// danh sách người nhận
 	recipients := []string{to}
 
-	// nội dung email
-	subject := "Cảnh báo đăng nhập từ thiết bị mới"
-	body := fmt.Sprintf("Tài khoản của bạn vừa đăng nhập từ thiết bị lạ (DeviceID: %s) vào lúc %s.\n\nNếu không phải bạn, vui lòng đổi mật khẩu ngay.", deviceId, loginTime)
-
 	msg := []byte(
 		"From: " + from + "\r\n" +
 			"To: " + to + "\r\n" +
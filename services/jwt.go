@@ -25,13 +25,23 @@ type JwtCustomClaim struct {
 }
 
 func (j *JwtService) GenerateJwt(email string, duration int, typeToken string) (string, *JwtCustomClaim, error) {
+	return j.generateJwt(email, email, duration, typeToken)
+}
+
+// GenerateConfirmJwt sinh token xác nhận gắn với một thao tác cụ thể,
+// subject là chuỗi đại diện (digest) của thao tác cần xác nhận.
+func (j *JwtService) GenerateConfirmJwt(email string, subject string, duration int, typeToken string) (string, *JwtCustomClaim, error) {
+	return j.generateJwt(email, subject, duration, typeToken)
+}
+
+func (j *JwtService) generateJwt(email string, subject string, duration int, typeToken string) (string, *JwtCustomClaim, error) {
 	tokenId, _ := uuid.NewRandom()
 	claims := &JwtCustomClaim{
 		Email: email,
 		Type:  typeToken,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ID:        tokenId.String(),
-			Subject:   email,
+			Subject:   subject,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(duration) * time.Second)),
 			Issuer:    j.Issuer,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	outboxBaseBackoff = 5 * time.Second
+	outboxMaxBackoff  = time.Hour
+	// outboxClaimLease là thời gian giữ sự kiện khi đang phát, hết hạn thì relay khác được phát lại
+	outboxClaimLease = time.Minute
+	// Sự kiện đã phát được giữ outboxRetention để đối soát rồi bị xóa, kiểm tra mỗi outboxCleanupInterval
+	outboxRetention       = 7 * 24 * time.Hour
+	outboxCleanupInterval = time.Hour
+)
+
+// OutboxSink là nơi nhận sự kiện từ relay. Sự kiện có thể được phát lại nhiều lần
+// (at-least-once) nên sink phải dùng EventId để loại bỏ bản trùng.
+type OutboxSink interface {
+	Name() string
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+// OutboxRelay đọc sự kiện đang chờ trong outbox và phát tới từng sink,
+// sink đã nhận thành công được ghi lại để lần thử sau chỉ phát tới sink còn lỗi.
+// outbox phải nằm cùng backend với tài khoản và session để sự kiện được ghi chung transaction.
+type OutboxRelay struct {
+	outbox      repositories.OutboxRepository
+	sinks       []OutboxSink
+	maxAttempts int
+	lastCleanup time.Time
+}
+
+func NewOutboxRelay(outbox repositories.OutboxRepository, sinks []OutboxSink, maxAttempts int) *OutboxRelay {
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+	return &OutboxRelay{
+		outbox:      outbox,
+		sinks:       sinks,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Start chạy relay cho tới khi ctx bị hủy
+func (r *OutboxRelay) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.processDue(ctx)
+		r.cleanup(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *OutboxRelay) processDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now()
+		claimCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		event, err := r.outbox.ClaimDue(claimCtx, now, outboxClaimLease)
+		cancel()
+		if errors.Is(err, repositories.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.Println("Không thể lấy sự kiện outbox", err)
+			return
+		}
+		r.publish(ctx, event)
+	}
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, event models.OutboxEvent) {
+	delivered := map[string]bool{}
+	for _, name := range event.DeliveredSinks {
+		delivered[name] = true
+	}
+	failures := []string{}
+	for _, sink := range r.sinks {
+		if delivered[sink.Name()] {
+			continue
+		}
+		sinkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := sink.Publish(sinkCtx, event)
+		cancel()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+			continue
+		}
+		event.DeliveredSinks = append(event.DeliveredSinks, sink.Name())
+	}
+
+	dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	event.Attempts++
+	switch {
+	case len(failures) == 0:
+		event.Status = models.OutboxPublished
+		event.PublishedAt = time.Now()
+		event.LastError = ""
+		event.NextAttemptAt = time.Time{}
+	case event.Attempts >= r.maxAttempts:
+		event.Status = models.OutboxFailed
+		event.LastError = strings.Join(failures, "; ")
+		event.NextAttemptAt = time.Time{}
+	default:
+		event.LastError = strings.Join(failures, "; ")
+		event.NextAttemptAt = time.Now().Add(exponentialBackoff(event.Attempts, outboxBaseBackoff, outboxMaxBackoff))
+	}
+	if err := r.outbox.SaveAttempt(dbCtx, event); err != nil {
+		log.Println("Không thể cập nhật sự kiện outbox", event.EventId, err)
+	}
+}
+
+// cleanup xóa sự kiện đã phát quá outboxRetention, chạy tối đa một lần mỗi outboxCleanupInterval
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	if time.Since(r.lastCleanup) < outboxCleanupInterval {
+		return
+	}
+	r.lastCleanup = time.Now()
+	cleanupCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	if _, err := r.outbox.DeletePublished(cleanupCtx, time.Now().Add(-outboxRetention)); err != nil {
+		log.Println("Không thể dọn outbox", err)
+	}
+}
+
+// WebhookSink chuyển sự kiện thành webhook delivery cho các subscription đã đăng ký
+type WebhookSink struct {
+	webhookService *WebhookService
+}
+
+func NewWebhookSink(webhookService *WebhookService) *WebhookSink {
+	return &WebhookSink{webhookService}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	return s.webhookService.Deliver(ctx, event.EventId, event.Type, event.CreatedAt, json.RawMessage(event.Data))
+}
+
+// LogSink ghi sự kiện ra log, dùng khi phát triển hoặc để đối soát
+type LogSink struct{}
+
+func (LogSink) Name() string {
+	return "log"
+}
+
+func (LogSink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	log.Printf("[outbox] %s %s %s/%s %s\n", event.EventId, event.Type, event.AggregateType, event.AggregateId.Hex(), event.Data)
+	return nil
+}
+
+// BusSink phát sự kiện lên message bus với subject dạng <prefix>.<type>, ví dụ usermanagement.account.created
+type BusSink struct {
+	bus    MessageBus
+	prefix string
+}
+
+func NewBusSink(bus MessageBus, prefix string) *BusSink {
+	return &BusSink{bus: bus, prefix: prefix}
+}
+
+func (s *BusSink) Name() string {
+	return "bus"
+}
+
+func (s *BusSink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	subject := event.Type
+	if s.prefix != "" {
+		subject = s.prefix + "." + subject
+	}
+	return s.bus.Publish(ctx, subject, event.EventId, []byte(event.Data))
+}
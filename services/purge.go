@@ -0,0 +1,236 @@
+package services
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LegacyTTLIndexName là TTL index cũ trên deleted_at, được thay bằng job purge
+const LegacyTTLIndexName = "deleted_at_1"
+
+// PurgeService xóa vĩnh viễn tài khoản đã hết thời gian lưu trong thùng rác,
+// kèm theo session, token đổi email và file avatar của tài khoản đó.
+type PurgeService struct {
+	accountCollection repositories.AccountRepository
+	// accountIndexes là index của collection accounts trên MongoDB, dùng để gỡ TTL index cũ
+	accountIndexes        *collections.IndexManager
+	sessionCollection     repositories.SessionRepository
+	emailChangeCollection *collections.EmailChangeCollection
+	historyCollection     *collections.AccountHistoryCollection
+	settingCollection     *collections.SettingCollection
+	purgeRecordCollection *collections.PurgeRecordCollection
+	auditService          *AuditService
+	avatarService         *AvatarService
+}
+
+func NewPurgeService(accountCollection repositories.AccountRepository, accountIndexes *collections.IndexManager, sessionCollection repositories.SessionRepository, emailChangeCollection *collections.EmailChangeCollection, historyCollection *collections.AccountHistoryCollection, settingCollection *collections.SettingCollection, purgeRecordCollection *collections.PurgeRecordCollection, auditService *AuditService, avatarService *AvatarService) *PurgeService {
+	return &PurgeService{
+		accountCollection:     accountCollection,
+		accountIndexes:        accountIndexes,
+		sessionCollection:     sessionCollection,
+		emailChangeCollection: emailChangeCollection,
+		historyCollection:     historyCollection,
+		settingCollection:     settingCollection,
+		purgeRecordCollection: purgeRecordCollection,
+		auditService:          auditService,
+		avatarService:         avatarService,
+	}
+}
+
+// RetentionDays trả về số ngày giữ tài khoản trong thùng rác, 0 là không tự động xóa
+func (p *PurgeService) RetentionDays(ctx context.Context) (int, error) {
+	var days int
+	if _, err := p.settingCollection.Get(ctx, models.SettingAccountRetentionDays, &days); err != nil {
+		return 0, err
+	}
+	return days, nil
+}
+
+func (p *PurgeService) SetRetentionDays(ctx context.Context, days int, updatedBy primitive.ObjectID) error {
+	if days < 0 {
+		return errors.New("Số ngày lưu trữ không được âm")
+	}
+	return p.settingCollection.Set(ctx, models.SettingAccountRetentionDays, days, updatedBy)
+}
+
+// MigrateTTLIndex chuyển thời gian lưu của TTL index cũ vào settings rồi xóa index,
+// để tài khoản không còn bị MongoDB xóa ngầm mà bỏ sót dữ liệu liên quan.
+func (p *PurgeService) MigrateTTLIndex(ctx context.Context) error {
+	index, err := p.accountIndexes.FindIndex(ctx, LegacyTTLIndexName)
+	if err != nil || index == nil || index.ExpireAfterSeconds == nil {
+		return err
+	}
+	var days int
+	found, err := p.settingCollection.Get(ctx, models.SettingAccountRetentionDays, &days)
+	if err != nil {
+		return err
+	}
+	if !found {
+		if err := p.SetRetentionDays(ctx, int(*index.ExpireAfterSeconds/(24*60*60)), primitive.NilObjectID); err != nil {
+			return err
+		}
+	}
+	return p.accountIndexes.DropIndex(ctx, LegacyTTLIndexName)
+}
+
+// ErrNotInTrash là lỗi khi tài khoản đã được khôi phục hoặc xóa trước khi purge nhận xử lý
+var ErrNotInTrash = errors.New("Tài khoản không còn nằm trong thùng rác")
+
+// PurgeAccount chuyển tài khoản sang StatusPurging trước để không thể khôi phục trong lúc xóa,
+// sau đó xóa dữ liệu liên quan, tài khoản được xóa sau cùng để nếu giữa chừng bị lỗi
+// thì lần chạy sau vẫn tìm thấy và xóa tiếp.
+func (p *PurgeService) PurgeAccount(ctx context.Context, account models.Account, trigger string, purgedBy primitive.ObjectID) (models.PurgeRecord, error) {
+	if account.Status != models.StatusPurging {
+		err := p.accountCollection.Update(ctx, repositories.AccountQuery{
+			Ids:    []primitive.ObjectID{account.Id},
+			Status: models.StatusDeleted,
+		}, repositories.AccountUpdate{Status: repositories.Ptr(models.StatusPurging)})
+		if errors.Is(err, repositories.ErrNoMatch) {
+			return models.PurgeRecord{}, ErrNotInTrash
+		}
+		if err != nil {
+			return models.PurgeRecord{}, err
+		}
+	}
+
+	record := models.PurgeRecord{
+		AccountId: account.Id,
+		Email:     account.Email,
+		DeletedAt: account.DeletedAt,
+		DeletedBy: account.DeletedBy,
+		Trigger:   trigger,
+		PurgedBy:  purgedBy,
+	}
+
+	sessionsDeleted, err := p.sessionCollection.DeleteSessions(ctx, repositories.SessionQuery{UserId: account.Id})
+	if err != nil {
+		return record, fmt.Errorf("Không thể xóa session: %w", err)
+	}
+	record.SessionsDeleted = sessionsDeleted
+
+	tokensDeleted, err := p.emailChangeCollection.DeleteMany(ctx, bson.M{"user_id": account.Id})
+	if err != nil {
+		return record, fmt.Errorf("Không thể xóa token: %w", err)
+	}
+	record.TokensDeleted = tokensDeleted
+
+	historyDeleted, err := p.historyCollection.DeleteMany(ctx, bson.M{"account_id": account.Id})
+	if err != nil {
+		return record, fmt.Errorf("Không thể xóa lịch sử tài khoản: %w", err)
+	}
+	record.HistoryDeleted = historyDeleted
+
+	removed, err := p.avatarService.Release(ctx, account.Id, account.ImageUrl)
+	if err != nil {
+		return record, fmt.Errorf("Không thể xóa avatar: %w", err)
+	}
+	record.AvatarRemoved = removed
+
+	deleted, err := p.accountCollection.Purge(ctx, account)
+	if err != nil {
+		return record, err
+	}
+	if deleted == 0 {
+		return record, ErrNotInTrash
+	}
+
+	record.PurgedAt = time.Now()
+	if err := p.purgeRecordCollection.Create(ctx, record); err != nil {
+		log.Println("Không thể ghi log purge", account.Id.Hex(), err)
+	}
+	return record, nil
+}
+
+// PurgeExpired xóa tối đa batchSize tài khoản đã quá thời gian lưu
+func (p *PurgeService) PurgeExpired(ctx context.Context, batchSize int) (int, error) {
+	days, err := p.RetentionDays(ctx)
+	if err != nil || days <= 0 {
+		return 0, err
+	}
+	// Tài khoản đã nhận xử lý nhưng lần trước lỗi giữa chừng được xóa tiếp trước
+	accounts, err := p.accountCollection.FindAll(ctx, repositories.AccountQuery{
+		Status: models.StatusPurging,
+		Sort:   repositories.SortDeletedAtAsc,
+		Limit:  batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(accounts) < batchSize {
+		cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+		expired, err := p.accountCollection.FindAll(ctx, repositories.AccountQuery{
+			Status:            models.StatusDeleted,
+			DeletedAtOrBefore: cutoff,
+			Sort:              repositories.SortDeletedAtAsc,
+			Limit:             batchSize - len(accounts),
+		})
+		if err != nil {
+			return 0, err
+		}
+		accounts = append(accounts, expired...)
+	}
+
+	purged := 0
+	for _, account := range accounts {
+		// Job chạy nền nên sự kiện không có người thực hiện
+		event := models.AuditEvent{
+			Action:   models.AuditActionAccountPurge,
+			Outcome:  models.AuditOutcomeSuccess,
+			Target:   models.AccountTarget(account),
+			Metadata: bson.M{"trigger": models.PurgeTriggerSchedule},
+		}
+		if _, err := p.PurgeAccount(ctx, account, models.PurgeTriggerSchedule, primitive.NilObjectID); err != nil {
+			log.Println("Purge tài khoản thất bại", account.Id.Hex(), err)
+			event.Outcome = models.AuditOutcomeFailure
+			event.Reason = err.Error()
+			p.auditService.Log(event)
+			continue
+		}
+		p.auditService.Log(event)
+		purged++
+	}
+	return purged, nil
+}
+
+// Start chạy job purge định kỳ cho tới khi ctx bị hủy
+func (p *PurgeService) Start(ctx context.Context, interval time.Duration, batchSize int) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	migrateCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	if err := p.MigrateTTLIndex(migrateCtx); err != nil {
+		log.Println("Không thể chuyển TTL index sang settings", err)
+	}
+	cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, interval)
+		purged, err := p.PurgeExpired(runCtx, batchSize)
+		cancel()
+		if err != nil {
+			log.Println("Job purge lỗi", err)
+		} else if purged > 0 {
+			log.Printf("Đã purge %d tài khoản\n", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package services_test
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/services"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Tài khoản được khôi phục sau khi job purge đã đọc danh sách thùng rác nhưng trước khi xóa:
+// purge phải dừng trước khi đụng tới dữ liệu liên quan. Các collection MongoDB để nil,
+// nếu PurgeAccount đi tiếp qua bước nhận xử lý thì test sẽ panic.
+func TestPurgeAccountAfterRestore(t *testing.T) {
+	ctx := context.Background()
+	accounts := memory.NewAccountRepository()
+	sessions := memory.NewSessionRepository()
+	purgeService := services.NewPurgeService(accounts, nil, sessions, nil, nil, nil, nil, nil, nil)
+
+	id, _ := accounts.Create(ctx, models.Account{Name: "A", Email: "a@example.com", Password: "secret123", Status: models.StatusDeleted, DeletedAt: time.Now()})
+	sessions.FindAndUpdate(ctx, models.Session{UserId: id, DeviceId: "phone"})
+	stale, _ := accounts.GetAccountById(ctx, id)
+
+	accounts.Update(ctx, repositories.AccountQuery{Ids: []primitive.ObjectID{id}, Status: models.StatusDeleted}, repositories.AccountUpdate{Status: repositories.Ptr(models.StatusActive)})
+
+	if _, err := purgeService.PurgeAccount(ctx, stale, models.PurgeTriggerSchedule, primitive.NilObjectID); !errors.Is(err, services.ErrNotInTrash) {
+		t.Fatalf("PurgeAccount tài khoản vừa khôi phục phải trả ErrNotInTrash, nhận %v", err)
+	}
+	if account, _ := accounts.GetAccountById(ctx, id); account.Status != models.StatusActive {
+		t.Fatalf("tài khoản đã khôi phục bị đổi trạng thái: %+v", account)
+	}
+	if remaining, _ := sessions.Find(ctx, repositories.SessionQuery{UserId: id}); len(remaining) != 1 {
+		t.Fatalf("session của tài khoản đã khôi phục bị xóa, còn %d", len(remaining))
+	}
+}
@@ -0,0 +1,254 @@
+package services
+
+import (
+	"UserManagementVer/collections"
+	"UserManagementVer/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Các nhóm dữ liệu có chính sách lưu trữ
+const (
+	RetentionTargetAccounts    = "accounts"
+	RetentionTargetSessions    = "sessions"
+	RetentionTargetAuditEvents = "audit_events"
+)
+
+// Cơ chế xóa dữ liệu hết hạn
+const (
+	RetentionMechanismPurgeJob = "purge_job"
+	RetentionMechanismTTLIndex = "ttl_index"
+)
+
+// Trạng thái thực tế của chính sách lưu trữ
+const (
+	RetentionStatusOk        = "ok"
+	RetentionStatusDisabled  = "disabled"
+	RetentionStatusOutOfSync = "out_of_sync"
+	RetentionStatusNotTTL    = "not_ttl_index"
+	RetentionStatusLegacyTTL = "legacy_ttl_index"
+)
+
+// Kết quả khi áp dụng chính sách
+const (
+	RetentionAppliedCreated   = "created"
+	RetentionAppliedModified  = "modified"
+	RetentionAppliedDropped   = "dropped"
+	RetentionAppliedUpdated   = "updated"
+	RetentionAppliedUnchanged = "unchanged"
+)
+
+const (
+	MaxRetentionDays = 3650
+	secondsPerDay    = 24 * 60 * 60
+)
+
+type RetentionPolicy struct {
+	Target             string `json:"target"`
+	Collection         string `json:"collection"`
+	Mechanism          string `json:"mechanism"`
+	Field              string `json:"field"`
+	IndexName          string `json:"index_name,omitempty"`
+	Days               int    `json:"days"`
+	ConfiguredDays     *int   `json:"configured_days,omitempty"`
+	ExpireAfterSeconds *int64 `json:"expire_after_seconds,omitempty"`
+	Status             string `json:"status"`
+	Message            string `json:"message,omitempty"`
+	Applied            string `json:"applied,omitempty"`
+	PreviousDays       *int   `json:"previous_days,omitempty"`
+}
+
+type retentionTarget struct {
+	mechanism     string
+	field         string
+	indexName     string
+	partialFilter bson.M
+	indexes       *collections.IndexManager
+}
+
+type RetentionService struct {
+	purgeService      *PurgeService
+	settingCollection *collections.SettingCollection
+	targets           map[string]retentionTarget
+	order             []string
+}
+
+func NewRetentionService(purgeService *PurgeService, settingCollection *collections.SettingCollection, accountIndexes *collections.IndexManager, sessionIndexes *collections.IndexManager, auditIndexes *collections.IndexManager) *RetentionService {
+	return &RetentionService{
+		purgeService:      purgeService,
+		settingCollection: settingCollection,
+		targets: map[string]retentionTarget{
+			RetentionTargetAccounts: {
+				mechanism: RetentionMechanismPurgeJob,
+				field:     "deleted_at",
+				indexName: LegacyTTLIndexName,
+				indexes:   accountIndexes,
+			},
+			RetentionTargetSessions: {
+				mechanism: RetentionMechanismTTLIndex,
+				field:     "expires_at",
+				indexName: "expires_at_ttl",
+				// Session đang chờ duyệt có expires_at rỗng, không được để TTL xóa ngay
+				partialFilter: bson.M{"expires_at": bson.M{"$gt": time.Unix(0, 0)}},
+				indexes:       sessionIndexes,
+			},
+			RetentionTargetAuditEvents: {
+				mechanism: RetentionMechanismTTLIndex,
+				field:     "created_at",
+				indexName: AuditTTLIndexName,
+				indexes:   auditIndexes,
+			},
+		},
+		order: []string{RetentionTargetAccounts, RetentionTargetSessions, RetentionTargetAuditEvents},
+	}
+}
+
+func (r *RetentionService) List(ctx context.Context) ([]RetentionPolicy, error) {
+	policies := []RetentionPolicy{}
+	for _, target := range r.order {
+		policy, err := r.Get(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// Get đọc chính sách hiện tại, với TTL index giá trị được lấy trực tiếp từ index đang có trong MongoDB
+func (r *RetentionService) Get(ctx context.Context, target string) (RetentionPolicy, error) {
+	t, ok := r.targets[target]
+	if !ok {
+		return RetentionPolicy{}, fmt.Errorf("Không có chính sách lưu trữ cho %s", target)
+	}
+	policy := RetentionPolicy{
+		Target:     target,
+		Collection: t.indexes.CollectionName(),
+		Mechanism:  t.mechanism,
+		Field:      t.field,
+		IndexName:  t.indexName,
+	}
+
+	var configured int
+	found, err := r.settingCollection.Get(ctx, retentionSettingKey(target), &configured)
+	if err != nil {
+		return policy, err
+	}
+	if found {
+		policy.ConfiguredDays = &configured
+	}
+
+	index, err := t.indexes.FindIndex(ctx, t.indexName)
+	if err != nil {
+		return policy, err
+	}
+	if index != nil {
+		policy.ExpireAfterSeconds = index.ExpireAfterSeconds
+	}
+
+	if t.mechanism == RetentionMechanismPurgeJob {
+		policy.IndexName = ""
+		policy.Days = configured
+		policy.Status = RetentionStatusOk
+		if configured == 0 {
+			policy.Status = RetentionStatusDisabled
+		}
+		if index != nil && index.ExpireAfterSeconds != nil {
+			policy.Status = RetentionStatusLegacyTTL
+			policy.Message = fmt.Sprintf("TTL index %s vẫn còn, MongoDB sẽ tự xóa tài khoản mà không xóa dữ liệu liên quan", t.indexName)
+		}
+		return policy, nil
+	}
+
+	switch {
+	case index == nil:
+		policy.Status = RetentionStatusDisabled
+	case index.ExpireAfterSeconds == nil:
+		policy.Status = RetentionStatusNotTTL
+		policy.Message = fmt.Sprintf("Index %s tồn tại nhưng không phải TTL index", t.indexName)
+	default:
+		policy.Days = int(*index.ExpireAfterSeconds / secondsPerDay)
+		policy.Status = RetentionStatusOk
+	}
+	if policy.Status != RetentionStatusNotTTL && found && configured != policy.Days {
+		policy.Status = RetentionStatusOutOfSync
+		policy.Message = fmt.Sprintf("Cấu hình lưu %d ngày nhưng index đang là %d ngày", configured, policy.Days)
+	}
+	return policy, nil
+}
+
+// Apply áp dụng số ngày lưu trữ mới. TTL index đã có được sửa bằng collMod,
+// chỉ tạo mới khi chưa có và chỉ xóa khi tắt chính sách (days = 0).
+func (r *RetentionService) Apply(ctx context.Context, target string, days int, updatedBy primitive.ObjectID) (RetentionPolicy, error) {
+	if days < 0 || days > MaxRetentionDays {
+		return RetentionPolicy{}, fmt.Errorf("Số ngày lưu trữ phải từ 0 đến %d", MaxRetentionDays)
+	}
+	current, err := r.Get(ctx, target)
+	if err != nil {
+		return current, err
+	}
+	t := r.targets[target]
+	previous := current.Days
+	applied := RetentionAppliedUnchanged
+
+	if t.mechanism == RetentionMechanismPurgeJob {
+		if current.ConfiguredDays == nil || *current.ConfiguredDays != days {
+			if err := r.purgeService.SetRetentionDays(ctx, days, updatedBy); err != nil {
+				return current, err
+			}
+			applied = RetentionAppliedUpdated
+		}
+		if current.Status == RetentionStatusLegacyTTL {
+			if err := t.indexes.DropIndex(ctx, t.indexName); err != nil {
+				return current, fmt.Errorf("Không thể xóa TTL index %s: %w", t.indexName, err)
+			}
+			applied = RetentionAppliedUpdated
+		}
+	} else {
+		seconds := int64(days) * secondsPerDay
+		switch {
+		case current.Status == RetentionStatusNotTTL:
+			return current, errors.New(current.Message)
+		case days == 0 && current.ExpireAfterSeconds != nil:
+			if err := t.indexes.DropIndex(ctx, t.indexName); err != nil {
+				return current, fmt.Errorf("Không thể xóa TTL index %s: %w", t.indexName, err)
+			}
+			applied = RetentionAppliedDropped
+		case days > 0 && current.ExpireAfterSeconds == nil:
+			if err := t.indexes.CreateTTLIndex(ctx, t.indexName, t.field, seconds, t.partialFilter); err != nil {
+				return current, fmt.Errorf("Không thể tạo TTL index %s: %w", t.indexName, err)
+			}
+			applied = RetentionAppliedCreated
+		case days > 0 && *current.ExpireAfterSeconds != seconds:
+			if err := t.indexes.SetTTL(ctx, t.indexName, seconds); err != nil {
+				return current, fmt.Errorf("collMod TTL index %s thất bại: %w", t.indexName, err)
+			}
+			applied = RetentionAppliedModified
+		}
+		if current.ConfiguredDays == nil || *current.ConfiguredDays != days {
+			if err := r.settingCollection.Set(ctx, retentionSettingKey(target), days, updatedBy); err != nil {
+				return current, err
+			}
+		}
+	}
+
+	policy, err := r.Get(ctx, target)
+	if err != nil {
+		return policy, err
+	}
+	policy.Applied = applied
+	policy.PreviousDays = &previous
+	return policy, nil
+}
+
+func retentionSettingKey(target string) string {
+	if target == RetentionTargetAccounts {
+		return models.SettingAccountRetentionDays
+	}
+	return "retention." + target
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrUploadNotFound   = errors.New("Không tìm thấy phiên upload")
+	ErrUploadExpired    = errors.New("Phiên upload đã hết hạn")
+	ErrUploadTooLarge   = errors.New("File vượt quá dung lượng cho phép")
+	ErrOffsetMismatch   = errors.New("Upload-Offset không khớp với dữ liệu đã nhận")
+	ErrUploadIncomplete = errors.New("Phiên upload chưa nhận đủ dữ liệu")
+)
+
+// tusPrefix là thư mục chứa các phiên upload dở dang trong storage
+const tusPrefix = "tus"
+
+// TusUpload là một phiên upload theo giao thức tus, Offset là số byte đã nhận liên tục từ đầu file
+type TusUpload struct {
+	Id        string             `json:"id"`
+	AccountId primitive.ObjectID `json:"account_id"`
+	Length    int64              `json:"length"`
+	Offset    int64              `json:"-"`
+	Metadata  map[string]string  `json:"metadata,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	ExpiresAt time.Time          `json:"expires_at"`
+}
+
+// TusService lưu upload dở dang vào storage để mọi replica cùng tiếp tục được một phiên:
+// tus/<id>/info.json mô tả phiên, mỗi lần PATCH được lưu thành tus/<id>/<offset>.part.
+// Phần được ghi bằng storage.Create nên hai PATCH cùng offset (kể cả trên hai replica) chỉ một
+// bên được ghi, không cần sticky session hay khóa trong server.
+type TusService struct {
+	storage  storage.Storage
+	maxBytes int64
+	ttl      time.Duration
+}
+
+func NewTusService(uploadStorage storage.Storage, maxBytes int64, ttl time.Duration) *TusService {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &TusService{
+		storage:  uploadStorage,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+	}
+}
+
+// MaxSize là dung lượng tối đa của một file, trả về cho client qua Tus-Max-Size
+func (t *TusService) MaxSize() int64 {
+	return t.maxBytes
+}
+
+func (t *TusService) Create(ctx context.Context, accountId primitive.ObjectID, length int64, metadata map[string]string) (TusUpload, error) {
+	if length > t.maxBytes {
+		return TusUpload{}, fmt.Errorf("%w: file không được quá %d MB", ErrUploadTooLarge, t.maxBytes>>20)
+	}
+	now := time.Now()
+	upload := TusUpload{
+		Id:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+		AccountId: accountId,
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: now,
+		ExpiresAt: now.Add(t.ttl),
+	}
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	if err := t.storage.Put(ctx, infoKey(upload.Id), bytes.NewReader(data), int64(len(data)), "application/json"); err != nil {
+		return TusUpload{}, err
+	}
+	return upload, nil
+}
+
+// Get trả về phiên upload của accountId cùng offset hiện tại, phiên hết hạn bị xóa và trả ErrUploadExpired
+func (t *TusService) Get(ctx context.Context, accountId primitive.ObjectID, id string) (TusUpload, error) {
+	upload, err := t.info(ctx, id)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	if upload.AccountId != accountId {
+		return TusUpload{}, ErrUploadNotFound
+	}
+	if time.Now().After(upload.ExpiresAt) {
+		if err := t.remove(ctx, id); err != nil {
+			log.Println("Không thể xóa phiên upload hết hạn", id, err)
+		}
+		return TusUpload{}, ErrUploadExpired
+	}
+	upload.Offset, err = t.offset(ctx, id)
+	return upload, err
+}
+
+// Append ghi data vào phiên tại offset. offset phải bằng số byte đã nhận và tổng không được vượt Length;
+// PATCH khác đã ghi offset này trước (request song song) cũng trả ErrOffsetMismatch.
+func (t *TusService) Append(ctx context.Context, accountId primitive.ObjectID, id string, offset int64, data []byte) (TusUpload, error) {
+	upload, err := t.Get(ctx, accountId, id)
+	if err != nil {
+		return TusUpload{}, err
+	}
+	if offset != upload.Offset {
+		return upload, ErrOffsetMismatch
+	}
+	if offset+int64(len(data)) > upload.Length {
+		return upload, ErrUploadTooLarge
+	}
+	if len(data) == 0 {
+		return upload, nil
+	}
+	err = t.storage.Create(ctx, partKey(id, offset), bytes.NewReader(data), int64(len(data)), "application/octet-stream")
+	if errors.Is(err, storage.ErrExists) {
+		return upload, ErrOffsetMismatch
+	}
+	if err != nil {
+		return upload, err
+	}
+	upload.Offset += int64(len(data))
+	return upload, nil
+}
+
+// Open ghép các phần đã nhận của phiên đã đủ dữ liệu thành file hoàn chỉnh
+func (t *TusService) Open(ctx context.Context, upload TusUpload) ([]byte, error) {
+	if upload.Offset != upload.Length {
+		return nil, ErrUploadIncomplete
+	}
+	parts, err := t.parts(ctx, upload.Id)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, upload.Length)
+	for _, part := range parts {
+		object, err := t.storage.Get(ctx, part.Key)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := io.ReadAll(object.Body)
+		object.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, chunk...)
+	}
+	if int64(len(data)) != upload.Length {
+		return nil, ErrUploadIncomplete
+	}
+	return data, nil
+}
+
+// Delete hủy phiên upload (tus termination) hoặc dọn phiên đã hoàn tất
+func (t *TusService) Delete(ctx context.Context, accountId primitive.ObjectID, id string) error {
+	upload, err := t.info(ctx, id)
+	if err != nil {
+		return err
+	}
+	if upload.AccountId != accountId {
+		return ErrUploadNotFound
+	}
+	return t.remove(ctx, id)
+}
+
+// Cleanup xóa các phiên đã hết hạn, trả về số phiên đã xóa
+func (t *TusService) Cleanup(ctx context.Context) (int, error) {
+	ids := map[string]bool{}
+	err := t.storage.List(ctx, tusPrefix+"/", func(object storage.ObjectInfo) error {
+		if parts := strings.Split(object.Key, "/"); len(parts) == 3 {
+			ids[parts[1]] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	now := time.Now()
+	for id := range ids {
+		upload, err := t.info(ctx, id)
+		// Phiên mất info.json (vd: lỗi giữa chừng khi xóa) cũng được dọn
+		if err != nil && !errors.Is(err, ErrUploadNotFound) {
+			return removed, err
+		}
+		if err == nil && now.Before(upload.ExpiresAt) {
+			continue
+		}
+		if err := t.remove(ctx, id); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Start dọn phiên upload hết hạn định kỳ cho tới khi ctx bị hủy
+func (t *TusService) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		runCtx, cancel := context.WithTimeout(ctx, interval)
+		removed, err := t.Cleanup(runCtx)
+		cancel()
+		if err != nil {
+			log.Println("Job dọn phiên upload lỗi", err)
+		} else if removed > 0 {
+			log.Printf("Đã xóa %d phiên upload hết hạn\n", removed)
+		}
+	}
+}
+
+func (t *TusService) info(ctx context.Context, id string) (TusUpload, error) {
+	if !validUploadId(id) {
+		return TusUpload{}, ErrUploadNotFound
+	}
+	object, err := t.storage.Get(ctx, infoKey(id))
+	if errors.Is(err, storage.ErrNotFound) {
+		return TusUpload{}, ErrUploadNotFound
+	}
+	if err != nil {
+		return TusUpload{}, err
+	}
+	defer object.Body.Close()
+	var upload TusUpload
+	if err := json.NewDecoder(object.Body).Decode(&upload); err != nil {
+		return TusUpload{}, err
+	}
+	return upload, nil
+}
+
+// offset là tổng độ dài các phần nối tiếp nhau từ đầu file
+func (t *TusService) offset(ctx context.Context, id string) (int64, error) {
+	parts, err := t.parts(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	for _, part := range parts {
+		if part.Key != partKey(id, offset) {
+			break
+		}
+		offset += part.Size
+	}
+	return offset, nil
+}
+
+func (t *TusService) parts(ctx context.Context, id string) ([]storage.ObjectInfo, error) {
+	parts := []storage.ObjectInfo{}
+	err := t.storage.List(ctx, path.Join(tusPrefix, id)+"/", func(object storage.ObjectInfo) error {
+		if strings.HasSuffix(object.Key, ".part") {
+			parts = append(parts, object)
+		}
+		return nil
+	})
+	// Tên phần chứa offset đệm số 0 nên sắp xếp theo tên là theo thứ tự trong file
+	sort.Slice(parts, func(i, j int) bool { return parts[i].Key < parts[j].Key })
+	return parts, err
+}
+
+func (t *TusService) remove(ctx context.Context, id string) error {
+	keys := []string{}
+	err := t.storage.List(ctx, path.Join(tusPrefix, id)+"/", func(object storage.ObjectInfo) error {
+		if object.Key != infoKey(id) {
+			keys = append(keys, object.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Xóa info.json sau cùng để nếu lỗi giữa chừng thì Cleanup vẫn tìm thấy phiên
+	keys = append(keys, infoKey(id))
+	for _, key := range keys {
+		if err := t.storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// validUploadId chỉ nhận id do Create sinh ra (32 ký tự hex) để id từ URL không tạo được key tùy ý
+func validUploadId(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func infoKey(id string) string {
+	return path.Join(tusPrefix, id, "info.json")
+}
+
+func partKey(id string, offset int64) string {
+	return path.Join(tusPrefix, id, fmt.Sprintf("%020d.part", offset))
+}
@@ -0,0 +1,143 @@
+package services_test
+
+import (
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTusServiceAppend(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	tus := services.NewTusService(local, 100, time.Hour)
+	accountId := primitive.NewObjectID()
+
+	if _, err := tus.Create(ctx, accountId, 101, nil); !errors.Is(err, services.ErrUploadTooLarge) {
+		t.Fatalf("Upload-Length vượt giới hạn phải lỗi, nhận %v", err)
+	}
+	upload, err := tus.Create(ctx, accountId, 11, map[string]string{"filename": "a.png"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := tus.Append(ctx, accountId, upload.Id, 0, []byte("hello ")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if _, err := tus.Append(ctx, accountId, upload.Id, 0, []byte("hello ")); !errors.Is(err, services.ErrOffsetMismatch) {
+		t.Fatalf("Append sai offset phải lỗi, nhận %v", err)
+	}
+	if _, err := tus.Append(ctx, accountId, upload.Id, 6, []byte("world!")); !errors.Is(err, services.ErrUploadTooLarge) {
+		t.Fatalf("Append vượt Upload-Length phải lỗi, nhận %v", err)
+	}
+	if _, err := tus.Get(ctx, primitive.NewObjectID(), upload.Id); !errors.Is(err, services.ErrUploadNotFound) {
+		t.Fatalf("tài khoản khác không được đọc phiên, nhận %v", err)
+	}
+	if _, err := tus.Get(ctx, accountId, "../../etc"); !errors.Is(err, services.ErrUploadNotFound) {
+		t.Fatalf("id không hợp lệ phải trả ErrUploadNotFound, nhận %v", err)
+	}
+	upload, err = tus.Append(ctx, accountId, upload.Id, 6, []byte("world"))
+	if err != nil || upload.Offset != 11 {
+		t.Fatalf("Append: %+v %v", upload, err)
+	}
+	// Offset được tính lại từ storage nên replica khác cũng tiếp tục được
+	upload, err = services.NewTusService(local, 100, time.Hour).Get(ctx, accountId, upload.Id)
+	if err != nil || upload.Offset != 11 || upload.Metadata["filename"] != "a.png" {
+		t.Fatalf("Get: %+v %v", upload, err)
+	}
+	data, err := tus.Open(ctx, upload)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("Open = %q %v", data, err)
+	}
+}
+
+// Nhiều replica nhận PATCH cùng offset cùng lúc: chỉ một phần được ghi, các request còn lại
+// nhận ErrOffsetMismatch và dữ liệu ghép lại không bị lẫn.
+func TestTusServiceConcurrentAppend(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	accountId := primitive.NewObjectID()
+	upload, err := services.NewTusService(local, 100, time.Hour).Create(ctx, accountId, 20, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	chunks := []string{"aaaaaaaaaa", "bbbbb", "cccccccccccccccccccc", "ddd"}
+	errs := make([]error, len(chunks))
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = services.NewTusService(local, 100, time.Hour).Append(ctx, accountId, upload.Id, 0, []byte(chunk))
+		}()
+	}
+	wg.Wait()
+
+	written := ""
+	for i, err := range errs {
+		switch {
+		case err == nil && written == "":
+			written = chunks[i]
+		case err == nil:
+			t.Fatalf("hai PATCH cùng offset đều được ghi: %q và %q", written, chunks[i])
+		case !errors.Is(err, services.ErrOffsetMismatch):
+			t.Fatalf("PATCH bị từ chối phải trả ErrOffsetMismatch, nhận %v", err)
+		}
+	}
+	upload, err = services.NewTusService(local, 100, time.Hour).Get(ctx, accountId, upload.Id)
+	if err != nil || upload.Offset != int64(len(written)) {
+		t.Fatalf("offset phải bằng phần đã ghi %q, nhận %+v %v", written, upload, err)
+	}
+}
+
+func TestTusServiceExpiration(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	accountId := primitive.NewObjectID()
+	expiring := services.NewTusService(local, 100, 10*time.Millisecond)
+	expired, err := expiring.Create(ctx, accountId, 10, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := expiring.Append(ctx, accountId, expired.Id, 0, []byte("abc")); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	active, err := services.NewTusService(local, 100, time.Hour).Create(ctx, accountId, 10, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := expiring.Get(ctx, accountId, expired.Id); !errors.Is(err, services.ErrUploadExpired) {
+		t.Fatalf("phiên hết hạn phải trả ErrUploadExpired, nhận %v", err)
+	}
+	expired, err = expiring.Create(ctx, accountId, 10, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	removed, err := expiring.Cleanup(ctx)
+	if err != nil || removed != 1 {
+		t.Fatalf("Cleanup phải xóa 1 phiên hết hạn, nhận %d %v", removed, err)
+	}
+	if _, err := expiring.Get(ctx, accountId, expired.Id); !errors.Is(err, services.ErrUploadNotFound) {
+		t.Fatalf("phiên đã dọn phải trả ErrUploadNotFound, nhận %v", err)
+	}
+	if _, err := expiring.Get(ctx, accountId, active.Id); err != nil {
+		t.Fatalf("phiên còn hạn không được bị dọn: %v", err)
+	}
+}
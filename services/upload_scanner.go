@@ -0,0 +1,102 @@
+package services
+
+import (
+	"UserManagementVer/scanner"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+)
+
+var (
+	ErrMalwareDetected = errors.New("File chứa mã độc")
+	ErrScanUnavailable = errors.New("Không thể quét mã độc")
+)
+
+// MalwareError cho biết file bị từ chối vì chứa mã độc, errors.Is(err, ErrMalwareDetected) = true
+type MalwareError struct {
+	Signature string
+	// QuarantineKey là key của file trong storage cách ly, rỗng nếu không cách ly được
+	QuarantineKey string
+}
+
+func (e *MalwareError) Error() string {
+	return fmt.Sprintf("%s (%s)", ErrMalwareDetected.Error(), e.Signature)
+}
+
+func (e *MalwareError) Unwrap() error {
+	return ErrMalwareDetected
+}
+
+// UploadScanner quét file upload trước khi lưu và cách ly file bị phát hiện mã độc
+type UploadScanner struct {
+	scanner    scanner.Scanner
+	quarantine storage.Storage
+	// failOpen = true thì vẫn nhận file khi scanner lỗi, ngược lại từ chối với ErrScanUnavailable
+	failOpen bool
+}
+
+// quarantine = nil thì file mã độc chỉ bị từ chối, không được giữ lại
+func NewUploadScanner(malwareScanner scanner.Scanner, quarantine storage.Storage, failOpen bool) *UploadScanner {
+	return &UploadScanner{
+		scanner:    malwareScanner,
+		quarantine: quarantine,
+		failOpen:   failOpen,
+	}
+}
+
+// Check trả về *MalwareError nếu data chứa mã độc, lỗi bọc ErrScanUnavailable nếu không quét được
+// và chính sách là fail-closed
+func (u *UploadScanner) Check(ctx context.Context, data []byte) error {
+	// Service nil khi không bật quét mã độc
+	if u == nil {
+		return nil
+	}
+	result, err := u.scanner.Scan(ctx, bytes.NewReader(data))
+	if err != nil {
+		if u.failOpen {
+			log.Println("Không quét được mã độc, vẫn nhận file do cấu hình fail_open", err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrScanUnavailable, err)
+	}
+	if !result.Infected {
+		return nil
+	}
+	malwareErr := &MalwareError{Signature: result.Signature}
+	if u.quarantine != nil {
+		key, err := u.quarantineFile(ctx, data, result.Signature)
+		if err != nil {
+			log.Println("Không thể cách ly file chứa mã độc", result.Signature, err)
+		}
+		malwareErr.QuarantineKey = key
+	}
+	return malwareErr
+}
+
+// quarantineFile lưu file tại quarantine/<ngày>/<sha256> kèm file .json mô tả kết quả quét
+func (u *UploadScanner) quarantineFile(ctx context.Context, data []byte, signature string) (string, error) {
+	digest := sha256.Sum256(data)
+	now := time.Now().UTC()
+	key := path.Join("quarantine", now.Format("2006-01-02"), hex.EncodeToString(digest[:]))
+	if err := u.quarantine.Put(ctx, key, bytes.NewReader(data), int64(len(data)), "application/octet-stream"); err != nil {
+		return "", err
+	}
+	metadata, err := json.Marshal(map[string]interface{}{
+		"signature":   signature,
+		"sha256":      hex.EncodeToString(digest[:]),
+		"size":        len(data),
+		"detected_at": now,
+	})
+	if err != nil {
+		return key, err
+	}
+	return key, u.quarantine.Put(ctx, key+".json", bytes.NewReader(metadata), int64(len(metadata)), "application/json")
+}
@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"UserManagementVer/imaging"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/scanner"
+	"UserManagementVer/services"
+	"UserManagementVer/storage"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeScanner báo mã độc khi nội dung chứa "virus", lỗi khi err khác nil
+type fakeScanner struct {
+	err error
+}
+
+func (s fakeScanner) Scan(ctx context.Context, r io.Reader) (scanner.Result, error) {
+	if s.err != nil {
+		return scanner.Result{}, s.err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	if bytes.Contains(data, []byte("virus")) {
+		return scanner.Result{Infected: true, Signature: "Test-Virus"}, nil
+	}
+	return scanner.Result{}, nil
+}
+
+func TestUploadScanner(t *testing.T) {
+	ctx := context.Background()
+	quarantine, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	uploadScanner := services.NewUploadScanner(fakeScanner{}, quarantine, false)
+
+	if err := uploadScanner.Check(ctx, []byte("clean")); err != nil {
+		t.Fatalf("file sạch: %v", err)
+	}
+	err = uploadScanner.Check(ctx, []byte("a virus inside"))
+	var malwareErr *services.MalwareError
+	if !errors.Is(err, services.ErrMalwareDetected) || !errors.As(err, &malwareErr) || malwareErr.Signature != "Test-Virus" {
+		t.Fatalf("file mã độc phải trả MalwareError, nhận %v", err)
+	}
+	object, err := quarantine.Get(ctx, malwareErr.QuarantineKey)
+	if err != nil {
+		t.Fatalf("file mã độc phải được cách ly: %v", err)
+	}
+	data, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	if string(data) != "a virus inside" {
+		t.Fatalf("nội dung cách ly sai: %q", data)
+	}
+	object, err = quarantine.Get(ctx, malwareErr.QuarantineKey+".json")
+	if err != nil {
+		t.Fatalf("thiếu thông tin cách ly: %v", err)
+	}
+	data, _ = io.ReadAll(object.Body)
+	object.Body.Close()
+	if !strings.Contains(string(data), `"signature":"Test-Virus"`) {
+		t.Fatalf("thông tin cách ly sai: %s", data)
+	}
+
+	unavailable := errors.New("connection refused")
+	if err := services.NewUploadScanner(fakeScanner{err: unavailable}, nil, false).Check(ctx, []byte("clean")); !errors.Is(err, services.ErrScanUnavailable) {
+		t.Fatalf("fail-closed phải từ chối khi không quét được, nhận %v", err)
+	}
+	if err := services.NewUploadScanner(fakeScanner{err: unavailable}, nil, true).Check(ctx, []byte("clean")); err != nil {
+		t.Fatalf("fail-open phải nhận file khi không quét được, nhận %v", err)
+	}
+	var disabled *services.UploadScanner
+	if err := disabled.Check(ctx, []byte("a virus inside")); err != nil {
+		t.Fatalf("không bật quét thì không được lỗi: %v", err)
+	}
+}
+
+func TestAvatarUploadRejectsMalware(t *testing.T) {
+	avatars, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	avatarService := services.NewAvatarService(avatars, memory.NewAccountRepository(), services.NewUploadScanner(fakeScanner{}, nil, false), 1<<20, imaging.Options{Sizes: []int{16}})
+
+	if _, err := avatarService.Upload(context.Background(), strings.NewReader("a virus inside")); !errors.Is(err, services.ErrMalwareDetected) {
+		t.Fatalf("Upload phải từ chối file mã độc trước khi xử lý ảnh, nhận %v", err)
+	}
+	files := 0
+	avatars.List(context.Background(), "", func(storage.ObjectInfo) error {
+		files++
+		return nil
+	})
+	if files != 0 {
+		t.Fatalf("file mã độc không được lưu vào storage avatar, có %d file", files)
+	}
+}
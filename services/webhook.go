@@ -0,0 +1,310 @@
+package services
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Header gửi kèm mỗi webhook, bên nhận kiểm tra chữ ký bằng
+// HMAC-SHA256(secret, timestamp + "." + body)
+const (
+	WebhookHeaderId        = "X-Webhook-Id"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// webhookClaimLease là thời gian giữ delivery khi đang gửi, hết hạn thì worker khác được gửi lại
+	webhookClaimLease = 2 * time.Minute
+)
+
+type WebhookService struct {
+	subscriptionRepository repositories.WebhookSubscriptionRepository
+	deliveryRepository     repositories.WebhookDeliveryRepository
+	client                 *http.Client
+	maxAttempts            int
+	notify                 chan struct{}
+}
+
+type WebhookEnvelope struct {
+	Id        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+func NewWebhookService(subscriptionRepository repositories.WebhookSubscriptionRepository, deliveryRepository repositories.WebhookDeliveryRepository, timeout time.Duration, maxAttempts int) *WebhookService {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	return &WebhookService{
+		subscriptionRepository: subscriptionRepository,
+		deliveryRepository:     deliveryRepository,
+		client: &http.Client{
+			Timeout: timeout,
+			// Không đi theo redirect: secret và payload chỉ được gửi tới đúng URL đã đăng ký,
+			// phản hồi 3xx được ghi nhận như một lần gửi lỗi
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxAttempts: maxAttempts,
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+// GenerateWebhookSecret sinh secret dùng để ký payload của một subscription
+func GenerateWebhookSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(raw), nil
+}
+
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Deliver tạo delivery cho mọi subscription đang bật có đăng ký sự kiện, việc gửi do worker đảm nhận.
+// eventId được dùng làm id của webhook nên gọi lại với cùng sự kiện không tạo delivery trùng.
+func (w *WebhookService) Deliver(ctx context.Context, eventId string, event string, createdAt time.Time, data json.RawMessage) error {
+	subscriptions, err := w.subscriptionRepository.FindActive(ctx)
+	if err != nil {
+		return err
+	}
+	envelope := WebhookEnvelope{
+		Id:        eventId,
+		Event:     event,
+		CreatedAt: createdAt,
+		Data:      data,
+	}
+	for _, subscription := range subscriptions {
+		if !subscription.Subscribes(event) {
+			continue
+		}
+		if _, err := w.enqueue(ctx, subscription, envelope); err != nil && !repositories.IsDuplicate(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping gửi sự kiện thử tới một subscription, kể cả khi subscription không đăng ký sự kiện này
+func (w *WebhookService) Ping(ctx context.Context, subscription models.WebhookSubscription) (models.WebhookDelivery, error) {
+	eventId, err := uuid.NewRandom()
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return w.enqueue(ctx, subscription, WebhookEnvelope{
+		Id:        eventId.String(),
+		Event:     models.WebhookEventPing,
+		CreatedAt: time.Now(),
+		Data: bson.M{
+			"subscription_id": subscription.Id.Hex(),
+			"url":             subscription.Url,
+		},
+	})
+}
+
+// Redeliver đưa delivery về trạng thái chờ gửi, lịch sử các lần gửi trước vẫn được giữ lại
+func (w *WebhookService) Redeliver(ctx context.Context, deliveryId primitive.ObjectID) (models.WebhookDelivery, error) {
+	delivery, err := w.deliveryRepository.GetById(ctx, deliveryId)
+	if err != nil {
+		return delivery, err
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		return delivery, errors.New("Delivery đang chờ gửi")
+	}
+	now := time.Now()
+	if err := w.deliveryRepository.Reset(ctx, deliveryId, now); err != nil {
+		return delivery, err
+	}
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.AttemptCount = 0
+	delivery.NextAttemptAt = now
+	w.wake()
+	return delivery, nil
+}
+
+func (w *WebhookService) enqueue(ctx context.Context, subscription models.WebhookSubscription, envelope WebhookEnvelope) (models.WebhookDelivery, error) {
+	now := time.Now()
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery := models.WebhookDelivery{
+		SubscriptionId: subscription.Id,
+		EventId:        envelope.Id,
+		Event:          envelope.Event,
+		Payload:        string(payload),
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	delivery.Id, err = w.deliveryRepository.Create(ctx, delivery)
+	if err != nil {
+		return delivery, err
+	}
+	w.wake()
+	return delivery, nil
+}
+
+func (w *WebhookService) wake() {
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start chạy worker gửi webhook cho tới khi ctx bị hủy,
+// worker được đánh thức ngay khi có delivery mới hoặc định kỳ để gửi lại.
+func (w *WebhookService) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.processDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.notify:
+		}
+	}
+}
+
+func (w *WebhookService) processDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now()
+		claimCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		delivery, err := w.deliveryRepository.ClaimDue(claimCtx, now, webhookClaimLease)
+		cancel()
+		if errors.Is(err, repositories.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.Println("Không thể lấy webhook delivery", err)
+			return
+		}
+		w.attempt(ctx, delivery)
+	}
+}
+
+// attempt gửi một delivery và cập nhật kết quả, lỗi thì hẹn gửi lại theo back-off lũy thừa
+func (w *WebhookService) attempt(ctx context.Context, delivery models.WebhookDelivery) {
+	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	subscription, err := w.subscriptionRepository.GetById(dbCtx, delivery.SubscriptionId)
+	if err != nil || (!subscription.Active && delivery.Event != models.WebhookEventPing) {
+		reason := "Webhook đã bị tắt"
+		if err != nil {
+			reason = "Webhook không còn tồn tại"
+		}
+		w.finish(dbCtx, delivery, models.WebhookAttempt{At: time.Now(), Error: reason}, models.WebhookDeliveryFailed, time.Time{})
+		return
+	}
+
+	result := w.send(ctx, subscription, delivery)
+	if result.Error == "" && result.StatusCode >= 200 && result.StatusCode < 300 {
+		w.finish(dbCtx, delivery, result, models.WebhookDeliverySucceeded, time.Time{})
+		return
+	}
+	if delivery.AttemptCount+1 >= w.maxAttempts {
+		w.finish(dbCtx, delivery, result, models.WebhookDeliveryFailed, time.Time{})
+		return
+	}
+	w.finish(dbCtx, delivery, result, models.WebhookDeliveryPending, time.Now().Add(webhookBackoff(delivery.AttemptCount+1)))
+}
+
+func (w *WebhookService) send(ctx context.Context, subscription models.WebhookSubscription, delivery models.WebhookDelivery) models.WebhookAttempt {
+	startedAt := time.Now()
+	result := models.WebhookAttempt{At: startedAt}
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(startedAt.Unix(), 10)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.Url, bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "UserManagement-Webhook/1.0")
+	request.Header.Set(WebhookHeaderId, delivery.EventId)
+	request.Header.Set(WebhookHeaderEvent, delivery.Event)
+	request.Header.Set(WebhookHeaderTimestamp, timestamp)
+	request.Header.Set(WebhookHeaderSignature, SignWebhookPayload(subscription.Secret, timestamp, body))
+
+	response, err := w.client.Do(request)
+	result.DurationMs = time.Since(startedAt).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+	result.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		result.Error = fmt.Sprintf("Bên nhận trả về HTTP %d", response.StatusCode)
+	}
+	return result
+}
+
+func (w *WebhookService) finish(ctx context.Context, delivery models.WebhookDelivery, result models.WebhookAttempt, status string, nextAttemptAt time.Time) {
+	if err := w.deliveryRepository.SaveAttempt(ctx, delivery.Id, result, status, nextAttemptAt); err != nil {
+		log.Println("Không thể cập nhật webhook delivery", delivery.Id.Hex(), err)
+	}
+}
+
+// webhookBackoff trả về thời gian chờ trước lần gửi thứ attempt+1: 30s, 1m, 2m, 4m... tối đa 6h
+func webhookBackoff(attempt int) time.Duration {
+	return exponentialBackoff(attempt, webhookBaseBackoff, webhookMaxBackoff)
+}
+
+// exponentialBackoff nhân đôi thời gian chờ sau mỗi lần lỗi, không vượt quá max, cộng thêm tối đa 10% ngẫu nhiên
+func exponentialBackoff(attempt int, base time.Duration, max time.Duration) time.Duration {
+	backoff := base
+	for i := 1; i < attempt && backoff < max; i++ {
+		backoff *= 2
+	}
+	if backoff > max {
+		backoff = max
+	}
+	jitter, err := rand.Int(rand.Reader, big.NewInt(int64(backoff/10)+1))
+	if err != nil {
+		return backoff
+	}
+	return backoff + time.Duration(jitter.Int64())
+}
@@ -0,0 +1,171 @@
+package services_test
+
+import (
+	"UserManagementVer/models"
+	"UserManagementVer/repositories/memory"
+	"UserManagementVer/services"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	paths    []string
+	headers  []http.Header
+	bodies   [][]byte
+	statuses []int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, req.URL.Path)
+	r.headers = append(r.headers, req.Header.Clone())
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	if status == http.StatusFound {
+		w.Header().Set("Location", "/redirected")
+	}
+	w.WriteHeader(status)
+}
+
+func (r *webhookReceiver) received() ([]string, []http.Header, [][]byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.paths, r.headers, r.bodies
+}
+
+func newWebhookTest(t *testing.T, maxAttempts int, statuses ...int) (*services.WebhookService, *memory.WebhookDeliveryRepository, models.WebhookSubscription, *webhookReceiver) {
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	subscriptions := memory.NewWebhookSubscriptionRepository()
+	deliveries := memory.NewWebhookDeliveryRepository()
+	subscription := models.WebhookSubscription{Url: server.URL + "/hook", Events: []string{"*"}, Secret: "whsec_test", Active: true}
+	subscription.Id, _ = subscriptions.Create(context.Background(), subscription)
+
+	webhookService := services.NewWebhookService(subscriptions, deliveries, 5*time.Second, maxAttempts)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go webhookService.Start(ctx, time.Hour)
+	return webhookService, deliveries, subscription, receiver
+}
+
+// waitDelivery chờ worker gửi xong tới khi delivery có đủ attempts lần gửi
+func waitDelivery(t *testing.T, deliveries *memory.WebhookDeliveryRepository, id primitive.ObjectID, attempts int) models.WebhookDelivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		delivery, err := deliveries.GetById(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(delivery.Attempts) >= attempts {
+			return delivery
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery chưa có %d lần gửi: %+v", attempts, delivery)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWebhookSignatureHeaders(t *testing.T) {
+	webhookService, deliveries, subscription, receiver := newWebhookTest(t, 3)
+
+	delivery, err := webhookService.Ping(context.Background(), subscription)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivery = waitDelivery(t, deliveries, delivery.Id, 1)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.DeliveredAt.IsZero() {
+		t.Fatalf("delivery phải thành công: %+v", delivery)
+	}
+
+	_, headers, bodies := receiver.received()
+	header, body := headers[0], bodies[0]
+	if string(body) != delivery.Payload {
+		t.Fatalf("body gửi đi khác payload đã lưu: %s", body)
+	}
+	if header.Get(services.WebhookHeaderId) != delivery.EventId || header.Get(services.WebhookHeaderEvent) != models.WebhookEventPing {
+		t.Fatalf("header id/event sai: %v", header)
+	}
+	timestamp := header.Get(services.WebhookHeaderTimestamp)
+	if timestamp == "" || header.Get(services.WebhookHeaderSignature) != services.SignWebhookPayload(subscription.Secret, timestamp, body) {
+		t.Fatalf("chữ ký không khớp: %v", header)
+	}
+}
+
+func TestWebhookRetryOnError(t *testing.T) {
+	webhookService, deliveries, subscription, _ := newWebhookTest(t, 3, http.StatusInternalServerError)
+
+	delivery, _ := webhookService.Ping(context.Background(), subscription)
+	delivery = waitDelivery(t, deliveries, delivery.Id, 1)
+	if delivery.Status != models.WebhookDeliveryPending || delivery.AttemptCount != 1 {
+		t.Fatalf("HTTP 500 phải được hẹn gửi lại: %+v", delivery)
+	}
+	if attempt := delivery.Attempts[0]; attempt.StatusCode != http.StatusInternalServerError || attempt.Error == "" {
+		t.Fatalf("lần gửi lỗi phải được ghi lại: %+v", attempt)
+	}
+	if wait := time.Until(delivery.NextAttemptAt); wait < 25*time.Second || wait > 40*time.Second {
+		t.Fatalf("lần gửi lại phải chờ back-off 30s, còn %v", wait)
+	}
+}
+
+// Hết số lần gửi thì delivery failed, Redeliver gửi lại và giữ lịch sử các lần trước.
+// Bên nhận trả về redirect thì không đi theo mà ghi nhận là một lần gửi lỗi.
+func TestWebhookRedeliver(t *testing.T) {
+	webhookService, deliveries, subscription, receiver := newWebhookTest(t, 1, http.StatusInternalServerError, http.StatusFound, http.StatusOK)
+	ctx := context.Background()
+
+	delivery, _ := webhookService.Ping(ctx, subscription)
+	delivery = waitDelivery(t, deliveries, delivery.Id, 1)
+	if delivery.Status != models.WebhookDeliveryFailed {
+		t.Fatalf("hết số lần gửi phải failed: %+v", delivery)
+	}
+
+	if _, err := webhookService.Redeliver(ctx, delivery.Id); err != nil {
+		t.Fatal(err)
+	}
+	delivery = waitDelivery(t, deliveries, delivery.Id, 2)
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.Attempts[1].StatusCode != http.StatusFound {
+		t.Fatalf("redirect phải được ghi là lần gửi lỗi: %+v", delivery)
+	}
+
+	if _, err := webhookService.Redeliver(ctx, delivery.Id); err != nil {
+		t.Fatal(err)
+	}
+	delivery = waitDelivery(t, deliveries, delivery.Id, 3)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.AttemptCount != 1 {
+		t.Fatalf("gửi lại phải thành công: %+v", delivery)
+	}
+	statuses := []int{}
+	for _, attempt := range delivery.Attempts {
+		statuses = append(statuses, attempt.StatusCode)
+	}
+	if len(statuses) != 3 || statuses[0] != http.StatusInternalServerError || statuses[2] != http.StatusOK {
+		t.Fatalf("lịch sử gửi sai: %v", statuses)
+	}
+
+	paths, _, _ := receiver.received()
+	for _, path := range paths {
+		if path != "/hook" {
+			t.Fatalf("worker đã đi theo redirect: %v", paths)
+		}
+	}
+	if len(paths) != 3 {
+		t.Fatalf("bên nhận phải nhận đúng 3 request, nhận %v", paths)
+	}
+}
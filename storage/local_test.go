@@ -0,0 +1,99 @@
+package storage_test
+
+import (
+	"UserManagementVer/storage"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	key := "avatars/abc/photo.png"
+	if err := local.Put(ctx, key, strings.NewReader("png-data"), 8, "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	object, err := local.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	if string(data) != "png-data" || object.Size != 8 || object.ContentType != "image/png" {
+		t.Fatalf("object không khớp: %q %d %s", data, object.Size, object.ContentType)
+	}
+	if url, err := local.URL(ctx, key); err != nil || url != "" {
+		t.Fatalf("storage local không có URL trực tiếp, nhận %q %v", url, err)
+	}
+
+	if err := local.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := local.Get(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Get sau khi xóa phải trả ErrNotFound, nhận %v", err)
+	}
+	if err := local.Delete(ctx, key); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Delete lần hai phải trả ErrNotFound, nhận %v", err)
+	}
+}
+
+func TestLocalStorageCreate(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	key := "tus/abc/00000000000000000000.part"
+	if err := local.Create(ctx, key, strings.NewReader("first"), 5, "application/octet-stream"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := local.Create(ctx, key, strings.NewReader("second"), 6, "application/octet-stream"); !errors.Is(err, storage.ErrExists) {
+		t.Fatalf("Create key đã tồn tại phải trả ErrExists, nhận %v", err)
+	}
+	object, err := local.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(object.Body)
+	object.Body.Close()
+	if string(data) != "first" {
+		t.Fatalf("Create lỗi không được ghi đè file, nhận %q", data)
+	}
+}
+
+func TestLocalStorageRejectsInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	for _, key := range []string{"", "../secret", "avatars/../../secret", "/etc/passwd", "avatars//a.png", "a\\b.png"} {
+		if err := local.Put(ctx, key, strings.NewReader("x"), 1, "text/plain"); !errors.Is(err, storage.ErrInvalidKey) {
+			t.Errorf("Put %q phải trả ErrInvalidKey, nhận %v", key, err)
+		}
+		if _, err := local.Get(ctx, key); !errors.Is(err, storage.ErrInvalidKey) {
+			t.Errorf("Get %q phải trả ErrInvalidKey, nhận %v", key, err)
+		}
+	}
+}
+
+func TestKeyFromImageUrl(t *testing.T) {
+	cases := map[string]string{
+		`uploads\b4a3de0a_anhabc.jpg`: "b4a3de0a_anhabc.jpg",
+		"uploads/b4a3de0a_anhabc.jpg": "b4a3de0a_anhabc.jpg",
+		"avatars/abc/photo.png":       "avatars/abc/photo.png",
+	}
+	for imageUrl, want := range cases {
+		if got := storage.KeyFromImageUrl(imageUrl); got != want {
+			t.Errorf("KeyFromImageUrl(%q) = %q, muốn %q", imageUrl, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"UserManagementVer/configs"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Storage lưu file trên dịch vụ tương thích S3 (AWS S3, MinIO...), avatar được tải qua presigned URL
+type S3Storage struct {
+	client     *minio.Client
+	bucket     string
+	presignTtl time.Duration
+}
+
+var _ Storage = (*S3Storage)(nil)
+
+// NewS3Storage kết nối tới endpoint và tạo bucket nếu chưa có
+func NewS3Storage(config configs.S3) (*S3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("Thiếu endpoint hoặc bucket của S3")
+	}
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.UseSsl,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	presignTtl := time.Duration(config.PresignTtlSeconds) * time.Second
+	if presignTtl <= 0 {
+		presignTtl = 15 * time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, config.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("Không thể kiểm tra bucket %s: %w", config.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, config.Bucket, minio.MakeBucketOptions{Region: config.Region}); err != nil {
+			return nil, fmt.Errorf("Không thể tạo bucket %s: %w", config.Bucket, err)
+		}
+	}
+	return &S3Storage{client: client, bucket: config.Bucket, presignTtl: presignTtl}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// Create dùng If-None-Match: * (conditional write), cần AWS S3 hoặc MinIO có hỗ trợ
+func (s *S3Storage) Create(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	opts.SetMatchETagExcept("*")
+	_, err := s.client.PutObject(ctx, s.bucket, key, body, size, opts)
+	return translateS3Error(err)
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (Object, error) {
+	if err := ValidateKey(key); err != nil {
+		return Object{}, err
+	}
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return Object{}, translateS3Error(err)
+	}
+	// GetObject chỉ gửi request khi đọc, Stat để biết object có tồn tại không
+	info, err := object.Stat()
+	if err != nil {
+		object.Close()
+		return Object{}, translateS3Error(err)
+	}
+	return Object{Body: object, Size: info.Size, ContentType: info.ContentType, ModTime: info.LastModified}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	// RemoveObject không báo lỗi khi object không tồn tại
+	if _, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{}); err != nil {
+		return translateS3Error(err)
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3Storage) URL(ctx context.Context, key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	url, err := s.client.PresignedGetObject(ctx, s.bucket, key, s.presignTtl, nil)
+	if err != nil {
+		return "", err
+	}
+	return url.String(), nil
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	// Hủy ctx để goroutine liệt kê của minio dừng khi fn trả lỗi
+	defer cancel()
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err := fn(ObjectInfo{Key: object.Key, Size: object.Size, ModTime: object.LastModified}); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func translateS3Error(err error) error {
+	response := minio.ToErrorResponse(err)
+	if response.StatusCode == http.StatusNotFound || response.Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	// AWS trả 409 ConditionalRequestConflict khi hai conditional write cùng key chạy song song
+	if response.StatusCode == http.StatusPreconditionFailed || response.Code == "PreconditionFailed" || response.Code == "ConditionalRequestConflict" {
+		return ErrExists
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+// Package storage lưu file (avatar...) theo key trên ổ đĩa hoặc dịch vụ tương thích S3,
+// DB chỉ lưu key nên các replica dùng chung một nơi lưu.
+package storage
+
+import (
+	"UserManagementVer/configs"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Các driver lưu trữ
+const (
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+// LegacyUploadDir là thư mục mà các phiên bản cũ lưu avatar, image_url cũ có dạng uploads\<tên file>
+const LegacyUploadDir = "uploads"
+
+var (
+	ErrNotFound   = errors.New("Không tìm thấy file")
+	ErrInvalidKey = errors.New("Key của file không hợp lệ")
+	ErrExists     = errors.New("File đã tồn tại")
+)
+
+// Object là nội dung file đọc từ storage, người gọi phải Close Body
+type Object struct {
+	Body        io.ReadCloser
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// ObjectInfo là thông tin của một file khi liệt kê
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+type Storage interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Create giống Put nhưng chỉ ghi khi key chưa tồn tại, ngược lại trả ErrExists. Kiểm tra và ghi là
+	// một thao tác của storage nên các replica ghi cùng key thì chỉ một bên thành công.
+	Create(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get trả về ErrNotFound nếu key không tồn tại
+	Get(ctx context.Context, key string) (Object, error)
+	// Delete trả về ErrNotFound nếu key không tồn tại
+	Delete(ctx context.Context, key string) error
+	// URL trả về đường dẫn tạm thời để client tải trực tiếp,
+	// chuỗi rỗng nghĩa là driver không hỗ trợ và server phải tự trả nội dung
+	URL(ctx context.Context, key string) (string, error)
+	// List gọi fn cho từng file có key bắt đầu bằng prefix (kể cả trong thư mục con), fn trả lỗi thì dừng
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// New tạo storage theo cấu hình, driver rỗng dùng ổ đĩa local
+func New(config configs.Storage) (Storage, error) {
+	switch config.Driver {
+	case "", DriverLocal:
+		dir := config.LocalDir
+		if dir == "" {
+			dir = LegacyUploadDir
+		}
+		return NewLocalStorage(dir)
+	case DriverS3:
+		return NewS3Storage(config.S3)
+	default:
+		return nil, fmt.Errorf("Driver lưu trữ %s không được hỗ trợ", config.Driver)
+	}
+}
+
+// AvatarKey trả về key gốc của avatar theo hash nội dung (hex), các biến thể nằm dưới key này.
+// Cùng nội dung thì cùng key nên nhiều tài khoản dùng chung một avatar đã lưu.
+func AvatarKey(digest string) string {
+	return path.Join("avatars", digest[:2], digest)
+}
+
+// KeyFromImageUrl đổi image_url lưu trong tài khoản thành key,
+// image_url cũ dạng uploads\<tên file> được hiểu là key <tên file> của storage local
+func KeyFromImageUrl(imageUrl string) string {
+	key := strings.ReplaceAll(imageUrl, "\\", "/")
+	return strings.TrimPrefix(key, LegacyUploadDir+"/")
+}
+
+// ValidateKey chặn key rỗng, tuyệt đối hoặc có thành phần ".." để không đọc/ghi ra ngoài nơi lưu
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || filepath.IsAbs(key) {
+		return ErrInvalidKey
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
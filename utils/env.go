@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+)
+
+func GetEnv(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func GetIntEnv(key string, defaultValue int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
@@ -1,14 +1,17 @@
 package utils
 
 import (
+	"UserManagementVer/imaging"
 	"fmt"
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"strings"
 )
 
 var validFile = map[string]bool{
 	".heic": true, //định dạng ảnh của ios 11
+	".heif": true,
 	".png":  true,
 	".jpg":  true,
 	".jpeg": true,
@@ -27,8 +30,13 @@ var validMiMe = map[string]bool{
 }
 
 func ChechValidFile(fileHeader *multipart.FileHeader) error {
-	fileName := fileHeader.Filename
-	fileExt := filepath.Ext(fileName)
+	return CheckValidFileName(fileHeader.Filename)
+}
+
+// CheckValidFileName kiểm tra đuôi file, dùng khi chỉ có tên file (vd: metadata của upload tus)
+func CheckValidFileName(fileName string) error {
+	// iPhone đặt tên file dạng IMG_0001.HEIC nên so sánh đuôi không phân biệt hoa thường
+	fileExt := strings.ToLower(filepath.Ext(fileName))
 	if _, ok := validFile[fileExt]; !ok {
 		return fmt.Errorf("%s không phải là định dạng file hợp lệ!", fileName)
 	}
@@ -36,20 +44,45 @@ func ChechValidFile(fileHeader *multipart.FileHeader) error {
 }
 
 func CheckValidMiMe(fileHeader *multipart.FileHeader) error {
+	mimeType, err := DetectMiMe(fileHeader)
+	if err != nil {
+		return err
+	}
+	return checkMiMe(mimeType)
+}
+
+// CheckValidContent kiểm tra kiểu nội dung từ các byte đầu của file đã có sẵn trong bộ nhớ
+func CheckValidContent(data []byte) error {
+	return checkMiMe(DetectContentType(data))
+}
+
+func checkMiMe(mimeType string) error {
+	if _, ok := validMiMe[mimeType]; !ok {
+		return fmt.Errorf("%s không phải là định dạng file hợp lệ!", mimeType)
+	}
+
+	return nil
+}
+
+// DetectMiMe đoán kiểu nội dung từ 512 byte đầu của file, không tin Content-Type client gửi lên
+func DetectMiMe(fileHeader *multipart.FileHeader) (string, error) {
 	f, err := fileHeader.Open()
 
 	if err != nil {
-		return fmt.Errorf("Không thể mở được file: %w", err)
+		return "", fmt.Errorf("Không thể mở được file: %w", err)
 	}
 	defer f.Close()
 
 	buf := make([]byte, 512)
 	n, _ := f.Read(buf)
+	return DetectContentType(buf[:n]), nil
+}
 
-	mimeType := http.DetectContentType(buf[:n])
-	if _, ok := validMiMe[mimeType]; !ok {
-		return fmt.Errorf("%s không phải là định dạng file hợp lệ!", mimeType)
+// DetectContentType đoán kiểu nội dung từ tối đa 512 byte đầu của data
+func DetectContentType(data []byte) string {
+	// http.DetectContentType không nhận ra HEIC/HEIF nên kiểm tra chữ ký trước
+	if imaging.IsHeif(data) {
+		return "image/heic"
 	}
-
-	return nil
+	return http.DetectContentType(data)
 }
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ImportRow là một dòng dữ liệu đọc từ file import, Row tính theo số dòng trong file (bắt đầu từ 1)
+type ImportRow struct {
+	Row    int
+	Values map[string]string
+}
+
+var importColumns = []string{"name", "email", "password", "phone", "dob"}
+
+// ReadImportRows đọc file .xlsx hoặc .csv, tìm dòng tiêu đề chứa cột email
+// và trả về các dòng dữ liệu phía sau theo tên cột.
+func ReadImportRows(fileHeader *multipart.FileHeader, maxRows int) ([]ImportRow, error) {
+	f, err := fileHeader.Open()
+	if err != nil {
+		return nil, fmt.Errorf("Không thể mở được file: %w", err)
+	}
+	defer f.Close()
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+	case ".xlsx":
+		records, err = readExcelRecords(f)
+	case ".csv":
+		records, err = readCsvRecords(f)
+	default:
+		return nil, fmt.Errorf("%s không phải là định dạng file hợp lệ!", fileHeader.Filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	headerIndex := -1
+	columns := map[int]string{}
+	for i, record := range records {
+		hasEmail := false
+		for j, cell := range record {
+			name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(cell, "\ufeff")))
+			if slices.Contains(importColumns, name) {
+				columns[j] = name
+				hasEmail = hasEmail || name == "email"
+			}
+		}
+		if hasEmail {
+			headerIndex = i
+			break
+		}
+		columns = map[int]string{}
+	}
+	if headerIndex < 0 {
+		return nil, errors.New("Không tìm thấy dòng tiêu đề có cột email")
+	}
+
+	rows := []ImportRow{}
+	for i := headerIndex + 1; i < len(records); i++ {
+		values := map[string]string{}
+		empty := true
+		for j, cell := range records[i] {
+			column, ok := columns[j]
+			if !ok {
+				continue
+			}
+			values[column] = strings.TrimSpace(cell)
+			if values[column] != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+		rows = append(rows, ImportRow{Row: i + 1, Values: values})
+		if maxRows > 0 && len(rows) > maxRows {
+			return nil, fmt.Errorf("File import vượt quá %d dòng", maxRows)
+		}
+	}
+	return rows, nil
+}
+
+func readExcelRecords(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Không đọc được file excel: %w", err)
+	}
+	defer f.Close()
+	return f.GetRows(f.GetSheetName(0))
+}
+
+func readCsvRecords(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Không đọc được file csv: %w", err)
+	}
+	return records, nil
+}
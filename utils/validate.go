@@ -25,6 +25,10 @@ func HandlerValidation(err error) string {
 				errValidator += fmt.Sprintf("%s không được trống, ", strings.ToLower(e.Field()))
 			case "email":
 				errValidator += fmt.Sprintf("%s không phải là một email hợp lệ, ", strings.ToLower(e.Field()))
+			case "oneof":
+				errValidator += fmt.Sprintf("%s phải là một trong các giá trị: %s, ", strings.ToLower(e.Field()), e.Param())
+			case "min":
+				errValidator += fmt.Sprintf("%s phải có ít nhất %s phần tử, ", strings.ToLower(e.Field()), e.Param())
 			case "phoneVn":
 				errValidator += fmt.Sprintf("%s phải theo định dạng số phone Việt Nam, ", strings.ToLower(e.Field()))
 			}